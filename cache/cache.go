@@ -0,0 +1,407 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+const (
+	DefaultTagHeader         = "Cache-Tag"
+	DefaultRevalidateTimeout = 30 * time.Second
+)
+
+type Config struct {
+	Store Store
+	// Vary lists request headers that split the cache key, e.g. "Accept-Language".
+	Vary []string
+	// DefaultTTL applies to responses without max-age. Zero leaves them uncached.
+	DefaultTTL time.Duration
+	// StaleWhileRevalidate applies to responses without a stale-while-revalidate directive.
+	StaleWhileRevalidate time.Duration
+	// TagHeader names the response header handlers use to tag entries, DefaultTagHeader when empty.
+	TagHeader string
+	// RevalidateTimeout bounds background revalidations, DefaultRevalidateTimeout when zero.
+	RevalidateTimeout time.Duration
+}
+
+type Cache struct {
+	cfg          Config
+	calls        map[string]*call
+	revalidating map[string]struct{}
+	lock         sync.Mutex
+}
+
+type call struct {
+	done  chan struct{}
+	entry *Entry
+}
+
+func New(cfg Config) *Cache {
+	if cfg.Store == nil {
+		cfg.Store = NewLRUStore(1024, 64<<20)
+	}
+	if cfg.TagHeader == "" {
+		cfg.TagHeader = DefaultTagHeader
+	}
+	if cfg.RevalidateTimeout <= 0 {
+		cfg.RevalidateTimeout = DefaultRevalidateTimeout
+	}
+	return &Cache{
+		cfg:          cfg,
+		calls:        make(map[string]*call),
+		revalidating: make(map[string]struct{}),
+	}
+}
+
+// Wrap caches the output of c's handler. Request middlewares still run on every request,
+// so authorization is never skipped, and response middlewares run on cached replies too.
+// Requests with Authorization are never answered from the cache, and their responses are only
+// stored when marked public or with s-maxage.
+func (c *Cache) Wrap(rc controller.RestController) controller.RestController {
+	handler := rc.Handler
+	rc.Handler = func(lc *context.LuxContext) error {
+		return c.serve(lc, handler)
+	}
+	return rc
+}
+
+func (c *Cache) InvalidateTags(tags ...string) int {
+	return c.cfg.Store.DeleteTags(tags...)
+}
+
+func (c *Cache) Purge() {
+	c.cfg.Store.Clear()
+}
+
+// Tag attaches invalidation tags to the response being cached.
+func (c *Cache) Tag(lc *context.LuxContext, tags ...string) {
+	lc.Response.Header().Add(c.cfg.TagHeader, strings.Join(tags, ","))
+}
+
+func (c *Cache) serve(lc *context.LuxContext, handler controller.RestHandler) error {
+	if lc.Request.Method != http.MethodGet && lc.Request.Method != http.MethodHead {
+		return handler(lc)
+	}
+
+	requestDirectives := parseCacheControl(lc.Request.Header.Get("Cache-Control"))
+	if _, ok := requestDirectives["no-store"]; ok {
+		lc.Response.Header().Set("X-Cache", "BYPASS")
+		return handler(lc)
+	}
+
+	// responses to authorized requests are per user: only store those marked shareable,
+	// and never answer them from the cache or another request's response
+	if lc.Request.Header.Get("Authorization") != "" {
+		return c.serveUncached(lc, handler, lc.Request.Method == http.MethodGet, true)
+	}
+
+	key := c.key(lc.Request)
+	_, noCache := requestDirectives["no-cache"]
+	if maxAge, ok := requestDirectives["max-age"]; ok && maxAge == "0" {
+		noCache = true
+	}
+
+	if !noCache {
+		if entry, ok := c.cfg.Store.Get(key); ok {
+			now := time.Now()
+			if now.Before(entry.FreshUntil) {
+				writeEntry(lc, entry, "HIT")
+				return nil
+			}
+			if now.Before(entry.StaleUntil) {
+				writeEntry(lc, entry, "STALE")
+				c.revalidate(key, lc, handler)
+				return nil
+			}
+		}
+	}
+
+	// HEAD is answered from GET entries but its bodiless response is never stored
+	if lc.Request.Method == http.MethodHead {
+		return c.serveUncached(lc, handler, false, false)
+	}
+
+	cl, leader := c.begin(key)
+	if !leader {
+		select {
+		case <-cl.done:
+		case <-lc.RequestContext.Done():
+			return lc.RequestContext.Err()
+		}
+		if cl.entry != nil {
+			writeEntry(lc, cl.entry, "HIT")
+			return nil
+		}
+		return handler(lc)
+	}
+
+	var entry *Entry
+	defer func() {
+		c.finish(key, cl, entry)
+	}()
+
+	perRequest := headerNames(lc.Response.Header())
+	err := handler(lc)
+	if err != nil {
+		return err
+	}
+
+	entry = c.entry(lc, perRequest, false)
+	if entry != nil {
+		c.cfg.Store.Set(key, entry)
+	}
+	lc.Response.Header().Set("X-Cache", "MISS")
+	return nil
+}
+
+// serveUncached calls handler without looking up or coalescing, storing the response only when
+// store is set. Authorized responses are stored only when they are marked shareable.
+func (c *Cache) serveUncached(lc *context.LuxContext, handler controller.RestHandler, store bool, authorized bool) error {
+	perRequest := headerNames(lc.Response.Header())
+	if err := handler(lc); err != nil {
+		return err
+	}
+	if !store {
+		lc.Response.Header().Del(c.cfg.TagHeader)
+		lc.Response.Header().Set("X-Cache", "BYPASS")
+		return nil
+	}
+	if entry := c.entry(lc, perRequest, authorized); entry != nil {
+		c.cfg.Store.Set(c.key(lc.Request), entry)
+	}
+	lc.Response.Header().Set("X-Cache", "BYPASS")
+	return nil
+}
+
+func (c *Cache) begin(key string) (*call, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cl, ok := c.calls[key]; ok {
+		return cl, false
+	}
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	return cl, true
+}
+
+func (c *Cache) finish(key string, cl *call, entry *Entry) {
+	c.lock.Lock()
+	delete(c.calls, key)
+	c.lock.Unlock()
+
+	cl.entry = entry
+	close(cl.done)
+}
+
+// revalidate refreshes key in the background, once at a time. The handler gets a context
+// detached from the request, which may be over by then, with a request scope of its own and
+// RevalidateTimeout to finish.
+func (c *Cache) revalidate(key string, lc *context.LuxContext, handler controller.RestHandler) {
+	c.lock.Lock()
+	if _, ok := c.revalidating[key]; ok {
+		c.lock.Unlock()
+		return
+	}
+	c.revalidating[key] = struct{}{}
+	c.lock.Unlock()
+
+	background, cancel := lc.Detach(c.cfg.RevalidateTimeout)
+
+	go func() {
+		defer func() {
+			c.lock.Lock()
+			delete(c.revalidating, key)
+			c.lock.Unlock()
+		}()
+
+		defer cancel()
+		defer background.Complete()
+
+		if err := handler(background); err != nil {
+			if background.Logger != nil {
+				background.Logger.Error().Str("error", err.Error()).Str("key", key).Msg("Cache revalidation error")
+			}
+			return
+		}
+		if entry := c.entry(background, nil, false); entry != nil {
+			c.cfg.Store.Set(key, entry)
+		}
+	}()
+}
+
+// key is the same for GET and HEAD, so HEAD requests are answered from GET entries.
+func (c *Cache) key(r *http.Request) string {
+	builder := strings.Builder{}
+	builder.WriteString(http.MethodGet)
+	builder.WriteString(" ")
+	builder.WriteString(r.URL.Path)
+	builder.WriteString("?")
+	builder.WriteString(r.URL.Query().Encode())
+	for _, name := range c.cfg.Vary {
+		builder.WriteString("\n")
+		builder.WriteString(http.CanonicalHeaderKey(name))
+		builder.WriteString(":")
+		builder.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return builder.String()
+}
+
+// entry builds a cache entry from a finished response, or nil when it must not be stored.
+// Headers in perRequest, which request middlewares set before the handler ran, are left out
+// along with hop-by-hop ones. Authorized responses must be public or carry s-maxage.
+func (c *Cache) entry(lc *context.LuxContext, perRequest map[string]struct{}, authorized bool) *Entry {
+	res := lc.Response
+	tags := parseTags(res.Header().Values(c.cfg.TagHeader))
+	res.Header().Del(c.cfg.TagHeader)
+
+	if res.IsStream() || !cacheableStatus(res.StatusCode) || res.Header().Get("Set-Cookie") != "" {
+		return nil
+	}
+	if strings.Contains(res.Header().Get("Vary"), "*") {
+		return nil
+	}
+
+	directives := parseCacheControl(res.Header().Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return nil
+		}
+	}
+	if authorized {
+		_, public := directives["public"]
+		_, shared := directives["s-maxage"]
+		if !public && !shared {
+			return nil
+		}
+	}
+
+	ttl := c.cfg.DefaultTTL
+	if seconds, ok := directiveSeconds(directives, "s-maxage"); ok {
+		ttl = seconds
+	} else if seconds, ok := directiveSeconds(directives, "max-age"); ok {
+		ttl = seconds
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	stale := c.cfg.StaleWhileRevalidate
+	if seconds, ok := directiveSeconds(directives, "stale-while-revalidate"); ok {
+		stale = seconds
+	}
+
+	now := time.Now()
+	return &Entry{
+		StatusCode: res.StatusCode,
+		Headers:    storedHeaders(res.Header(), perRequest),
+		Body:       append([]byte(nil), res.Body...),
+		Tags:       tags,
+		StoredAt:   now,
+		FreshUntil: now.Add(ttl),
+		StaleUntil: now.Add(ttl + stale),
+	}
+}
+
+func writeEntry(lc *context.LuxContext, entry *Entry, status string) {
+	header := lc.Response.Header()
+	for key, values := range entry.Headers {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.FormatInt(int64(time.Since(entry.StoredAt)/time.Second), 10))
+	header.Set("X-Cache", status)
+	lc.Response.WriteHeader(entry.StatusCode)
+	lc.Response.Body = append(lc.Response.Body[:0], entry.Body...)
+}
+
+// perRequestHeaders are response headers that describe one request, never a cached reply.
+var perRequestHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Age", "Date", "X-Cache", "Set-Cookie", "X-Request-Id", "Traceparent", "Tracestate",
+}
+
+func headerNames(header http.Header) map[string]struct{} {
+	names := make(map[string]struct{}, len(header))
+	for name := range header {
+		names[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+	return names
+}
+
+// storedHeaders copies header without hop-by-hop headers, those named by Connection,
+// perRequestHeaders and the ones in perRequest.
+func storedHeaders(header http.Header, perRequest map[string]struct{}) http.Header {
+	skip := make(map[string]struct{}, len(perRequest)+len(perRequestHeaders))
+	for name := range perRequest {
+		skip[name] = struct{}{}
+	}
+	for _, name := range perRequestHeaders {
+		skip[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			skip[http.CanonicalHeaderKey(strings.TrimSpace(name))] = struct{}{}
+		}
+	}
+
+	stored := make(http.Header, len(header))
+	for name, values := range header {
+		if _, ok := skip[http.CanonicalHeaderKey(name)]; !ok {
+			stored[name] = append([]string(nil), values...)
+		}
+	}
+	return stored
+}
+
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		directives[name] = strings.Trim(strings.TrimSpace(value), "\"")
+	}
+	return directives
+}
+
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func parseTags(values []string) []string {
+	tags := []string(nil)
+	for _, value := range values {
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
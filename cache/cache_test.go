@@ -0,0 +1,460 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/lux"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]string
+	}{
+		{"", map[string]string{}},
+		{"no-store", map[string]string{"no-store": ""}},
+		{"Public, MAX-AGE=60", map[string]string{"public": "", "max-age": "60"}},
+		{`max-age="30" , s-maxage=10,,`, map[string]string{"max-age": "30", "s-maxage": "10"}},
+		{"private=\"Set-Cookie\", stale-while-revalidate=5", map[string]string{"private": "Set-Cookie", "stale-while-revalidate": "5"}},
+	}
+	for _, tt := range tests {
+		got := parseCacheControl(tt.header)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseCacheControl(%q) = %v, want %v", tt.header, got, tt.want)
+			continue
+		}
+		for name, value := range tt.want {
+			if got[name] != value {
+				t.Errorf("parseCacheControl(%q)[%s] = %q, want %q", tt.header, name, got[name], value)
+			}
+		}
+	}
+}
+
+func TestDirectiveSeconds(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"max-age=60", time.Minute, true},
+		{"max-age=0", 0, true},
+		{"max-age=-1", 0, false},
+		{"max-age=soon", 0, false},
+		{"max-age", 0, false},
+		{"no-cache", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := directiveSeconds(parseCacheControl(tt.header), "max-age")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("directiveSeconds(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags([]string{"user:1, posts", "feed"})
+	if strings.Join(got, "|") != "user:1|posts|feed" {
+		t.Errorf("parseTags = %q", got)
+	}
+}
+
+type response struct {
+	status       int
+	cacheControl string
+	header       map[string]string
+}
+
+func newCachedServer(cfg Config, res response, calls *int32) *lux.Lux {
+	c := New(cfg)
+	l := lux.New()
+	rc := c.Wrap(controller.RestController{
+		Handler: func(lc *context.LuxContext) error {
+			n := atomic.AddInt32(calls, 1)
+			if res.cacheControl != "" {
+				lc.Response.Header().Set("Cache-Control", res.cacheControl)
+			}
+			for key, value := range res.header {
+				lc.Response.Header().Set(key, value)
+			}
+			if res.status != 0 {
+				lc.SetStatus(res.status)
+			}
+			return lc.ReplyString("call " + strconv.Itoa(int(n)))
+		},
+	})
+	l.AddRestController("/item", controller.GET, rc)
+	l.AddRestController("/item", controller.HEAD, rc)
+	return l
+}
+
+func TestCache(t *testing.T) {
+	type request struct {
+		method string
+		header map[string]string
+		cache  string
+		body   string
+	}
+	get := func(cache string, body string) request {
+		return request{method: http.MethodGet, cache: cache, body: body}
+	}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		response response
+		requests []request
+	}{
+		{
+			name:     "max-age",
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{get("MISS", "call 1"), get("HIT", "call 1")},
+		},
+		{
+			name:     "default ttl",
+			cfg:      Config{DefaultTTL: time.Minute},
+			response: response{},
+			requests: []request{get("MISS", "call 1"), get("HIT", "call 1")},
+		},
+		{
+			name:     "no ttl",
+			response: response{},
+			requests: []request{get("MISS", "call 1"), get("MISS", "call 2")},
+		},
+		{
+			name:     "no-store response",
+			cfg:      Config{DefaultTTL: time.Minute},
+			response: response{cacheControl: "no-store"},
+			requests: []request{get("MISS", "call 1"), get("MISS", "call 2")},
+		},
+		{
+			name:     "private response",
+			response: response{cacheControl: "private, max-age=60"},
+			requests: []request{get("MISS", "call 1"), get("MISS", "call 2")},
+		},
+		{
+			name:     "set-cookie",
+			response: response{cacheControl: "max-age=60", header: map[string]string{"Set-Cookie": "a=b"}},
+			requests: []request{get("MISS", "call 1"), get("MISS", "call 2")},
+		},
+		{
+			name:     "vary star",
+			response: response{cacheControl: "max-age=60", header: map[string]string{"Vary": "*"}},
+			requests: []request{get("MISS", "call 1"), get("MISS", "call 2")},
+		},
+		{
+			name:     "uncacheable status",
+			response: response{cacheControl: "max-age=60", status: http.StatusInternalServerError},
+			requests: []request{get("MISS", "call 1"), get("MISS", "call 2")},
+		},
+		{
+			name:     "cached not found",
+			response: response{cacheControl: "max-age=60", status: http.StatusNotFound},
+			requests: []request{get("MISS", "call 1"), get("HIT", "call 1")},
+		},
+		{
+			name:     "no-store request",
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{
+				{method: http.MethodGet, header: map[string]string{"Cache-Control": "no-store"}, cache: "BYPASS", body: "call 1"},
+				get("MISS", "call 2"),
+			},
+		},
+		{
+			name:     "no-cache request refreshes",
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{
+				get("MISS", "call 1"),
+				{method: http.MethodGet, header: map[string]string{"Cache-Control": "no-cache"}, cache: "MISS", body: "call 2"},
+				get("HIT", "call 2"),
+			},
+		},
+		{
+			name:     "max-age=0 request refreshes",
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{
+				get("MISS", "call 1"),
+				{method: http.MethodGet, header: map[string]string{"Cache-Control": "max-age=0"}, cache: "MISS", body: "call 2"},
+			},
+		},
+		{
+			name:     "head from get",
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{
+				{method: http.MethodHead, cache: "BYPASS"},
+				get("MISS", "call 2"),
+				{method: http.MethodHead, cache: "HIT"},
+			},
+		},
+		{
+			name:     "authorized private",
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{
+				get("MISS", "call 1"),
+				{method: http.MethodGet, header: map[string]string{"Authorization": "Bearer a"}, cache: "BYPASS", body: "call 2"},
+				get("HIT", "call 1"),
+			},
+		},
+		{
+			name:     "authorized public",
+			response: response{cacheControl: "public, max-age=60"},
+			requests: []request{
+				{method: http.MethodGet, header: map[string]string{"Authorization": "Bearer a"}, cache: "BYPASS", body: "call 1"},
+				{method: http.MethodGet, header: map[string]string{"Authorization": "Bearer b"}, cache: "BYPASS", body: "call 2"},
+				get("HIT", "call 2"),
+			},
+		},
+		{
+			name:     "vary",
+			cfg:      Config{Vary: []string{"Accept-Language"}},
+			response: response{cacheControl: "max-age=60"},
+			requests: []request{
+				{method: http.MethodGet, header: map[string]string{"Accept-Language": "en"}, cache: "MISS", body: "call 1"},
+				{method: http.MethodGet, header: map[string]string{"Accept-Language": "ko"}, cache: "MISS", body: "call 2"},
+				{method: http.MethodGet, header: map[string]string{"Accept-Language": "en"}, cache: "HIT", body: "call 1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			l := newCachedServer(tt.cfg, tt.response, &calls)
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(req.method, "/item", nil)
+				for key, value := range req.header {
+					r.Header.Set(key, value)
+				}
+				w := httptest.NewRecorder()
+				l.ServeHTTP(w, r)
+				if got := w.Header().Get("X-Cache"); got != req.cache {
+					t.Errorf("request %d: X-Cache = %q, want %q", i, got, req.cache)
+				}
+				if req.body != "" && w.Body.String() != req.body {
+					t.Errorf("request %d: body = %q, want %q", i, w.Body.String(), req.body)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	var calls int32
+	c := New(Config{})
+	revalidated := make(chan struct{})
+	l := lux.New()
+	l.AddRestController("/item", controller.GET, c.Wrap(controller.RestController{
+		Handler: func(lc *context.LuxContext) error {
+			defer close(revalidated)
+			atomic.AddInt32(&calls, 1)
+			lc.Response.Header().Set("Cache-Control", "max-age=60")
+			return lc.ReplyString("fresh")
+		},
+	}))
+
+	key := c.key(httptest.NewRequest(http.MethodGet, "/item", nil))
+	now := time.Now()
+	c.cfg.Store.Set(key, &Entry{
+		StatusCode: http.StatusOK,
+		Headers:    http.Header{"Content-Type": {"text/plain"}},
+		Body:       []byte("stale"),
+		StoredAt:   now.Add(-time.Minute),
+		FreshUntil: now.Add(-time.Second),
+		StaleUntil: now.Add(time.Minute),
+	})
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item", nil))
+	if w.Header().Get("X-Cache") != "STALE" || w.Body.String() != "stale" {
+		t.Fatalf("got %q %q, want the stale entry", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	if age, _ := strconv.Atoi(w.Header().Get("Age")); age < 59 {
+		t.Errorf("Age = %d", age)
+	}
+
+	select {
+	case <-revalidated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry was not revalidated")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if entry, ok := c.cfg.Store.Get(key); ok && string(entry.Body) == "fresh" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("revalidated entry was not stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("handler ran %d times", calls)
+	}
+}
+
+func TestCacheCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := New(Config{})
+	l := lux.New()
+	l.AddRestController("/item", controller.GET, c.Wrap(controller.RestController{
+		Handler: func(lc *context.LuxContext) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			lc.Response.Header().Set("Cache-Control", "max-age=60")
+			return lc.ReplyString("shared")
+		},
+	}))
+
+	const clients = 8
+	results := make(chan *httptest.ResponseRecorder, clients)
+	wg := sync.WaitGroup{}
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item", nil))
+			results <- w
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	statuses := map[string]int{}
+	for w := range results {
+		if w.Body.String() != "shared" {
+			t.Errorf("body = %q", w.Body.String())
+		}
+		statuses[w.Header().Get("X-Cache")]++
+	}
+	if calls != 1 || statuses["MISS"] != 1 || statuses["HIT"] != clients-1 {
+		t.Errorf("handler ran %d times, X-Cache %v", calls, statuses)
+	}
+}
+
+func TestCacheTags(t *testing.T) {
+	var calls int32
+	c := New(Config{})
+	l := lux.New()
+	l.AddRestController("/item", controller.GET, c.Wrap(controller.RestController{
+		Handler: func(lc *context.LuxContext) error {
+			n := atomic.AddInt32(&calls, 1)
+			lc.Response.Header().Set("Cache-Control", "max-age=60")
+			c.Tag(lc, "items", "item:1")
+			return lc.ReplyString(strconv.Itoa(int(n)))
+		},
+	}))
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item", nil))
+		return w
+	}
+
+	if w := get(); w.Header().Get(DefaultTagHeader) != "" {
+		t.Errorf("tag header leaked: %q", w.Header().Get(DefaultTagHeader))
+	}
+	tests := []struct {
+		tags    []string
+		removed int
+		body    string
+	}{
+		{[]string{"other"}, 0, "1"},
+		{[]string{"item:1"}, 1, "2"},
+		{[]string{"items", "item:1"}, 1, "3"},
+	}
+	for _, tt := range tests {
+		if removed := c.InvalidateTags(tt.tags...); removed != tt.removed {
+			t.Errorf("InvalidateTags(%q) = %d, want %d", tt.tags, removed, tt.removed)
+		}
+		if w := get(); w.Body.String() != tt.body {
+			t.Errorf("after InvalidateTags(%q): body = %q, want %q", tt.tags, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestStoredHeaders(t *testing.T) {
+	header := http.Header{
+		"Content-Type":  {"text/plain"},
+		"Connection":    {"X-Hop"},
+		"X-Hop":         {"1"},
+		"X-Request-Id":  {"abc"},
+		"Date":          {"now"},
+		"X-Cors":        {"origin"},
+		"Cache-Control": {"max-age=60"},
+	}
+	stored := storedHeaders(header, map[string]struct{}{"X-Cors": {}})
+	want := []string{"Cache-Control", "Content-Type"}
+	if len(stored) != len(want) {
+		t.Errorf("stored = %v, want only %q", stored, want)
+	}
+	for _, name := range want {
+		if stored.Get(name) == "" {
+			t.Errorf("%s was not stored", name)
+		}
+	}
+}
+
+func TestLRUStore(t *testing.T) {
+	entry := func(body string, tags ...string) *Entry {
+		return &Entry{Body: []byte(body), Tags: tags, StaleUntil: time.Now().Add(time.Minute)}
+	}
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int64
+		keys       []string
+		touch      string
+		want       []string
+	}{
+		{"unlimited", 0, 0, []string{"a", "b", "c"}, "", []string{"a", "b", "c"}},
+		{"entries", 2, 0, []string{"a", "b", "c"}, "", []string{"b", "c"}},
+		{"recently used", 2, 0, []string{"a", "b", "c"}, "a", []string{"a", "c"}},
+		{"bytes", 0, 8, []string{"a", "b", "c"}, "", []string{"b", "c"}},
+		{"entry above the limit", 0, 2, []string{"a"}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewLRUStore(tt.maxEntries, tt.maxBytes)
+			for i, key := range tt.keys {
+				s.Set(key, entry("body"))
+				if i == 1 && tt.touch != "" {
+					s.Get(tt.touch)
+				}
+			}
+			var got []string
+			for _, key := range tt.keys {
+				if _, ok := s.Get(key); ok {
+					got = append(got, key)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || s.Len() != len(tt.want) {
+				t.Errorf("kept %q (%d), want %q", got, s.Len(), tt.want)
+			}
+		})
+	}
+
+	s := NewLRUStore(0, 0)
+	s.Set("expired", &Entry{StaleUntil: time.Now().Add(-time.Second)})
+	s.Set("tagged", entry("x", "t"))
+	s.Set("tagged", entry("x"))
+	if _, ok := s.Get("expired"); ok {
+		t.Error("expired entry was returned")
+	}
+	if removed := s.DeleteTags("t"); removed != 0 {
+		t.Errorf("replaced entry kept its old tags: %d removed", removed)
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Entry struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+	Tags       []string
+	StoredAt   time.Time
+	FreshUntil time.Time
+	StaleUntil time.Time
+}
+
+func (e *Entry) size() int64 {
+	size := int64(len(e.Body))
+	for key, values := range e.Headers {
+		size += int64(len(key))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+// Store keeps cached entries. Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+	Delete(key string)
+	// DeleteTags removes every entry carrying one of tags and returns how many were removed.
+	DeleteTags(tags ...string) int
+	Clear()
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+}
+
+type LRUStore struct {
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	order      *list.List
+	items      map[string]*list.Element
+	tags       map[string]map[string]struct{}
+	lock       sync.Mutex
+}
+
+// NewLRUStore keeps at most maxEntries entries and maxBytes of headers and bodies,
+// evicting the least recently used first. Zero disables a limit.
+func NewLRUStore(maxEntries int, maxBytes int64) *LRUStore {
+	return &LRUStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *LRUStore) Get(key string) (*Entry, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruItem).entry
+	if time.Now().After(entry.StaleUntil) {
+		s.remove(elem)
+		return nil, false
+	}
+
+	s.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (s *LRUStore) Set(key string, entry *Entry) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+
+	size := entry.size()
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return
+	}
+
+	s.items[key] = s.order.PushFront(&lruItem{key: key, entry: entry})
+	s.bytes += size
+	for _, tag := range entry.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+
+	for (s.maxEntries > 0 && s.order.Len() > s.maxEntries) || (s.maxBytes > 0 && s.bytes > s.maxBytes) {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *LRUStore) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+}
+
+func (s *LRUStore) DeleteTags(tags ...string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	removed := 0
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if elem, ok := s.items[key]; ok {
+				s.remove(elem)
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+func (s *LRUStore) Clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.order.Init()
+	s.items = make(map[string]*list.Element)
+	s.tags = make(map[string]map[string]struct{})
+	s.bytes = 0
+}
+
+func (s *LRUStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.order.Len()
+}
+
+func (s *LRUStore) remove(elem *list.Element) {
+	item := elem.Value.(*lruItem)
+	s.order.Remove(elem)
+	delete(s.items, item.key)
+	s.bytes -= item.entry.size()
+	for _, tag := range item.entry.Tags {
+		delete(s.tags[tag], item.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package config loads typed configuration structs from YAML, TOML and JSON files,
+// environment variables and command line flags, e.g.
+//
+//	type DatabaseConfig struct {
+//		URL      string        `config:"url" validate:"required"`
+//		Password string        `config:"password"`
+//		Timeout  time.Duration `config:"timeout" default:"5s" validate:"min=1s"`
+//	}
+//
+//	loader := config.New()
+//	config.SetFiles(loader, "config.yaml")
+//	config.SetEnvPrefix(loader, "APP")
+//	p.Register(config.Provide[DatabaseConfig](loader, "database"))
+//
+// Later sources override earlier ones: defaults, then each file in order, then environment
+// variables, then flags. Above, Timeout is read from the key database.timeout of the files,
+// from APP_DATABASE_TIMEOUT and from --database.timeout. A variable suffixed with _FILE, e.g.
+// APP_DATABASE_PASSWORD_FILE, names a file to read the value from, for secrets. With
+// SetFileFlag, a flag or variable such as --config or APP_CONFIG picks the file at run time.
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Loader knows where configuration comes from. Each Load reads the sources again.
+type Loader struct {
+	files     []string
+	fileFlag  string
+	fileEnv   string
+	envPrefix string
+	args      []string
+	lookupEnv func(string) (string, bool)
+}
+
+func New() *Loader {
+	return &Loader{
+		args:      os.Args[1:],
+		lookupEnv: os.LookupEnv,
+	}
+}
+
+// SetFiles sets the files to read, in order, each in the format its extension tells: .yaml,
+// .yml, .toml or .json. Files that do not exist are skipped.
+func SetFiles(l *Loader, paths ...string) {
+	l.files = paths
+}
+
+// SetFileFlag lets the flag --name or the environment variable env name the file to read in
+// place of those of SetFiles, e.g. --config or APP_CONFIG. A file named so must exist.
+func SetFileFlag(l *Loader, name string, env string) {
+	l.fileFlag = name
+	l.fileEnv = env
+}
+
+// SetEnvPrefix prefixes the environment variables read, e.g. APP for APP_SERVER_PORT.
+func SetEnvPrefix(l *Loader, prefix string) {
+	l.envPrefix = strings.TrimSuffix(prefix, "_")
+}
+
+// SetArgs sets the command line arguments flags are read from, os.Args[1:] by default.
+// Arguments that are not flags of the loaded struct are ignored.
+func SetArgs(l *Loader, args []string) {
+	l.args = args
+}
+
+// SetLookupEnv replaces os.LookupEnv, e.g. in tests.
+func SetLookupEnv(l *Loader, lookupEnv func(string) (string, bool)) {
+	l.lookupEnv = lookupEnv
+}
+
+// Load returns a T read from section, a dotted path such as "server" or "" for the root,
+// and validated.
+func Load[T any](l *Loader, section string) (T, error) {
+	var t T
+	err := l.Load(&t, section)
+	return t, err
+}
+
+// Provide returns a constructor of the T read from section, to register with a provider,
+// so that constructors can depend on T.
+func Provide[T any](l *Loader, section string) func() (T, error) {
+	return func() (T, error) {
+		return Load[T](l, section)
+	}
+}
+
+// Load fills target, a pointer to a struct, from section and validates it.
+func (l *Loader) Load(target any, section string) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrNotStruct{Type: reflect.TypeOf(target)}
+	}
+
+	path := []string(nil)
+	if section != "" {
+		path = strings.Split(section, ".")
+	}
+	fields := fieldsOf(v.Elem(), path)
+
+	errs := []error(nil)
+	for _, f := range fields {
+		if def, ok := f.tag.Lookup("default"); ok {
+			errs = append(errs, f.setString(def, "default"))
+		}
+	}
+	files, named := l.filesToRead()
+	for _, file := range files {
+		tree, err := readFile(file, named)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if x, ok := lookup(tree, f.path); ok {
+				errs = append(errs, f.set(x, file))
+			}
+		}
+	}
+	for _, f := range fields {
+		errs = append(errs, l.fromEnv(f))
+		if s, ok := lookupFlag(l.args, f.flag(), f.isBool()); ok {
+			errs = append(errs, f.setString(s, "flag --"+f.flag()))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		errs = append(errs, f.validate()...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	return errors.Join(validateStructs(v.Elem(), path)...)
+}
+
+// filesToRead returns the file named by the file flag or variable, if set, or else the files
+// of SetFiles. It reports whether the file was named.
+func (l *Loader) filesToRead() ([]string, bool) {
+	if l.fileFlag != "" {
+		if path, ok := lookupFlag(l.args, l.fileFlag, false); ok {
+			return []string{path}, true
+		}
+	}
+	if l.fileEnv != "" {
+		if path, ok := l.lookupEnv(l.fileEnv); ok && path != "" {
+			return []string{path}, true
+		}
+	}
+	return l.files, false
+}
+
+type ErrNotStruct struct {
+	Type reflect.Type
+}
+
+func (e ErrNotStruct) Error() string {
+	return "config: " + typeString(e.Type) + " is not a pointer to a struct"
+}
+
+func typeString(t reflect.Type) string {
+	if t == nil {
+		return "nil"
+	}
+	return t.String()
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+type serverConfig struct {
+	Port        int           `config:"port" default:"8080" validate:"min=1,max=65535"`
+	Host        string        `default:"localhost"`
+	ReadTimeout time.Duration `default:"5s"`
+	Debug       bool
+	Tags        []string
+	Password    string
+	Limits      map[string]int
+}
+
+func TestLoad(t *testing.T) {
+	defaults := serverConfig{Port: 8080, Host: "localhost", ReadTimeout: 5 * time.Second}
+	with := func(change func(c *serverConfig)) serverConfig {
+		c := defaults
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		env      map[string]string
+		args     []string
+		fileFlag bool
+		want     serverConfig
+		err      any
+	}{
+		{
+			name: "defaults",
+			want: defaults,
+		},
+		{
+			name:  "yaml",
+			files: map[string]string{"a.yaml": "server:\n  port: 9000\n  read-timeout: 1s\n  tags: [a, b]\n  limits: {rps: 10}\n"},
+			want: with(func(c *serverConfig) {
+				c.Port, c.ReadTimeout, c.Tags, c.Limits = 9000, time.Second, []string{"a", "b"}, map[string]int{"rps": 10}
+			}),
+		},
+		{
+			name:  "later files override earlier ones",
+			files: map[string]string{"a.yaml": "server:\n  port: 9000\n  host: yaml\n", "b.json": `{"server": {"port": 9001}}`},
+			want:  with(func(c *serverConfig) { c.Port, c.Host = 9001, "yaml" }),
+		},
+		{
+			name:  "toml",
+			files: map[string]string{"a.toml": "[server]\nport = 9002\ndebug = true\n"},
+			want:  with(func(c *serverConfig) { c.Port, c.Debug = 9002, true }),
+		},
+		{
+			name:  "environment over files",
+			files: map[string]string{"a.yaml": "server:\n  port: 9000\n"},
+			env:   map[string]string{"APP_SERVER_PORT": "9100", "APP_SERVER_TAGS": "x,y"},
+			want:  with(func(c *serverConfig) { c.Port, c.Tags = 9100, []string{"x", "y"} }),
+		},
+		{
+			name: "flags over environment",
+			env:  map[string]string{"APP_SERVER_PORT": "9100"},
+			args: []string{"serve", "--server.port", "9200", "--server.read-timeout=2s", "--server.debug"},
+			want: with(func(c *serverConfig) { c.Port, c.ReadTimeout, c.Debug = 9200, 2*time.Second, true }),
+		},
+		{
+			name:  "secret from a file",
+			files: map[string]string{"password": "s3cret\n"},
+			env:   map[string]string{"APP_SERVER_PASSWORD_FILE": "password"},
+			want:  with(func(c *serverConfig) { c.Password = "s3cret" }),
+		},
+		{
+			name: "secret twice",
+			env:  map[string]string{"APP_SERVER_PASSWORD": "a", "APP_SERVER_PASSWORD_FILE": "password"},
+			err:  &ErrInvalidValue{},
+		},
+		{
+			name: "secret file missing",
+			env:  map[string]string{"APP_SERVER_PASSWORD_FILE": "password"},
+			err:  &ErrInvalidValue{},
+		},
+		{
+			name: "invalid value",
+			env:  map[string]string{"APP_SERVER_PORT": "http"},
+			err:  &ErrInvalidValue{},
+		},
+		{
+			name: "invalid",
+			args: []string{"--server.port=0"},
+			err:  &ErrValidation{},
+		},
+		{
+			name:     "file named by flag",
+			files:    map[string]string{"a.yaml": "server:\n  port: 9000\n", "c.json": `{"server": {"port": 9300}}`},
+			args:     []string{"--config", "c.json"},
+			fileFlag: true,
+			want:     with(func(c *serverConfig) { c.Port = 9300 }),
+		},
+		{
+			name:     "file named by environment",
+			files:    map[string]string{"c.json": `{"server": {"port": 9300}}`},
+			env:      map[string]string{"APP_CONFIG": "c.json"},
+			fileFlag: true,
+			want:     with(func(c *serverConfig) { c.Port = 9300 }),
+		},
+		{
+			name:     "named file missing",
+			args:     []string{"--config=missing.yaml"},
+			fileFlag: true,
+			err:      new(error),
+		},
+		{
+			name:  "unsupported file",
+			files: map[string]string{"a.ini": "port=1"},
+			err:   &ErrUnsupportedFormat{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := []string{"missing.yaml"}
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if name != "password" {
+					files = append(files, path)
+				}
+			}
+			// map iteration is random, files are read in the order of their names
+			sort.Strings(files)
+
+			l := New()
+			SetFiles(l, files...)
+			SetEnvPrefix(l, "APP_")
+			SetArgs(l, prefixPaths(dir, tt.args))
+			SetLookupEnv(l, func(name string) (string, bool) {
+				v, ok := tt.env[name]
+				if ok && (name == "APP_SERVER_PASSWORD_FILE" || name == "APP_CONFIG") {
+					v = filepath.Join(dir, v)
+				}
+				return v, ok
+			})
+			if tt.fileFlag {
+				SetFileFlag(l, "config", "APP_CONFIG")
+			}
+
+			got, err := Load[serverConfig](l, "server")
+			if tt.err != nil {
+				if !errors.As(err, tt.err) {
+					t.Errorf("Load = %v, want %T", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// prefixPaths puts dir before the value of --config in args.
+func prefixPaths(dir string, args []string) []string {
+	args = append([]string(nil), args...)
+	for i, arg := range args {
+		switch {
+		case arg == "--config" && i+1 < len(args):
+			args[i+1] = filepath.Join(dir, args[i+1])
+		case len(arg) > len("--config=") && arg[:len("--config=")] == "--config=":
+			args[i] = "--config=" + filepath.Join(dir, arg[len("--config="):])
+		}
+	}
+	return args
+}
+
+func TestLoadSection(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "port: 1\nserver:\n  port: 2\n  api:\n    port: 3\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		section string
+		port    int
+	}{
+		{"", 1},
+		{"server", 2},
+		{"server.api", 3},
+		{"other", 8080},
+	}
+	for _, tt := range tests {
+		l := New()
+		SetFiles(l, path)
+		SetArgs(l, nil)
+		SetLookupEnv(l, func(string) (string, bool) { return "", false })
+		got, err := Load[serverConfig](l, tt.section)
+		if err != nil || got.Port != tt.port {
+			t.Errorf("Load(%q) = %d, %v, want %d", tt.section, got.Port, err, tt.port)
+		}
+	}
+}
+
+func TestLoadNotStruct(t *testing.T) {
+	l := New()
+	port := 0
+	for _, target := range []any{nil, port, &port, (*serverConfig)(nil)} {
+		if err := l.Load(target, ""); !errors.As(err, &ErrNotStruct{}) {
+			t.Errorf("Load(%T) = %v, want ErrNotStruct", target, err)
+		}
+	}
+}
+
+func TestProvide(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		port int
+		err  bool
+	}{
+		{"valid", map[string]string{"SERVER_PORT": "9000"}, 9000, false},
+		{"invalid", map[string]string{"SERVER_PORT": "0"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New()
+			SetArgs(l, nil)
+			SetLookupEnv(l, func(name string) (string, bool) {
+				v, ok := tt.env[name]
+				return v, ok
+			})
+
+			p := provider.New()
+			if err := p.Register(Provide[serverConfig](l, "server")); err != nil {
+				t.Fatal(err)
+			}
+			err := p.Construct(context.Background())
+			if (err != nil) != tt.err {
+				t.Fatalf("Construct = %v, want error %v", err, tt.err)
+			}
+			if got, _ := provider.Get[serverConfig](p); got.Port != tt.port {
+				t.Errorf("port = %d, want %d", got.Port, tt.port)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// field is a value of the loaded struct read from the sources: a field whose type is not a
+// struct, or a struct that reads itself from text, like time.Time.
+type field struct {
+	value reflect.Value
+	tag   reflect.StructTag
+	path  []string
+	name  string
+}
+
+// fieldsOf lists the fields of v, a struct, under path. Nested structs add their key to the
+// path, embedded ones do not. A field is keyed by its config tag or else its name in
+// snake_case, and skipped when the tag is "-".
+func fieldsOf(v reflect.Value, path []string) []*field {
+	fields := []*field(nil)
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		key := sf.Tag.Get("config")
+		if key == "-" || !sf.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = snakeCase(sf.Name)
+		}
+
+		fieldPath := append(append([]string(nil), path...), key)
+		if sf.Anonymous && sf.Tag.Get("config") == "" {
+			fieldPath = path
+		}
+
+		if sf.Type.Kind() == reflect.Struct && !readsText(sf.Type) {
+			fields = append(fields, fieldsOf(v.Field(i), fieldPath)...)
+			continue
+		}
+		fields = append(fields, &field{value: v.Field(i), tag: sf.Tag, path: fieldPath, name: strings.Join(fieldPath, ".")})
+	}
+	return fields
+}
+
+func readsText(t reflect.Type) bool {
+	return t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// snakeCase turns a field name into a key, "ReadHeaderTimeout" into "read_header_timeout" and
+// "TLSCertFile" into "tls_cert_file".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	sb := strings.Builder{}
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				sb.WriteRune('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// env is the environment variable of f, its env tag or else its path in upper case
+// after prefix, e.g. APP_SERVER_READ_TIMEOUT.
+func (f *field) env(prefix string) string {
+	if name := f.tag.Get("env"); name != "" {
+		return name
+	}
+	parts := append([]string(nil), f.path...)
+	if prefix != "" {
+		parts = append([]string{prefix}, parts...)
+	}
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.Join(parts, "_")))
+}
+
+// flag is the flag name of f, its flag tag or else its path in kebab case, e.g.
+// server.read-timeout.
+func (f *field) flag() string {
+	if name := f.tag.Get("flag"); name != "" {
+		return name
+	}
+	return strings.ReplaceAll(strings.Join(f.path, "."), "_", "-")
+}
+
+func (f *field) isBool() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+func (f *field) setString(s string, source string) error {
+	if err := parse(f.value, s); err != nil {
+		return ErrInvalidValue{Field: f.name, Source: source, Err: hideValue(err)}
+	}
+	return nil
+}
+
+func (f *field) set(x any, source string) error {
+	if err := assign(f.value, x); err != nil {
+		return ErrInvalidValue{Field: f.name, Source: source, Err: hideValue(err)}
+	}
+	return nil
+}
+
+// quoted matches quoted text, the way strconv, time and parse put what they failed on in
+// their errors.
+var quoted = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// hideValue keeps the value, or the item of it, that failed to parse out of the message of err.
+func hideValue(err error) error {
+	if !quoted.MatchString(err.Error()) {
+		return err
+	}
+	return hiddenValueError{err: err}
+}
+
+type hiddenValueError struct {
+	err error
+}
+
+func (e hiddenValueError) Error() string {
+	return quoted.ReplaceAllString(e.err.Error(), "the value")
+}
+
+func (e hiddenValueError) Unwrap() error {
+	return e.err
+}
+
+// parse sets v from text. Slices are comma separated and maps are comma separated
+// key=value pairs, except []byte, which takes the text as it is.
+func parse(v reflect.Value, s string) error {
+	if v.Kind() != reflect.Pointer && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		items := []string(nil)
+		if strings.TrimSpace(s) != "" {
+			items = strings.Split(s, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := parse(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(s, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			k, e, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			if err := setEntry(m, strings.TrimSpace(k), strings.TrimSpace(e)); err != nil {
+				return err
+			}
+		}
+		v.Set(m)
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		if err := parse(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// assign sets v from a value decoded from a file.
+func assign(v reflect.Value, x any) error {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case string:
+		return parse(v, x)
+	case json.Number:
+		return parse(v, x.String())
+	case float64:
+		return parse(v, strconv.FormatFloat(x, 'f', -1, 64))
+	case map[string]any:
+		return assignMap(v, x)
+	case []any:
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot set %s from a list", v.Type())
+		}
+		slice := reflect.MakeSlice(v.Type(), len(x), len(x))
+		for i, item := range x {
+			if err := assign(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	if rx := reflect.ValueOf(x); rx.Type().AssignableTo(v.Type()) {
+		v.Set(rx)
+		return nil
+	}
+	return parse(v, fmt.Sprint(x))
+}
+
+// assignMap sets v, a map or a struct in a list or map, from a table of a file.
+func assignMap(v reflect.Value, x map[string]any) error {
+	switch v.Kind() {
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for k, e := range x {
+			if err := setEntry(m, k, e); err != nil {
+				return err
+			}
+		}
+		v.Set(m)
+		return nil
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		if err := assignMap(p.Elem(), x); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Struct:
+		for _, f := range fieldsOf(v, nil) {
+			if def, ok := f.tag.Lookup("default"); ok {
+				if err := parse(f.value, def); err != nil {
+					return fmt.Errorf("%s: %w", f.name, err)
+				}
+			}
+			if e, ok := lookup(x, f.path); ok {
+				if err := assign(f.value, e); err != nil {
+					return fmt.Errorf("%s: %w", f.name, err)
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot set %s from a table", v.Type())
+}
+
+// setEntry sets the entry k of m, from text or a decoded value.
+func setEntry(m reflect.Value, k string, e any) error {
+	key := reflect.New(m.Type().Key()).Elem()
+	if err := parse(key, k); err != nil {
+		return err
+	}
+	elem := reflect.New(m.Type().Elem()).Elem()
+	if err := assign(elem, e); err != nil {
+		return err
+	}
+	m.SetMapIndex(key, elem)
+	return nil
+}
+
+// ErrInvalidValue is returned when a source has a value that does not fit its field. It
+// leaves the value out, as it may be a secret.
+type ErrInvalidValue struct {
+	Field  string
+	Source string
+	Err    error
+}
+
+func (e ErrInvalidValue) Error() string {
+	return fmt.Sprintf("config: invalid %s from %s: %v", e.Field, e.Source, e.Err)
+}
+
+func (e ErrInvalidValue) Unwrap() error {
+	return e.Err
+}
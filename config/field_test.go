@@ -0,0 +1,182 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Port", "port"},
+		{"ReadHeaderTimeout", "read_header_timeout"},
+		{"TLSCertFile", "tls_cert_file"},
+		{"URL", "url"},
+		{"UserID", "user_id"},
+		{"HTTP2Enabled", "http2_enabled"},
+		{"Level3", "level3"},
+	}
+	for _, tt := range tests {
+		if got := snakeCase(tt.name); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+type fieldNames struct {
+	Port     int
+	Timeout  time.Duration `config:"read-timeout"`
+	Secret   string        `env:"SECRET" flag:"secret"`
+	Skipped  string        `config:"-"`
+	internal string
+	TLS      struct {
+		CertFile string
+	}
+	Embedded
+	Named Embedded `config:"named"`
+}
+
+type Embedded struct {
+	Level string
+}
+
+func TestFieldNames(t *testing.T) {
+	want := []struct {
+		name string
+		env  string
+		flag string
+	}{
+		{"server.port", "APP_SERVER_PORT", "server.port"},
+		{"server.read-timeout", "APP_SERVER_READ_TIMEOUT", "server.read-timeout"},
+		{"server.secret", "SECRET", "secret"},
+		{"server.tls.cert_file", "APP_SERVER_TLS_CERT_FILE", "server.tls.cert-file"},
+		{"server.level", "APP_SERVER_LEVEL", "server.level"},
+		{"server.named.level", "APP_SERVER_NAMED_LEVEL", "server.named.level"},
+	}
+
+	v := fieldNames{}
+	fields := fieldsOf(reflect.ValueOf(&v).Elem(), []string{"server"})
+	if len(fields) != len(want) {
+		t.Fatalf("%d fields, want %d", len(fields), len(want))
+	}
+	for i, f := range fields {
+		if f.name != want[i].name || f.env("APP") != want[i].env || f.flag() != want[i].flag {
+			t.Errorf("field %d = %s %s %s, want %+v", i, f.name, f.env("APP"), f.flag(), want[i])
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		into any
+		want any
+		err  bool
+	}{
+		{"string", "hello", new(string), "hello", false},
+		{"bool", "true", new(bool), true, false},
+		{"bad bool", "maybe", new(bool), false, true},
+		{"int", "-42", new(int), -42, false},
+		{"hex int", "0x10", new(int), 16, false},
+		{"int overflow", "300", new(int8), int8(0), true},
+		{"uint", "7", new(uint16), uint16(7), false},
+		{"negative uint", "-1", new(uint), uint(0), true},
+		{"float", "1.5", new(float64), 1.5, false},
+		{"duration", "1m30s", new(time.Duration), 90 * time.Second, false},
+		{"bad duration", "soon", new(time.Duration), time.Duration(0), true},
+		{"bytes", "a,b", new([]byte), []byte("a,b"), false},
+		{"slice", "a, b ,c", new([]string), []string{"a", "b", "c"}, false},
+		{"empty slice", " ", new([]string), []string{}, false},
+		{"int slice", "1,2", new([]int), []int{1, 2}, false},
+		{"map", "a=1, b=2", new(map[string]int), map[string]int{"a": 1, "b": 2}, false},
+		{"not a pair", "a", new(map[string]int), map[string]int(nil), true},
+		{"pointer", "3", new(*int), func() *int { i := 3; return &i }(), false},
+		{"text unmarshaler", "127.0.0.1", new(net.IP), net.ParseIP("127.0.0.1"), false},
+		{"time", "2024-01-02T03:04:05Z", new(time.Time), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), false},
+		{"unsupported", "x", new(chan int), (chan int)(nil), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := reflect.ValueOf(tt.into).Elem()
+			err := parse(v, tt.text)
+			if (err != nil) != tt.err {
+				t.Fatalf("parse(%q) = %v, want error %v", tt.text, err, tt.err)
+			}
+			if !reflect.DeepEqual(v.Interface(), tt.want) {
+				t.Errorf("parse(%q) = %#v, want %#v", tt.text, v.Interface(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	type item struct {
+		Name string
+		Size int `default:"1"`
+	}
+	tests := []struct {
+		name    string
+		decoded any
+		into    any
+		want    any
+		err     bool
+	}{
+		{"string", "x", new(string), "x", false},
+		{"yaml int", 8080, new(int), 8080, false},
+		{"json number", float64(1.5), new(float64), 1.5, false},
+		{"number to string", 42, new(string), "42", false},
+		{"list", []any{"a", "b"}, new([]string), []string{"a", "b"}, false},
+		{"list to a scalar", []any{"a"}, new(string), "", true},
+		{"table", map[string]any{"a": 1}, new(map[string]int), map[string]int{"a": 1}, false},
+		{"table to a scalar", map[string]any{"a": 1}, new(int), 0, true},
+		{"list of tables", []any{map[string]any{"name": "x"}, map[string]any{"name": "y", "size": 2}}, new([]item), []item{{"x", 1}, {"y", 2}}, false},
+		{"table of pointers", map[string]any{"a": map[string]any{"name": "x"}}, new(map[string]*item), map[string]*item{"a": {"x", 1}}, false},
+		{"nil", nil, new(int), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := reflect.ValueOf(tt.into).Elem()
+			err := assign(v, tt.decoded)
+			if (err != nil) != tt.err {
+				t.Fatalf("assign(%v) = %v, want error %v", tt.decoded, err, tt.err)
+			}
+			if !reflect.DeepEqual(v.Interface(), tt.want) {
+				t.Errorf("assign(%v) = %#v, want %#v", tt.decoded, v.Interface(), tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidValueHidesValue(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		into any
+	}{
+		{"int", "hunter2", new(int)},
+		{"duration", "hunter2", new(time.Duration)},
+		{"slice item", "1,hunter2", new([]int)},
+		{"map pair", "hunter2", new(map[string]string)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &field{value: reflect.ValueOf(tt.into).Elem(), name: "secret"}
+			err := f.setString(tt.text, "environment variable SECRET")
+			if err == nil {
+				t.Fatal("setString = nil, want an error")
+			}
+			if strings.Contains(err.Error(), "hunter2") {
+				t.Errorf("error %q shows the value", err)
+			}
+			if !strings.HasPrefix(err.Error(), "config: invalid secret from environment variable SECRET: ") {
+				t.Errorf("error = %q", err)
+			}
+		})
+	}
+}
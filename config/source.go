@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile decodes a configuration file into nested maps, or returns nil when it does not exist
+// and is not required.
+func readFile(path string, required bool) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read %s: %w", path, err)
+	}
+
+	tree := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	default:
+		return nil, ErrUnsupportedFormat{Path: path}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to decode %s: %w", path, err)
+	}
+	return tree, nil
+}
+
+// lookup finds the value at path in tree. Keys match regardless of case, underscores and
+// dashes, so read_timeout, readTimeout and read-timeout are the same key.
+func lookup(tree map[string]any, path []string) (any, bool) {
+	var x any = tree
+	for _, key := range path {
+		m, ok := x.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		x, ok = m[key]
+		if ok {
+			continue
+		}
+		for k, v := range m {
+			if normalize(k) == normalize(key) {
+				x, ok = v, true
+				break
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return x, true
+}
+
+func normalize(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// fromEnv sets f from its environment variable, or from the file its _FILE variable names.
+func (l *Loader) fromEnv(f *field) error {
+	name := f.env(l.envPrefix)
+	s, ok := l.lookupEnv(name)
+	path, fromFile := l.lookupEnv(name + "_FILE")
+	switch {
+	case ok && fromFile:
+		return ErrInvalidValue{Field: f.name, Source: "environment", Err: fmt.Errorf("both %s and %s_FILE are set", name, name)}
+	case ok:
+		return f.setString(s, "environment variable "+name)
+	case fromFile:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ErrInvalidValue{Field: f.name, Source: "environment variable " + name + "_FILE", Err: err}
+		}
+		return f.setString(strings.TrimRight(string(data), "\r\n"), path)
+	}
+	return nil
+}
+
+// lookupFlag returns the value of the last --name=value or --name value in args, a single
+// dash working as well. A boolean flag without =value is true.
+func lookupFlag(args []string, name string, isBool bool) (string, bool) {
+	value, found := "", false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if k, v, ok := strings.Cut(arg, "="); ok {
+			if k == name {
+				value, found = v, true
+			}
+			continue
+		}
+		if arg != name {
+			continue
+		}
+		switch {
+		case isBool:
+			value, found = "true", true
+		case i+1 < len(args):
+			value, found = args[i+1], true
+			i++
+		}
+	}
+	return value, found
+}
+
+type ErrUnsupportedFormat struct {
+	Path string
+}
+
+func (e ErrUnsupportedFormat) Error() string {
+	return "config: unsupported format of " + e.Path + ", must be .yaml, .yml, .toml or .json"
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		flag   string
+		isBool bool
+		want   string
+		found  bool
+	}{
+		{"missing", []string{"--other=1"}, "port", false, "", false},
+		{"equals", []string{"--port=80"}, "port", false, "80", true},
+		{"separate", []string{"--port", "80"}, "port", false, "80", true},
+		{"single dash", []string{"-port=80"}, "port", false, "80", true},
+		{"last wins", []string{"--port=80", "--port", "81"}, "port", false, "81", true},
+		{"empty value", []string{"--port="}, "port", false, "", true},
+		{"no value", []string{"--port"}, "port", false, "", false},
+		{"bool", []string{"--debug", "serve"}, "debug", true, "true", true},
+		{"bool false", []string{"--debug=false"}, "debug", true, "false", true},
+		{"after terminator", []string{"--", "--port=80"}, "port", false, "", false},
+		{"positional", []string{"port"}, "port", false, "", false},
+		{"dotted", []string{"--server.read-timeout=1s"}, "server.read-timeout", false, "1s", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := lookupFlag(tt.args, tt.flag, tt.isBool)
+			if got != tt.want || found != tt.found {
+				t.Errorf("lookupFlag(%q, %q) = %q, %v, want %q, %v", tt.args, tt.flag, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tree := map[string]any{
+		"server": map[string]any{
+			"read_timeout": "1s",
+			"TLS":          map[string]any{"cert-file": "cert.pem"},
+		},
+		"port": 80,
+	}
+	tests := []struct {
+		path  string
+		want  any
+		found bool
+	}{
+		{"port", 80, true},
+		{"server.read_timeout", "1s", true},
+		{"server.readTimeout", "1s", true},
+		{"server.read-timeout", "1s", true},
+		{"server.tls.cert_file", "cert.pem", true},
+		{"server.missing", nil, false},
+		{"port.nested", nil, false},
+	}
+	for _, tt := range tests {
+		got, found := lookup(tree, strings.Split(tt.path, "."))
+		if found != tt.found || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookup(%s) = %v, %v, want %v, %v", tt.path, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		content  string
+		required bool
+		port     string
+		err      any
+	}{
+		{name: "config.yaml", content: "server:\n  port: 80\n", port: "80"},
+		{name: "config.yml", content: "server: {port: 81}\n", port: "81"},
+		{name: "config.toml", content: "[server]\nport = 82\n", port: "82"},
+		{name: "config.json", content: `{"server": {"port": 83}}`, port: "83"},
+		{name: "config.ini", content: "port=84", err: &ErrUnsupportedFormat{}},
+		{name: "broken.json", content: `{"server":`, err: new(error)},
+		{name: "missing.yaml"},
+		{name: "required.yaml", required: true, err: new(error)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tree, err := readFile(path, tt.required)
+			if tt.err != nil {
+				if !errors.As(err, tt.err) {
+					t.Errorf("readFile = %v, want %T", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			port := ""
+			if x, ok := lookup(tree, []string{"server", "port"}); ok {
+				port = fmt.Sprint(x)
+			}
+			if port != tt.port {
+				t.Errorf("port = %q, want %q", port, tt.port)
+			}
+		})
+	}
+}
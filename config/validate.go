@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validate checks f against the rules of its validate tag, separated by commas:
+//
+//	required    the value must not be zero
+//	min=N       numbers and durations must be at least N, strings, slices and maps have
+//	            at least N elements
+//	max=N       like min, for at most N
+//	oneof=a b   the value must be one of the space separated values
+func (f *field) validate() []error {
+	tag := f.tag.Get("validate")
+	if tag == "" {
+		return nil
+	}
+
+	errs := []error(nil)
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		var err error
+		switch name {
+		case "required":
+			if f.value.IsZero() {
+				err = ErrValidation{Field: f.name, Reason: "is required"}
+			}
+		case "min":
+			err = f.bound(arg, "at least", func(c int) bool { return c >= 0 })
+		case "max":
+			err = f.bound(arg, "at most", func(c int) bool { return c <= 0 })
+		case "oneof":
+			options := strings.Fields(arg)
+			s := fmt.Sprint(f.value.Interface())
+			found := false
+			for _, option := range options {
+				found = found || option == s
+			}
+			if !found {
+				err = ErrValidation{Field: f.name, Reason: "must be one of " + strings.Join(options, ", ")}
+			}
+		default:
+			err = ErrInvalidTag{Field: f.name, Tag: rule}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Validator is implemented by configuration structs with rules spanning several fields. Load
+// calls Validate on the loaded struct and the structs nested in it once their fields are valid.
+type Validator interface {
+	Validate() error
+}
+
+// validateStructs calls Validate on v, a struct under path, and the structs nested in it,
+// innermost first.
+func validateStructs(v reflect.Value, path []string) []error {
+	errs := []error(nil)
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		key := sf.Tag.Get("config")
+		if key == "-" || !sf.IsExported() || sf.Type.Kind() != reflect.Struct || readsText(sf.Type) {
+			continue
+		}
+		if key == "" {
+			key = snakeCase(sf.Name)
+		}
+		fieldPath := append(append([]string(nil), path...), key)
+		if sf.Anonymous && sf.Tag.Get("config") == "" {
+			fieldPath = path
+		}
+		errs = append(errs, validateStructs(v.Field(i), fieldPath)...)
+	}
+
+	validator, ok := v.Addr().Interface().(Validator)
+	if !ok {
+		return errs
+	}
+	if err := validator.Validate(); err != nil {
+		name := strings.Join(path, ".")
+		if name == "" {
+			name = typeString(v.Type())
+		}
+		errs = append(errs, ErrValidation{Field: name, Reason: err.Error()})
+	}
+	return errs
+}
+
+// bound compares f, or its length, with the limit arg, and fails unless ok accepts the result.
+func (f *field) bound(arg string, relation string, ok func(c int) bool) error {
+	v := f.value
+	invalid := ErrInvalidTag{Field: f.name, Tag: f.tag.Get("validate")}
+	failed := ErrValidation{Field: f.name, Reason: "must be " + relation + " " + arg}
+
+	if v.Type() == durationType {
+		limit, err := time.ParseDuration(arg)
+		if err != nil {
+			return invalid
+		}
+		if !ok(compare(float64(v.Int()), float64(limit))) {
+			return failed
+		}
+		return nil
+	}
+
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return invalid
+	}
+	var actual float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = v.Float()
+	case reflect.String, reflect.Slice, reflect.Map:
+		actual = float64(v.Len())
+		failed.Reason = fmt.Sprintf("must have %s %s elements", relation, arg)
+		if v.Kind() == reflect.String {
+			failed.Reason = fmt.Sprintf("must be %s %s characters long", relation, arg)
+			actual = float64(len([]rune(v.String())))
+		}
+	default:
+		return invalid
+	}
+	if !ok(compare(actual, limit)) {
+		return failed
+	}
+	return nil
+}
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type ErrValidation struct {
+	Field  string
+	Reason string
+}
+
+func (e ErrValidation) Error() string {
+	return "config: " + e.Field + " " + e.Reason
+}
+
+type ErrInvalidTag struct {
+	Field string
+	Tag   string
+}
+
+func (e ErrInvalidTag) Error() string {
+	return fmt.Sprintf("config: invalid validate tag %q of %s", e.Tag, e.Field)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		tag   string
+		want  string
+	}{
+		{"required set", "x", "required", ""},
+		{"required zero", 0, "required", "config: f is required"},
+		{"min int", 1, "min=1", ""},
+		{"below min int", 0, "min=1", "config: f must be at least 1"},
+		{"max uint", uint(65535), "max=65535", ""},
+		{"above max uint", uint(65536), "max=65535", "config: f must be at most 65535"},
+		{"min float", 0.5, "min=0.25", ""},
+		{"min duration", time.Second, "min=1s", ""},
+		{"below min duration", time.Millisecond, "min=1s", "config: f must be at least 1s"},
+		{"negative duration", -time.Second, "min=0s", "config: f must be at least 0s"},
+		{"string length", "héllo", "max=5", ""},
+		{"string too long", "hello!", "max=5", "config: f must be at most 5 characters long"},
+		{"slice length", []string{"a"}, "min=2", "config: f must have at least 2 elements"},
+		{"oneof", "info", "oneof=debug info warn", ""},
+		{"not oneof", "trace", "oneof=debug info warn", "config: f must be one of debug, info, warn"},
+		{"several rules", 0, "required, min=1", "config: f is required\nconfig: f must be at least 1"},
+		{"unknown rule", "x", "email", `config: invalid validate tag "email" of f`},
+		{"bad limit", 1, "min=one", `config: invalid validate tag "min=one" of f`},
+		{"bad duration limit", time.Second, "min=1", `config: invalid validate tag "min=1" of f`},
+		{"bound of a bool", true, "min=1", `config: invalid validate tag "min=1" of f`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &field{
+				value: reflect.ValueOf(tt.value),
+				tag:   reflect.StructTag(`validate:"` + tt.tag + `"`),
+				name:  "f",
+			}
+			got := errors.Join(f.validate()...)
+			if got == nil && tt.want != "" || got != nil && got.Error() != tt.want {
+				t.Errorf("validate = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type window struct {
+	From int
+	To   int
+}
+
+func (w window) Validate() error {
+	if w.From > w.To {
+		return errors.New("must not end before it starts")
+	}
+	return nil
+}
+
+type schedule struct {
+	Open   window
+	Closed window `config:"closed"`
+	Label  string `validate:"required"`
+}
+
+func (s *schedule) Validate() error {
+	if s.Label == "never" {
+		return errors.New("is never open")
+	}
+	return nil
+}
+
+func TestValidateStructs(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule schedule
+		want     []string
+	}{
+		{"valid", schedule{Open: window{1, 2}, Label: "shop"}, nil},
+		{"nested", schedule{Open: window{2, 1}, Closed: window{3, 1}, Label: "shop"}, []string{
+			"config: hours.open must not end before it starts",
+			"config: hours.closed must not end before it starts",
+		}},
+		{"inner first", schedule{Open: window{2, 1}, Label: "never"}, []string{
+			"config: hours.open must not end before it starts",
+			"config: hours is never open",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateStructs(reflect.ValueOf(&tt.schedule).Elem(), []string{"hours"})
+			got := []string(nil)
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
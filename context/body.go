@@ -0,0 +1,74 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrBodyTooSlow = errors.New("request body arrived too slowly")
+	ErrJSONTooDeep = errors.New("json nesting exceeds the depth limit")
+)
+
+type JSONConfig struct {
+	// MaxDepth bounds the nesting of objects and arrays in ParseJSON. Zero means unlimited.
+	MaxDepth              int
+	DisallowUnknownFields bool
+}
+
+// BodyErrorStatus maps errors from reading the request body to 413 or 408, or fallback.
+func BodyErrorStatus(err error, fallback int) int {
+	maxBytesErr := (*http.MaxBytesError)(nil)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrBodyTooSlow):
+		return http.StatusRequestTimeout
+	}
+	return fallback
+}
+
+func (l *LuxContext) failBody(err error, fallback int) error {
+	if status := BodyErrorStatus(err, fallback); status != 0 {
+		l.Response.WriteHeader(status)
+	}
+	return err
+}
+
+// depthReader fails once the JSON passing through it nests deeper than max.
+type depthReader struct {
+	r        io.Reader
+	max      int
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func (d *depthReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	for _, c := range p[:n] {
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			switch c {
+			case '\\':
+				d.escaped = true
+			case '"':
+				d.inString = false
+			}
+		case c == '"':
+			d.inString = true
+		case c == '{' || c == '[':
+			d.depth++
+			if d.depth > d.max {
+				return 0, fmt.Errorf("%w of %d", ErrJSONTooDeep, d.max)
+			}
+		case c == '}' || c == ']':
+			d.depth--
+		}
+	}
+	return n, err
+}
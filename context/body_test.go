@@ -0,0 +1,72 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		fallback int
+		want     int
+	}{
+		{&http.MaxBytesError{Limit: 1}, 0, http.StatusRequestEntityTooLarge},
+		{fmt.Errorf("reading: %w", &http.MaxBytesError{Limit: 1}), 0, http.StatusRequestEntityTooLarge},
+		{ErrBodyTooSlow, 0, http.StatusRequestTimeout},
+		{fmt.Errorf("reading: %w", ErrBodyTooSlow), http.StatusBadRequest, http.StatusRequestTimeout},
+		{errors.New("other"), 0, 0},
+		{errors.New("other"), http.StatusBadRequest, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := BodyErrorStatus(tt.err, tt.fallback); got != tt.want {
+			t.Errorf("BodyErrorStatus(%v, %d) = %d, want %d", tt.err, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		config JSONConfig
+		body   string
+		err    error
+		status int
+	}{
+		{"valid", JSONConfig{}, `{"name":"a","tags":[["x"]]}`, nil, http.StatusOK},
+		{"malformed", JSONConfig{}, `{"name":`, nil, http.StatusBadRequest},
+		{"within depth", JSONConfig{MaxDepth: 3}, `{"name":"a","tags":[["x"]]}`, nil, http.StatusOK},
+		{"too deep", JSONConfig{MaxDepth: 2}, `{"name":"a","tags":[["x"]]}`, ErrJSONTooDeep, http.StatusBadRequest},
+		{"brackets in strings", JSONConfig{MaxDepth: 1}, `{"name":"[[{{\"[","tags":null}`, nil, http.StatusOK},
+		{"unknown field allowed", JSONConfig{}, `{"name":"a","age":3}`, nil, http.StatusOK},
+		{"unknown field", JSONConfig{DisallowUnknownFields: true}, `{"name":"a","age":3}`, nil, http.StatusBadRequest},
+		{"too large", JSONConfig{}, `{"name":"` + strings.Repeat("a", 64) + `"}`, nil, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.Body = http.MaxBytesReader(w, r.Body, 48)
+			lc := &LuxContext{Request: r, Response: NewResponse(), JSONConfig: tt.config}
+
+			v := struct {
+				Name string
+				Tags [][]string
+			}{}
+			err := lc.ParseJSON(&v)
+			if (err != nil) != (tt.status != http.StatusOK) {
+				t.Fatalf("err = %v, want status %d", err, tt.status)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if lc.Response.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package context
+
+import "sync"
+
+type completion struct {
+	funcs []func(*LuxContext)
+	done  bool
+	lock  sync.Mutex
+}
+
+// OnComplete runs fn once the response has been written, when BytesWritten is known.
+// Callbacks run in the order they were added.
+func (l *LuxContext) OnComplete(fn func(*LuxContext)) {
+	if l.completion == nil {
+		l.completion = new(completion)
+	}
+
+	l.completion.lock.Lock()
+	if !l.completion.done {
+		l.completion.funcs = append(l.completion.funcs, fn)
+		l.completion.lock.Unlock()
+		return
+	}
+	l.completion.lock.Unlock()
+	fn(l)
+}
+
+// Complete runs the OnComplete callbacks once. lux calls it after writing the response.
+func (l *LuxContext) Complete() {
+	if l.completion == nil {
+		return
+	}
+
+	l.completion.lock.Lock()
+	funcs := l.completion.funcs
+	l.completion.funcs = nil
+	l.completion.done = true
+	l.completion.lock.Unlock()
+
+	for _, fn := range funcs {
+		fn(l)
+	}
+}
+
+// Fork copies l with an empty Response. Both share OnComplete callbacks, so a fork can
+// answer the request while l is still used elsewhere, e.g. by a handler that timed out.
+func (l *LuxContext) Fork() *LuxContext {
+	if l.completion == nil {
+		l.completion = new(completion)
+	}
+	fork := *l
+	fork.Response = NewResponse()
+	return &fork
+}
@@ -0,0 +1,143 @@
+package context
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CheckPreconditions evaluates If-Match, If-Unmodified-Since, If-None-Match and
+// If-Modified-Since against the current validators of the resource, the way
+// http.ServeContent does. exists tells whether there is a resource at all: if not, no
+// If-Match holds and every If-None-Match does, so If-None-Match: * guards a PUT that
+// creates. When the request must not proceed it answers 304 or 412, drops the body and
+// returns true.
+func (l *LuxContext) CheckPreconditions(modtime time.Time, etag string, exists bool) bool {
+	code := evaluatePreconditions(l.Request, modtime, etag, exists)
+	if code == 0 {
+		return false
+	}
+
+	header := l.Response.Header()
+	if code == http.StatusNotModified {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		header.Del("Content-Encoding")
+		if etag != "" {
+			header.Set("ETag", etag)
+		}
+	}
+	l.Response.Body = l.Response.Body[:0]
+	if closer, ok := l.Response.Stream.(io.Closer); ok {
+		closer.Close()
+	}
+	l.Response.Stream = nil
+	l.Response.WriteHeader(code)
+	return true
+}
+
+// evaluatePreconditions lets http.ServeContent judge the request against empty content,
+// returning 304 or 412 when it answers with one, which only its precondition checks do.
+// ServeContent assumes the resource exists, so a missing one is judged here.
+func evaluatePreconditions(r *http.Request, modtime time.Time, etag string, exists bool) int {
+	if !exists {
+		if r.Header.Get("If-Match") != "" {
+			return http.StatusPreconditionFailed
+		}
+		return 0
+	}
+
+	probe := &probeWriter{header: make(http.Header)}
+	if etag != "" {
+		probe.header.Set("ETag", etag)
+	}
+	http.ServeContent(probe, r, "", modtime, strings.NewReader(""))
+	if probe.status == http.StatusNotModified || probe.status == http.StatusPreconditionFailed {
+		return probe.status
+	}
+	return 0
+}
+
+type probeWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *probeWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *probeWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *probeWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
+
+// ReplyContent streams content with http.ServeContent, so Range and conditional requests
+// are answered as net/http answers them: 206 for ranges, multipart/byteranges for several,
+// 416 outside the content, 304 and 412 for preconditions. modtime and etag may be left empty.
+func (l *LuxContext) ReplyContent(contentType string, content io.ReadSeeker, modtime time.Time, etag string) error {
+	header := l.Response.Header()
+	header.Set("Content-Type", contentType)
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+
+	pr, pw := io.Pipe()
+	w := &contentWriter{res: l.Response, body: pw, headerWritten: make(chan struct{})}
+	go func() {
+		http.ServeContent(w, l.Request, "", modtime, content)
+		w.WriteHeader(http.StatusOK)
+		pw.Close()
+		if closer, ok := content.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	// ServeContent sets every header before the status, so they are final once it is written
+	<-w.headerWritten
+	l.Response.Body = l.Response.Body[:0]
+	l.Response.SetStream(pr)
+	return nil
+}
+
+func (l *LuxContext) ReplyMP4Content(content io.ReadSeeker, modtime time.Time, etag string) error {
+	return l.ReplyContent("video/mp4", content, modtime, etag)
+}
+
+func (l *LuxContext) ReplyWebMContent(content io.ReadSeeker, modtime time.Time, etag string) error {
+	return l.ReplyContent("video/webm", content, modtime, etag)
+}
+
+// contentWriter is the http.ResponseWriter ReplyContent gives http.ServeContent: headers and
+// status go to the Response, the body through a pipe the Response streams from. Closing the
+// stream fails the writes, which ends ServeContent.
+type contentWriter struct {
+	res           *Response
+	body          *io.PipeWriter
+	headerWritten chan struct{}
+	once          sync.Once
+}
+
+func (w *contentWriter) Header() http.Header {
+	return w.res.Headers
+}
+
+func (w *contentWriter) WriteHeader(code int) {
+	w.once.Do(func() {
+		w.res.StatusCode = code
+		close(w.headerWritten)
+	})
+}
+
+func (w *contentWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
@@ -0,0 +1,228 @@
+package context
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplyContent(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	const content = "0123456789"
+	const etag = `"v1"`
+
+	tests := []struct {
+		name    string
+		header  map[string]string
+		status  int
+		body    string
+		headers map[string]string
+	}{
+		{
+			name:    "full",
+			status:  http.StatusOK,
+			body:    content,
+			headers: map[string]string{"Accept-Ranges": "bytes", "ETag": etag, "Content-Length": "10"},
+		},
+		{
+			name:    "range",
+			header:  map[string]string{"Range": "bytes=2-5"},
+			status:  http.StatusPartialContent,
+			body:    "2345",
+			headers: map[string]string{"Content-Range": "bytes 2-5/10", "Content-Type": "text/plain"},
+		},
+		{
+			name:    "suffix range",
+			header:  map[string]string{"Range": "bytes=-3"},
+			status:  http.StatusPartialContent,
+			body:    "789",
+			headers: map[string]string{"Content-Range": "bytes 7-9/10"},
+		},
+		{
+			name:    "open range",
+			header:  map[string]string{"Range": "bytes=8-"},
+			status:  http.StatusPartialContent,
+			body:    "89",
+			headers: map[string]string{"Content-Range": "bytes 8-9/10"},
+		},
+		{
+			name:    "unsatisfiable range",
+			header:  map[string]string{"Range": "bytes=20-30"},
+			status:  http.StatusRequestedRangeNotSatisfiable,
+			headers: map[string]string{"Content-Range": "bytes */10"},
+		},
+		{
+			name:   "several ranges",
+			header: map[string]string{"Range": "bytes=0-1,4-5"},
+			status: http.StatusPartialContent,
+		},
+		{
+			name:   "if-none-match",
+			header: map[string]string{"If-None-Match": etag},
+			status: http.StatusNotModified,
+		},
+		{
+			name:   "if-none-match other",
+			header: map[string]string{"If-None-Match": `"v0"`},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			name:   "if-modified-since",
+			header: map[string]string{"If-Modified-Since": modtime.Add(time.Hour).Format(http.TimeFormat)},
+			status: http.StatusNotModified,
+		},
+		{
+			name:   "if-match",
+			header: map[string]string{"If-Match": `"v0"`},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "if-range current",
+			header: map[string]string{"Range": "bytes=0-1", "If-Range": etag},
+			status: http.StatusPartialContent,
+			body:   "01",
+		},
+		{
+			name:   "if-range stale",
+			header: map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`},
+			status: http.StatusOK,
+			body:   content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+			lc := &LuxContext{Request: r, Response: NewResponse()}
+			if err := lc.ReplyContent("text/plain", strings.NewReader(content), modtime, etag); err != nil {
+				t.Fatal(err)
+			}
+			if !lc.Response.IsStream() {
+				t.Fatal("reply is not streamed")
+			}
+			body, err := io.ReadAll(lc.Response.Stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if lc.Response.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.status)
+			}
+			if tt.body != "" && string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+			if tt.status == http.StatusNotModified && len(body) != 0 {
+				t.Errorf("304 carried a body: %q", body)
+			}
+			if tt.name == "several ranges" && !strings.HasPrefix(lc.Response.Header().Get("Content-Type"), "multipart/byteranges") {
+				t.Errorf("Content-Type = %q, want multipart/byteranges", lc.Response.Header().Get("Content-Type"))
+			}
+			for key, want := range tt.headers {
+				if got := lc.Response.Header().Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := modtime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modtime.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		etag   string
+		exists bool
+		want   int
+	}{
+		{"none", http.MethodGet, nil, `"a"`, true, 0},
+		{"if-none-match hit", http.MethodGet, map[string]string{"If-None-Match": `"a"`}, `"a"`, true, http.StatusNotModified},
+		{"if-none-match weak", http.MethodGet, map[string]string{"If-None-Match": `W/"a"`}, `"a"`, true, http.StatusNotModified},
+		{"if-none-match list", http.MethodGet, map[string]string{"If-None-Match": `"b", "a"`}, `"a"`, true, http.StatusNotModified},
+		{"if-none-match miss", http.MethodGet, map[string]string{"If-None-Match": `"b"`}, `"a"`, true, 0},
+		{"if-none-match on put", http.MethodPut, map[string]string{"If-None-Match": `"a"`}, `"a"`, true, http.StatusPreconditionFailed},
+		{"if-none-match star", http.MethodPut, map[string]string{"If-None-Match": "*"}, `"a"`, true, http.StatusPreconditionFailed},
+		{"if-none-match star missing", http.MethodPut, map[string]string{"If-None-Match": "*"}, "", false, 0},
+		{"if-match hit", http.MethodPut, map[string]string{"If-Match": `"a"`}, `"a"`, true, 0},
+		{"if-match star", http.MethodPut, map[string]string{"If-Match": "*"}, `"a"`, true, 0},
+		{"if-match miss", http.MethodPut, map[string]string{"If-Match": `"b"`}, `"a"`, true, http.StatusPreconditionFailed},
+		{"if-match weak", http.MethodPut, map[string]string{"If-Match": `W/"a"`}, `W/"a"`, true, http.StatusPreconditionFailed},
+		{"if-match missing", http.MethodPut, map[string]string{"If-Match": "*"}, "", false, http.StatusPreconditionFailed},
+		{"if-modified-since later", http.MethodGet, map[string]string{"If-Modified-Since": after}, "", true, http.StatusNotModified},
+		{"if-modified-since earlier", http.MethodGet, map[string]string{"If-Modified-Since": before}, "", true, 0},
+		{"if-unmodified-since earlier", http.MethodPut, map[string]string{"If-Unmodified-Since": before}, "", true, http.StatusPreconditionFailed},
+		{"if-unmodified-since later", http.MethodPut, map[string]string{"If-Unmodified-Since": after}, "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+			lc := &LuxContext{Request: r, Response: NewResponse()}
+			lc.Response.Header().Set("Content-Type", "text/plain")
+			lc.Response.Body = []byte("stale")
+
+			answered := lc.CheckPreconditions(modtime, tt.etag, tt.exists)
+			if answered != (tt.want != 0) {
+				t.Fatalf("answered = %v, want status %d", answered, tt.want)
+			}
+			if !answered {
+				return
+			}
+			if lc.Response.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.want)
+			}
+			if len(lc.Response.Body) != 0 {
+				t.Errorf("body = %q, want none", lc.Response.Body)
+			}
+			if tt.want == http.StatusNotModified && lc.Response.Header().Get("Content-Type") != "" {
+				t.Error("304 kept Content-Type")
+			}
+		})
+	}
+}
+
+func TestReplyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		err  bool
+	}{
+		{path, false},
+		{dir, true},
+		{filepath.Join(dir, "missing.txt"), true},
+	}
+	for _, tt := range tests {
+		lc := &LuxContext{Request: httptest.NewRequest(http.MethodGet, "/", nil), Response: NewResponse()}
+		err := lc.ReplyFile(tt.path)
+		if (err != nil) != tt.err {
+			t.Errorf("ReplyFile(%q) = %v, want error %v", tt.path, err, tt.err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		body, _ := io.ReadAll(lc.Response.Stream)
+		if string(body) != "hello" || lc.Response.Header().Get("ETag") == "" {
+			t.Errorf("ReplyFile(%q) = %q with ETag %q", tt.path, body, lc.Response.Header().Get("ETag"))
+		}
+	}
+}
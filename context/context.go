@@ -2,7 +2,9 @@ package context
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -13,6 +15,7 @@ type Response struct {
 	StatusCode int
 	Headers    http.Header
 	Body       []byte
+	Stream     io.Reader
 }
 
 func NewResponse() *Response {
@@ -36,7 +39,18 @@ func (r *Response) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// SetStream makes the response copy stream to the client after Body instead of buffering it.
+// Closers are closed once the response has been written.
+func (r *Response) SetStream(stream io.Reader) {
+	r.Stream = stream
+}
+
+func (r *Response) IsStream() bool {
+	return r.Stream != nil
+}
+
 type LuxContext struct {
+	Route          string
 	Request        *http.Request
 	Response       *Response
 	RouteParams    httprouter.Params
@@ -44,6 +58,13 @@ type LuxContext struct {
 	RequestContext context.Context
 	Logger         *zerolog.Logger
 	JWTConfig      *JWTConfig
+	JSONConfig     JSONConfig
+	StartTime      time.Time
+	// BytesWritten counts the body bytes sent, it is set before OnComplete callbacks run.
+	BytesWritten int64
+
+	completion *completion
+	detach     []func(*LuxContext)
 }
 
 func (l *LuxContext) IsOk() bool {
@@ -0,0 +1,43 @@
+package context
+
+import (
+	"context"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// OnDetach runs fn on every context Detach makes from l, e.g. to open a request scope of its own.
+func (l *LuxContext) OnDetach(fn func(*LuxContext)) {
+	l.detach = append(l.detach[:len(l.detach):len(l.detach)], fn)
+}
+
+// Detach copies l for work that outlives the request, such as a background cache revalidation.
+// The copy has a new Response and a RequestContext derived from Context rather than from the
+// request, which ends after timeout; the request is cloned onto it. Call Complete on the copy
+// once done, and cancel to release the timer.
+func (l *LuxContext) Detach(timeout time.Duration) (*LuxContext, context.CancelFunc) {
+	parent := l.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	requestContext, cancel := context.WithTimeout(parent, timeout)
+
+	detached := &LuxContext{
+		Route:          l.Route,
+		RouteParams:    append(httprouter.Params(nil), l.RouteParams...),
+		Context:        l.Context,
+		RequestContext: requestContext,
+		Response:       NewResponse(),
+		Logger:         l.Logger,
+		JWTConfig:      l.JWTConfig,
+		JSONConfig:     l.JSONConfig,
+		StartTime:      time.Now(),
+		detach:         l.detach,
+	}
+	detached.Request = l.Request.Clone(requestContext)
+	for _, fn := range l.detach {
+		fn(detached)
+	}
+	return detached, cancel
+}
@@ -23,6 +23,9 @@ func (l *LuxContext) GetMultipartFile(name string, maxMemoryBytes int64) ([]*mul
 	return l.Request.MultipartForm.File[name], nil
 }
 
+// SaveFile copies file to path.
+//
+// Deprecated: SaveFile writes to any path it is given; use StreamUpload with an UploadStorage.
 func (l *LuxContext) SaveFile(file multipart.File, path string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -33,6 +36,9 @@ func (l *LuxContext) SaveFile(file multipart.File, path string) error {
 	return err
 }
 
+// SaveMultipartFile writes every file in headers, one after another, to path.
+//
+// Deprecated: use StreamUpload, which stores each file on its own and enforces limits.
 func (l *LuxContext) SaveMultipartFile(headers []*multipart.FileHeader, path string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -44,8 +50,9 @@ func (l *LuxContext) SaveMultipartFile(headers []*multipart.FileHeader, path str
 		if err != nil {
 			return err
 		}
-		defer file.Close()
-		if _, err := io.Copy(f, file); err != nil {
+		_, err = io.Copy(f, file)
+		file.Close()
+		if err != nil {
 			return err
 		}
 	}
@@ -64,10 +71,12 @@ func (l *LuxContext) GetPathVariable(key string) string {
 	return l.RouteParams.ByName(key)
 }
 
+// GetBody reads the whole body. Bodies over the route's size limit answer 413 and bodies
+// below the minimum transfer rate answer 408.
 func (l *LuxContext) GetBody() ([]byte, error) {
 	data, err := io.ReadAll(l.Request.Body)
 	if err != nil {
-		return nil, err
+		return nil, l.failBody(err, 0)
 	}
 	l.Request.Body.Close()
 	return data, nil
@@ -96,10 +105,19 @@ func (l *LuxContext) GetRemotePort() string {
 	return port
 }
 
+// ParseJSON decodes the body into v following JSONConfig. Malformed, too deep or unknown
+// input answers 400, besides the statuses of GetBody.
 func (l *LuxContext) ParseJSON(v interface{}) error {
-	decoder := json.NewDecoder(l.Request.Body)
+	body := io.Reader(l.Request.Body)
+	if l.JSONConfig.MaxDepth > 0 {
+		body = &depthReader{r: body, max: l.JSONConfig.MaxDepth}
+	}
+	decoder := json.NewDecoder(body)
+	if l.JSONConfig.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
 	if err := decoder.Decode(v); err != nil {
-		return err
+		return l.failBody(err, http.StatusBadRequest)
 	}
 	if err := l.Request.Body.Close(); err != nil {
 		return err
@@ -2,9 +2,12 @@ package context
 
 import (
 	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+
+	"github.com/snowmerak/lux/v3/util"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -113,12 +116,22 @@ func (l *LuxContext) ReplyFile(path string) error {
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	buf, err := io.ReadAll(f)
+	info, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return err
 	}
-	return l.ReplyBinary(buf)
+	if info.IsDir() {
+		f.Close()
+		return fmt.Errorf("reply file %s: is a directory", path)
+	}
+
+	contentType, ok := util.LookupContentTypeFromExt(filepath.Ext(path))
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	etag := fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size())
+	return l.ReplyContent(contentType, f, info.ModTime(), etag)
 }
 
 func (l *LuxContext) ReplyAuto(data []byte) error {
@@ -0,0 +1,50 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+var ErrNoScope = errors.New("no request scope")
+
+// Scope is a request scope, a *provider.Scope when opened by lux.SetRequestScope. It is an
+// interface so that this package does not depend on provider.
+type Scope interface {
+	ResolveType(t reflect.Type, name string) (any, error)
+}
+
+type scopeKey struct{}
+
+// WithScope makes s the request scope of the contexts derived from ctx.
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// Scope returns the request scope opened by lux.SetRequestScope, or nil.
+func (l *LuxContext) Scope() Scope {
+	if l.RequestContext == nil {
+		return nil
+	}
+	s, _ := l.RequestContext.Value(scopeKey{}).(Scope)
+	return s
+}
+
+// Resolve returns the value of type T within the request scope of l, like provider.Resolve.
+func Resolve[T any](l *LuxContext) (T, error) {
+	return ResolveNamed[T](l, "")
+}
+
+func ResolveNamed[T any](l *LuxContext, name string) (T, error) {
+	var r T
+	s := l.Scope()
+	if s == nil {
+		return r, ErrNoScope
+	}
+	v, err := s.ResolveType(reflect.TypeOf((*T)(nil)).Elem(), name)
+	if err != nil {
+		return r, err
+	}
+	r, _ = v.(T)
+	return r, nil
+}
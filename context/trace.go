@@ -0,0 +1,127 @@
+package context
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+type requestIDKey struct{}
+type traceContextKey struct{}
+
+// TraceContext is a W3C Trace Context, https://www.w3.org/TR/trace-context/.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	State   string
+}
+
+// NewTraceContext starts a new sampled trace.
+func NewTraceContext() TraceContext {
+	tc := TraceContext{Flags: 1}
+	rand.Read(tc.TraceID[:])
+	rand.Read(tc.SpanID[:])
+	return tc
+}
+
+// ParseTraceParent parses a traceparent header, rejecting all-zero ids and version ff.
+func ParseTraceParent(header string) (TraceContext, bool) {
+	tc := TraceContext{}
+	header = strings.TrimSpace(header)
+	if len(header) < 55 || header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return tc, false
+	}
+
+	version, err := hex.DecodeString(header[:2])
+	if err != nil || version[0] == 0xff || version[0] == 0 && len(header) != 55 {
+		return tc, false
+	}
+	if len(header) > 55 && header[55] != '-' {
+		return tc, false
+	}
+	if strings.ToLower(header[:55]) != header[:55] {
+		return tc, false
+	}
+
+	if _, err := hex.Decode(tc.TraceID[:], []byte(header[3:35])); err != nil {
+		return tc, false
+	}
+	if _, err := hex.Decode(tc.SpanID[:], []byte(header[36:52])); err != nil {
+		return tc, false
+	}
+	flags, err := hex.DecodeString(header[53:55])
+	if err != nil {
+		return tc, false
+	}
+	tc.Flags = flags[0]
+
+	if !tc.IsValid() {
+		return TraceContext{}, false
+	}
+	return tc, true
+}
+
+func (t TraceContext) IsValid() bool {
+	return t.TraceID != [16]byte{} && t.SpanID != [8]byte{}
+}
+
+func (t TraceContext) IsSampled() bool {
+	return t.Flags&1 == 1
+}
+
+func (t TraceContext) TraceIDString() string {
+	return hex.EncodeToString(t.TraceID[:])
+}
+
+func (t TraceContext) SpanIDString() string {
+	return hex.EncodeToString(t.SpanID[:])
+}
+
+// TraceParent formats t as a version 00 traceparent header.
+func (t TraceContext) TraceParent() string {
+	return "00-" + t.TraceIDString() + "-" + t.SpanIDString() + "-" + hex.EncodeToString([]byte{t.Flags})
+}
+
+// Child keeps the trace, flags and state of t under a new span id.
+func (t TraceContext) Child() TraceContext {
+	child := t
+	rand.Read(child.SpanID[:])
+	return child
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+func TraceContextFrom(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// RequestID returns the id set by middleware.RequestID, or an empty string.
+func (l *LuxContext) RequestID() string {
+	if l.RequestContext == nil {
+		return ""
+	}
+	id, _ := RequestIDFrom(l.RequestContext)
+	return id
+}
+
+// TraceContext returns the server span of this request as set by middleware.RequestID.
+func (l *LuxContext) TraceContext() (TraceContext, bool) {
+	if l.RequestContext == nil {
+		return TraceContext{}, false
+	}
+	return TraceContextFrom(l.RequestContext)
+}
@@ -0,0 +1,70 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const spanID = "00f067aa0ba902b7"
+
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+		flags  byte
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, 1},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, 0},
+		{"surrounding space", "  00-" + traceID + "-" + spanID + "-01 ", true, 1},
+		{"empty", "", false, 0},
+		{"short", "00-" + traceID + "-" + spanID, false, 0},
+		{"version 00 with more", "00-" + traceID + "-" + spanID + "-01-extra", false, 0},
+		{"future version with more", "01-" + traceID + "-" + spanID + "-01-extra", true, 1},
+		{"future version glued", "01-" + traceID + "-" + spanID + "-01extra", false, 0},
+		{"version ff", "ff-" + traceID + "-" + spanID + "-01", false, 0},
+		{"bad version", "0x-" + traceID + "-" + spanID + "-01", false, 0},
+		{"upper case", "00-" + strings.ToUpper(traceID) + "-" + spanID + "-01", false, 0},
+		{"zero trace id", "00-" + strings.Repeat("0", 32) + "-" + spanID + "-01", false, 0},
+		{"zero span id", "00-" + traceID + "-" + strings.Repeat("0", 16) + "-01", false, 0},
+		{"bad trace id", "00-" + strings.Repeat("g", 32) + "-" + spanID + "-01", false, 0},
+		{"bad flags", "00-" + traceID + "-" + spanID + "-zz", false, 0},
+		{"misplaced dash", "00_" + traceID + "-" + spanID + "-01", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, ok := ParseTraceParent(tt.header)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceParent(%q) ok = %v, want %v", tt.header, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if tc.TraceIDString() != traceID || tc.SpanIDString() != spanID || tc.Flags != tt.flags {
+				t.Errorf("got %s %s %02x", tc.TraceIDString(), tc.SpanIDString(), tc.Flags)
+			}
+			if tc.IsSampled() != (tt.flags&1 == 1) {
+				t.Errorf("IsSampled = %v", tc.IsSampled())
+			}
+		})
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	tc := NewTraceContext()
+	if !tc.IsValid() || !tc.IsSampled() {
+		t.Fatalf("new trace context %+v is not valid and sampled", tc)
+	}
+
+	parsed, ok := ParseTraceParent(tc.TraceParent())
+	if !ok || parsed.TraceID != tc.TraceID || parsed.SpanID != tc.SpanID || parsed.Flags != tc.Flags {
+		t.Errorf("TraceParent %q does not round trip", tc.TraceParent())
+	}
+
+	tc.State = "vendor=value"
+	child := tc.Child()
+	if child.TraceID != tc.TraceID || child.SpanID == tc.SpanID || child.Flags != tc.Flags || child.State != tc.State {
+		t.Errorf("Child() = %+v of %+v", child, tc)
+	}
+}
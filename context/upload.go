@@ -0,0 +1,242 @@
+package context
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/snowmerak/lux/v3/util"
+)
+
+const defaultMaxUploadValueSize = 1 << 20
+
+var (
+	ErrUploadTooLarge      = errors.New("upload exceeds the total size limit")
+	ErrFileTooLarge        = errors.New("file exceeds the size limit")
+	ErrTooManyFiles        = errors.New("upload has too many files")
+	ErrFileTypeNotAllowed  = errors.New("file type is not allowed")
+	errNoUploadStorage     = errors.New("upload storage is not configured")
+	errUploadValueTooLarge = errors.New("form value exceeds the size limit")
+)
+
+type UploadConfig struct {
+	// MaxFileSize limits each file, MaxTotalSize the whole body. Zero means unlimited.
+	MaxFileSize  int64
+	MaxTotalSize int64
+	MaxFiles     int
+	// MaxValueSize limits each non-file form value, 1 MiB when zero.
+	MaxValueSize int64
+	// AllowedTypes lists sniffed media types such as "image/png" or "image/*". Empty allows all.
+	AllowedTypes []string
+	Storage      UploadStorage
+	Progress     func(UploadProgress)
+}
+
+type UploadedFile struct {
+	Field        string
+	Name         string
+	OriginalName string
+	ContentType  string
+	Size         int64
+	Location     string
+}
+
+type UploadResult struct {
+	Files  []UploadedFile
+	Values url.Values
+}
+
+type UploadProgress struct {
+	Field      string
+	Name       string
+	FileBytes  int64
+	TotalBytes int64
+}
+
+// StreamUpload reads a multipart body part by part, writing files to cfg.Storage without
+// buffering them. On failure every file saved so far is removed and the response status is
+// set to 413, 415, 400 or 500 so the handler can return the error as is.
+func (l *LuxContext) StreamUpload(cfg UploadConfig) (*UploadResult, error) {
+	result := &UploadResult{Values: url.Values{}}
+
+	fail := func(status int, err error) (*UploadResult, error) {
+		for _, file := range result.Files {
+			cfg.Storage.Remove(file.Location)
+		}
+		l.Response.WriteHeader(status)
+		return nil, err
+	}
+
+	if cfg.Storage == nil {
+		return fail(http.StatusInternalServerError, errNoUploadStorage)
+	}
+	if cfg.MaxValueSize <= 0 {
+		cfg.MaxValueSize = defaultMaxUploadValueSize
+	}
+
+	reader, err := l.Request.MultipartReader()
+	if err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	counter := &uploadCounter{cfg: &cfg}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(uploadErrorStatus(err, http.StatusBadRequest), err)
+		}
+
+		if part.FileName() == "" {
+			value, err := counter.readValue(part)
+			part.Close()
+			if err != nil {
+				return fail(uploadErrorStatus(err, http.StatusBadRequest), err)
+			}
+			result.Values.Add(part.FormName(), value)
+			continue
+		}
+
+		if cfg.MaxFiles > 0 && len(result.Files) >= cfg.MaxFiles {
+			part.Close()
+			return fail(http.StatusRequestEntityTooLarge, ErrTooManyFiles)
+		}
+
+		file, err := counter.saveFile(part)
+		part.Close()
+		if err != nil {
+			status := http.StatusInternalServerError
+			if counter.readErr != nil {
+				status = http.StatusBadRequest
+			}
+			return fail(uploadErrorStatus(err, status), err)
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	return result, nil
+}
+
+func uploadErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, ErrUploadTooLarge), errors.Is(err, ErrFileTooLarge), errors.Is(err, errUploadValueTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrFileTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	}
+	return BodyErrorStatus(err, fallback)
+}
+
+type uploadCounter struct {
+	cfg       *UploadConfig
+	part      *multipart.Part
+	name      string
+	fileBytes int64
+	total     int64
+	readErr   error
+}
+
+func (c *uploadCounter) Read(p []byte) (int, error) {
+	n, err := c.part.Read(p)
+	c.fileBytes += int64(n)
+	c.total += int64(n)
+	if err != nil && err != io.EOF {
+		c.readErr = err
+	}
+
+	if c.cfg.MaxTotalSize > 0 && c.total > c.cfg.MaxTotalSize {
+		return n, ErrUploadTooLarge
+	}
+	if c.cfg.MaxFileSize > 0 && c.fileBytes > c.cfg.MaxFileSize {
+		return n, ErrFileTooLarge
+	}
+
+	if n > 0 && c.cfg.Progress != nil {
+		c.cfg.Progress(UploadProgress{
+			Field:      c.part.FormName(),
+			Name:       c.name,
+			FileBytes:  c.fileBytes,
+			TotalBytes: c.total,
+		})
+	}
+	return n, err
+}
+
+func (c *uploadCounter) readValue(part *multipart.Part) (string, error) {
+	before := c.total
+	data, err := io.ReadAll(io.LimitReader(part, c.cfg.MaxValueSize+1))
+	c.total += int64(len(data))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > c.cfg.MaxValueSize {
+		return "", fmt.Errorf("%s: %w", part.FormName(), errUploadValueTooLarge)
+	}
+	if c.cfg.MaxTotalSize > 0 && c.total > c.cfg.MaxTotalSize {
+		c.total = before
+		return "", ErrUploadTooLarge
+	}
+	return string(data), nil
+}
+
+func (c *uploadCounter) saveFile(part *multipart.Part) (UploadedFile, error) {
+	c.part = part
+	c.name = util.SanitizeFileName(part.FileName())
+	c.fileBytes = 0
+	c.readErr = nil
+
+	buffered := bufio.NewReaderSize(c, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return UploadedFile{}, err
+	}
+
+	contentType := http.DetectContentType(head)
+	if !uploadTypeAllowed(contentType, c.cfg.AllowedTypes) {
+		return UploadedFile{}, fmt.Errorf("%s is %s: %w", c.name, contentType, ErrFileTypeNotAllowed)
+	}
+
+	location, err := c.cfg.Storage.Save(c.name, contentType, buffered)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	return UploadedFile{
+		Field:        part.FormName(),
+		Name:         c.name,
+		OriginalName: part.FileName(),
+		ContentType:  contentType,
+		Size:         c.fileBytes,
+		Location:     location,
+	}, nil
+}
+
+func uploadTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package context
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UploadStorage receives files from StreamUpload. Save must not leave anything behind
+// when it returns an error.
+type UploadStorage interface {
+	Save(name string, contentType string, r io.Reader) (location string, err error)
+	Remove(location string) error
+}
+
+type LocalUploadStorage struct {
+	dir string
+}
+
+func NewLocalUploadStorage(dir string) (*LocalUploadStorage, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalUploadStorage{dir: dir}, nil
+}
+
+func (s *LocalUploadStorage) Save(name string, _ string, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(s.dir, "*-"+name)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+func (s *LocalUploadStorage) Remove(location string) error {
+	rel, err := filepath.Rel(s.dir, location)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") || strings.ContainsRune(rel, filepath.Separator) {
+		return fmt.Errorf("remove upload %s: outside of %s", location, s.dir)
+	}
+	return os.Remove(location)
+}
+
+type MemoryUpload struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type MemoryUploadStorage struct {
+	files map[string]MemoryUpload
+	next  uint64
+	lock  sync.RWMutex
+}
+
+func NewMemoryUploadStorage() *MemoryUploadStorage {
+	return &MemoryUploadStorage{
+		files: make(map[string]MemoryUpload),
+	}
+}
+
+func (s *MemoryUploadStorage) Save(name string, contentType string, r io.Reader) (string, error) {
+	buf := bytes.Buffer{}
+	if _, err := io.Copy(&buf, r); err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next++
+	location := strconv.FormatUint(s.next, 10) + "/" + name
+	s.files[location] = MemoryUpload{
+		Name:        name,
+		ContentType: contentType,
+		Data:        buf.Bytes(),
+	}
+	return location, nil
+}
+
+func (s *MemoryUploadStorage) Remove(location string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.files, location)
+	return nil
+}
+
+func (s *MemoryUploadStorage) Get(location string) (MemoryUpload, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	file, ok := s.files[location]
+	return file, ok
+}
@@ -0,0 +1,300 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type uploadPart struct {
+	field string
+	file  string
+	data  []byte
+}
+
+func multipartRequest(t *testing.T, parts []uploadPart) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, part := range parts {
+		var w interface{ Write([]byte) (int, error) }
+		var err error
+		if part.file == "" {
+			w, err = mw.CreateFormField(part.field)
+		} else {
+			w, err = mw.CreateFormFile(part.field, part.file)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(part.data)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestStreamUpload(t *testing.T) {
+	tests := []struct {
+		name   string
+		config UploadConfig
+		parts  []uploadPart
+		status int
+		err    error
+		files  []string
+		values map[string]string
+	}{
+		{
+			name: "files and values",
+			parts: []uploadPart{
+				{field: "title", data: []byte("holiday")},
+				{field: "photo", file: "a.png", data: pngHeader},
+				{field: "notes", file: "notes.txt", data: []byte("plain text")},
+			},
+			status: http.StatusOK,
+			files:  []string{"a.png image/png", "notes.txt text/plain; charset=utf-8"},
+			values: map[string]string{"title": "holiday"},
+		},
+		{
+			name:   "unsafe file name",
+			parts:  []uploadPart{{field: "f", file: "../../etc/passwd", data: []byte("x")}},
+			status: http.StatusOK,
+			files:  []string{"passwd text/plain; charset=utf-8"},
+		},
+		{
+			name:   "allowed wildcard",
+			config: UploadConfig{AllowedTypes: []string{"image/*"}},
+			parts:  []uploadPart{{field: "f", file: "a.png", data: pngHeader}},
+			status: http.StatusOK,
+			files:  []string{"a.png image/png"},
+		},
+		{
+			name:   "type not allowed",
+			config: UploadConfig{AllowedTypes: []string{"image/*"}},
+			parts: []uploadPart{
+				{field: "f", file: "a.png", data: pngHeader},
+				{field: "f", file: "a.png", data: []byte("<html><body>")},
+			},
+			status: http.StatusUnsupportedMediaType,
+			err:    ErrFileTypeNotAllowed,
+		},
+		{
+			name:   "file too large",
+			config: UploadConfig{MaxFileSize: 4},
+			parts:  []uploadPart{{field: "f", file: "a.txt", data: []byte("12345")}},
+			status: http.StatusRequestEntityTooLarge,
+			err:    ErrFileTooLarge,
+		},
+		{
+			name:   "file at the limit",
+			config: UploadConfig{MaxFileSize: 5},
+			parts:  []uploadPart{{field: "f", file: "a.txt", data: []byte("12345")}},
+			status: http.StatusOK,
+			files:  []string{"a.txt text/plain; charset=utf-8"},
+		},
+		{
+			name:   "total too large",
+			config: UploadConfig{MaxTotalSize: 8},
+			parts: []uploadPart{
+				{field: "f", file: "a.txt", data: []byte("12345")},
+				{field: "f", file: "b.txt", data: []byte("12345")},
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    ErrUploadTooLarge,
+		},
+		{
+			name:   "values count towards the total",
+			config: UploadConfig{MaxTotalSize: 8},
+			parts: []uploadPart{
+				{field: "v", data: []byte("12345")},
+				{field: "f", file: "a.txt", data: []byte("12345")},
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    ErrUploadTooLarge,
+		},
+		{
+			name:   "value too large",
+			config: UploadConfig{MaxValueSize: 2},
+			parts:  []uploadPart{{field: "v", data: []byte("123")}},
+			status: http.StatusRequestEntityTooLarge,
+			err:    errUploadValueTooLarge,
+		},
+		{
+			name:   "too many files",
+			config: UploadConfig{MaxFiles: 1},
+			parts: []uploadPart{
+				{field: "f", file: "a.txt", data: []byte("a")},
+				{field: "f", file: "b.txt", data: []byte("b")},
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    ErrTooManyFiles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMemoryUploadStorage()
+			cfg := tt.config
+			cfg.Storage = storage
+
+			lc := &LuxContext{Request: multipartRequest(t, tt.parts), Response: NewResponse()}
+			result, err := lc.StreamUpload(cfg)
+			if lc.Response.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.status)
+			}
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				if len(storage.files) != 0 {
+					t.Errorf("%d files left in storage after a failed upload", len(storage.files))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var files []string
+			for _, file := range result.Files {
+				files = append(files, file.Name+" "+file.ContentType)
+				stored, ok := storage.Get(file.Location)
+				if !ok || int64(len(stored.Data)) != file.Size {
+					t.Errorf("%s: stored %d bytes, reported %d", file.Name, len(stored.Data), file.Size)
+				}
+			}
+			if strings.Join(files, "|") != strings.Join(tt.files, "|") {
+				t.Errorf("files = %q, want %q", files, tt.files)
+			}
+			for key, want := range tt.values {
+				if got := result.Values.Get(key); got != want {
+					t.Errorf("value %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamUploadRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		storage UploadStorage
+		status  int
+	}{
+		{
+			name:    "no storage",
+			request: func() *http.Request { return multipartRequest(t, nil) },
+			status:  http.StatusInternalServerError,
+		},
+		{
+			name: "not multipart",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("{}"))
+			},
+			storage: NewMemoryUploadStorage(),
+			status:  http.StatusBadRequest,
+		},
+		{
+			name: "truncated body",
+			request: func() *http.Request {
+				r := multipartRequest(t, []uploadPart{{field: "f", file: "a.txt", data: []byte("data")}})
+				body := &bytes.Buffer{}
+				body.ReadFrom(r.Body)
+				r.Body = readCloser{bytes.NewReader(body.Bytes()[:body.Len()-10])}
+				return r
+			},
+			storage: NewMemoryUploadStorage(),
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := &LuxContext{Request: tt.request(), Response: NewResponse()}
+			if _, err := lc.StreamUpload(UploadConfig{Storage: tt.storage}); err == nil {
+				t.Fatal("upload succeeded")
+			}
+			if lc.Response.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+type readCloser struct {
+	*bytes.Reader
+}
+
+func (readCloser) Close() error {
+	return nil
+}
+
+func TestUploadTypeAllowed(t *testing.T) {
+	tests := []struct {
+		contentType string
+		allowed     []string
+		want        bool
+	}{
+		{"image/png", nil, true},
+		{"image/png", []string{"image/png"}, true},
+		{"image/png", []string{" IMAGE/PNG "}, true},
+		{"image/png", []string{"image/*"}, true},
+		{"image/png", []string{"*/*"}, true},
+		{"text/plain; charset=utf-8", []string{"text/plain"}, true},
+		{"text/plain; charset=utf-8", []string{"image/*"}, false},
+		{"imagefoo/png", []string{"image/*"}, false},
+		{"", []string{"image/*"}, false},
+	}
+	for _, tt := range tests {
+		if got := uploadTypeAllowed(tt.contentType, tt.allowed); got != tt.want {
+			t.Errorf("uploadTypeAllowed(%q, %q) = %v, want %v", tt.contentType, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestLocalUploadStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewLocalUploadStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := storage.Save("a.txt", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(location); err != nil || string(data) != "hello" {
+		t.Fatalf("saved %q, %v", data, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "b.txt")
+	os.WriteFile(outside, []byte("keep"), 0o644)
+	tests := []struct {
+		location string
+		ok       bool
+	}{
+		{outside, false},
+		{filepath.Join(dir, "..", filepath.Base(outside)), false},
+		{dir, false},
+		{location, true},
+	}
+	for _, tt := range tests {
+		if err := storage.Remove(tt.location); (err == nil) != tt.ok {
+			t.Errorf("Remove(%q) = %v, want ok %v", tt.location, err, tt.ok)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the directory was removed: %v", err)
+	}
+	if _, err := os.Stat(location); !os.IsNotExist(err) {
+		t.Errorf("saved file was not removed: %v", err)
+	}
+}
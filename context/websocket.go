@@ -1,6 +1,7 @@
 package context
 
 import (
+	"context"
 	"net"
 
 	"github.com/gobwas/ws"
@@ -8,7 +9,8 @@ import (
 )
 
 type WSContext struct {
-	Conn net.Conn
+	Conn           net.Conn
+	RequestContext context.Context
 }
 
 func (w *WSContext) Close() error {
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/snowmerak/lux/v3/context"
 	"github.com/snowmerak/lux/v3/middleware"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type Method string
@@ -28,14 +31,28 @@ type RestController struct {
 	RequestMiddlewares  []middleware.Request
 	Handler             RestHandler
 	ResponseMiddlewares []middleware.Response
+	// Timeout overrides the server wide handler timeout for this route.
+	Timeout time.Duration
+	// MaxBodySize overrides the server wide body limit for this route. Negative means unlimited.
+	MaxBodySize int64
 }
 
 func (c *RestController) Serve(lc *context.LuxContext) error {
 	if err := middleware.ApplyRequests(lc, c.RequestMiddlewares); err != nil {
+		if errors.As(err, new(middleware.ErrAnswered)) {
+			return middleware.ApplyResponses(lc, c.ResponseMiddlewares)
+		}
 		return err
 	}
 
-	if err := c.Handler(lc); err != nil {
+	span, end := middleware.StartSpan(lc, "handler")
+	err := c.Handler(lc)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	end()
+	if err != nil {
 		return err
 	}
 
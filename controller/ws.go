@@ -1,6 +1,7 @@
 package controller
 
 import (
+	ctx "context"
 	"net"
 
 	"github.com/snowmerak/lux/v3/context"
@@ -13,6 +14,11 @@ type SocketController struct {
 }
 
 func (c *SocketController) Serve(conn net.Conn) error {
-	ctx := &context.WSContext{Conn: conn}
-	return c.Handler(ctx)
+	return c.ServeContext(ctx.Background(), conn)
+}
+
+// ServeContext hands the handler requestContext, which carries the session's span.
+func (c *SocketController) ServeContext(requestContext ctx.Context, conn net.Conn) error {
+	wc := &context.WSContext{Conn: conn, RequestContext: requestContext}
+	return c.Handler(wc)
 }
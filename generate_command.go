@@ -23,6 +23,7 @@ func generateCommand(ctx *cli.Context) error {
 	}
 
 	path := strings.Join(elems, "/")
+	configPath := path + "/config.yaml"
 
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create package directory: %w", err)
@@ -33,19 +34,28 @@ func generateCommand(ctx *cli.Context) error {
 
 	builder.WriteString("import (\n")
 	builder.WriteString("\t\"context\"\n")
-	builder.WriteString("\t\"os\"\n")
 	builder.WriteString("\t\"log\"\n")
+	builder.WriteString("\t\"os\"\n")
+	builder.WriteString("\t\"os/signal\"\n")
+	builder.WriteString("\t\"syscall\"\n\n")
+	builder.WriteString("\t\"github.com/snowmerak/lux/v3/config\"\n")
 	builder.WriteString("\t\"github.com/snowmerak/lux/v3/lux\"\n")
 	builder.WriteString("\t\"github.com/snowmerak/lux/v3/provider\"\n")
 	builder.WriteString(")\n\n")
 
 	builder.WriteString("func main() {\n")
-	builder.WriteString("\tctx, cancel := context.WithCancel(context.Background())\n")
+	builder.WriteString("\tctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)\n")
 	builder.WriteString("\tdefer cancel()\n\n")
+	builder.WriteString("\t// defaults, then the file named by --config or APP_CONFIG, which must exist, or else\n")
+	builder.WriteString("\t// " + configPath + " if run from the module root, then APP_ environment variables, then flags\n")
+	builder.WriteString("\tloader := config.New()\n")
+	builder.WriteString("\tconfig.SetFiles(loader, \"" + configPath + "\")\n")
+	builder.WriteString("\tconfig.SetFileFlag(loader, \"config\", \"APP_CONFIG\")\n")
+	builder.WriteString("\tconfig.SetEnvPrefix(loader, \"APP\")\n\n")
 	builder.WriteString("\tconstructors := []any{\n")
-	builder.WriteString("\t\tlux.New,\n")
-	builder.WriteString("\t\tlux.GenerateListenAddress(\":8080\"),\n")
-	builder.WriteString("\t\t// Add your constructors here\n")
+	builder.WriteString("\t\tconfig.Provide[lux.Config](loader, \"server\"),\n")
+	builder.WriteString("\t\tlux.NewFromConfig,\n")
+	builder.WriteString("\t\t// Add your constructors here, and config.Provide[T](loader, \"section\") for their configuration\n")
 	builder.WriteString("\t}\n\n")
 	builder.WriteString("\tupdaters := []any{\n")
 	builder.WriteString("\t\t// Add your updaters here\n")
@@ -60,10 +70,13 @@ func generateCommand(ctx *cli.Context) error {
 	builder.WriteString("\tif err := provider.Update(p, updaters...); err != nil {\n")
 	builder.WriteString("\t\tlog.Fatal(err)\n")
 	builder.WriteString("\t}\n\n")
-	builder.WriteString("\tif err := provider.JustRun(p, lux.ListenAndServe1); err != nil {\n")
+	builder.WriteString("\tif err := provider.JustRun(p, lux.ManageLifecycle); err != nil {\n")
 	builder.WriteString("\t\tlog.Fatal(err)\n")
 	builder.WriteString("\t}\n\n")
-	builder.WriteString("\t<-ctx.Done()\n")
+	builder.WriteString("\t// serves until SIGINT or SIGTERM, then shuts down and stops every component\n")
+	builder.WriteString("\tif err := provider.JustRun(p, lux.ListenAndServeConfig); err != nil {\n")
+	builder.WriteString("\t\tlog.Fatal(err)\n")
+	builder.WriteString("\t}\n")
 	builder.WriteString("}\n")
 
 	cmdFilePath := path + "/main.go"
@@ -76,5 +89,25 @@ func generateCommand(ctx *cli.Context) error {
 		return fmt.Errorf("failed to write main.go: %w", err)
 	}
 
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if err := os.WriteFile(configPath, []byte(defaultConfig), 0644); err != nil {
+			return fmt.Errorf("failed to write config.yaml: %w", err)
+		}
+	}
+
 	return nil
 }
+
+// defaultConfig is the config.yaml generated along with a command, read from the module root
+// unless --config or APP_CONFIG names another file.
+const defaultConfig = `server:
+  listen_address: ":8080"
+  read_header_timeout: 5s
+  shutdown_timeout: 30s
+  # tls:
+  #   cert_file: cert.pem
+  #   key_file: key.pem
+  # jwt:
+  #   signing_method: HS256
+  #   signing_key is best set with APP_SERVER_JWT_SIGNING_KEY_FILE
+`
@@ -65,6 +65,35 @@ func generateComponentsSetCommand(ctx *cli.Context) error {
 		}
 
 		log.Printf("Generated %s\n", filePath)
+
+		if !ctx.Bool("static") {
+			continue
+		}
+
+		set, err := loadStaticSet(ps.RootPath, name, cons, upds, false)
+		if err != nil {
+			return err
+		}
+		if set.err != nil {
+			return set.err
+		}
+
+		writer.Reset()
+		if err := buildStaticWire(&writer, set); err != nil {
+			return fmt.Errorf("failed to build static wiring: %w", err)
+		}
+
+		file, err = prettyFormat(writer.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to format source: %w", err)
+		}
+
+		filePath = fmt.Sprintf("%s/%s%s", ComponentsSetDirectory, name, StaticWireFileSuffix)
+		if err := os.WriteFile(filePath, file, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+
+		log.Printf("Generated %s\n", filePath)
 	}
 
 	return nil
@@ -0,0 +1,623 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snowmerak/lux/v3/parser"
+)
+
+const StaticWireFileSuffix = "_wire" + GeneratedFileSuffix
+
+// staticComponent is a constructor or updater of a set with its signature resolved by go/types.
+type staticComponent struct {
+	parser.Component
+	params   []types.Type
+	results  []types.Type
+	cleanups []types.Type
+	hasError bool
+	deps     []int
+}
+
+func (c *staticComponent) String() string {
+	return c.PackageName + "." + c.FunctionName
+}
+
+// staticSet is a component set resolved for static wiring.
+type staticSet struct {
+	name         string
+	constructors []*staticComponent
+	updaters     []*staticComponent
+	// providers maps the types the constructors provide to their index.
+	providers map[string]int
+	// duplicates maps types provided more than once, which only a tolerant load allows, to
+	// the indexes of all their constructors.
+	duplicates map[string][]int
+	provided   []types.Type
+	order      []int
+	// err reports why the set cannot be wired: missing, ambiguous, duplicate or cyclic dependencies.
+	err error
+}
+
+// loadStaticSet type checks the packages of a set's components from source and resolves the
+// signature of every component. A type provided twice fails the load unless tolerant, which
+// keeps the first provider for wiring and reports the duplicate in err, as graph does.
+func loadStaticSet(root string, name string, cons []parser.Component, upds []parser.Component, tolerant bool) (*staticSet, error) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+	byPath := map[string]*types.Package{}
+	for _, comp := range append(append([]parser.Component(nil), cons...), upds...) {
+		if _, ok := byPath[comp.PackagePath]; ok {
+			continue
+		}
+		pkg, err := imp.ImportFrom(comp.PackagePath, root, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to type check %s: %w", comp.PackagePath, err)
+		}
+		byPath[comp.PackagePath] = pkg
+	}
+
+	resolve := func(comp parser.Component) (*staticComponent, error) {
+		pkg, ok := byPath[comp.PackagePath]
+		if !ok {
+			return nil, fmt.Errorf("package %s of %s.%s not found", comp.PackagePath, comp.PackageName, comp.FunctionName)
+		}
+		fn, ok := pkg.Scope().Lookup(comp.FunctionName).(*types.Func)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s is not a function", comp.PackageName, comp.FunctionName)
+		}
+
+		sig := fn.Type().(*types.Signature)
+		if sig.TypeParams().Len() > 0 || sig.Variadic() {
+			return nil, fmt.Errorf("%s.%s: generic and variadic functions cannot be wired statically", comp.PackageName, comp.FunctionName)
+		}
+		sc := &staticComponent{Component: comp}
+		for i := 0; i < sig.Params().Len(); i++ {
+			sc.params = append(sc.params, sig.Params().At(i).Type())
+		}
+		for i := 0; i < sig.Results().Len(); i++ {
+			t := sig.Results().At(i).Type()
+			switch {
+			case isErrorType(t):
+				if i != sig.Results().Len()-1 {
+					return nil, fmt.Errorf("%s: error must be the last result", sc)
+				}
+				sc.hasError = true
+			case isCleanupType(t):
+				sc.cleanups = append(sc.cleanups, t)
+			default:
+				sc.results = append(sc.results, t)
+			}
+		}
+		return sc, nil
+	}
+
+	set := &staticSet{name: name, providers: map[string]int{}, duplicates: map[string][]int{}}
+	for _, comp := range cons {
+		sc, err := resolve(comp)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range sc.results {
+			key := types.TypeString(t, nil)
+			if other, ok := set.providers[key]; ok {
+				if !tolerant {
+					return nil, fmt.Errorf("duplicate provider of %s: %s and %s", key, set.constructors[other], sc)
+				}
+				if set.duplicates[key] == nil {
+					set.duplicates[key] = []int{other}
+				}
+				set.duplicates[key] = append(set.duplicates[key], len(set.constructors))
+				continue
+			}
+			set.providers[key] = len(set.constructors)
+			set.provided = append(set.provided, t)
+		}
+		set.constructors = append(set.constructors, sc)
+	}
+	for _, comp := range upds {
+		sc, err := resolve(comp)
+		if err != nil {
+			return nil, err
+		}
+		set.updaters = append(set.updaters, sc)
+	}
+
+	set.resolveDeps()
+	return set, nil
+}
+
+// provider returns the constructor providing what satisfies t: the one providing t itself, or
+// else the one providing the only type implementing the interface t.
+func (s *staticSet) provider(t types.Type) (int, types.Type, error) {
+	if i, ok := s.providers[types.TypeString(t, nil)]; ok {
+		return i, t, nil
+	}
+
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return -1, nil, nil
+	}
+	candidates := []types.Type(nil)
+	for _, p := range s.provided {
+		if _, ok := p.Underlying().(*types.Interface); !ok && types.Implements(p, iface) {
+			candidates = append(candidates, p)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return -1, nil, nil
+	case 1:
+		return s.providers[types.TypeString(candidates[0], nil)], candidates[0], nil
+	}
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = types.TypeString(c, nil)
+	}
+	return -1, nil, fmt.Errorf("ambiguous dependency: %s, which %s all implement", types.TypeString(t, nil), strings.Join(names, ", "))
+}
+
+func (s *staticSet) resolveDeps() {
+	errs := []string(nil)
+	for _, t := range s.provided {
+		key := types.TypeString(t, nil)
+		if dups, ok := s.duplicates[key]; ok {
+			names := make([]string, len(dups))
+			for i, c := range dups {
+				names[i] = s.constructors[c].String()
+			}
+			errs = append(errs, fmt.Sprintf("duplicate provider of %s: %s", key, strings.Join(names, " and ")))
+		}
+	}
+	check := func(sc *staticComponent, updater bool) {
+		for _, t := range sc.results {
+			if !exported(t) {
+				errs = append(errs, fmt.Sprintf("%s returns %s, which cannot be named outside its package", sc, types.TypeString(t, nil)))
+			}
+		}
+		sc.deps = sc.deps[:0]
+		for _, t := range sc.params {
+			if isContextType(t) {
+				sc.deps = append(sc.deps, -1)
+				continue
+			}
+			i, _, err := s.provider(t)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Sprintf("%s needs %s", sc, err))
+			case i < 0:
+				errs = append(errs, fmt.Sprintf("missing dependency: %s needs %s, which no constructor of %s provides", sc, types.TypeString(t, nil), s.name))
+			}
+			sc.deps = append(sc.deps, i)
+		}
+		if !updater {
+			return
+		}
+		// updaters replace values in their fields, so they return the provided types themselves
+		for _, t := range sc.results {
+			if _, ok := s.providers[types.TypeString(t, nil)]; ok {
+				continue
+			}
+			if i, provided, err := s.provider(t); err == nil && i >= 0 {
+				errs = append(errs, fmt.Sprintf("%s returns %s, but must return the %s that %s provides", sc, types.TypeString(t, nil), types.TypeString(provided, nil), s.constructors[i]))
+				continue
+			}
+			errs = append(errs, fmt.Sprintf("%s returns %s, which no constructor of %s provides", sc, types.TypeString(t, nil), s.name))
+		}
+	}
+	for _, sc := range s.constructors {
+		check(sc, false)
+	}
+	for _, sc := range s.updaters {
+		check(sc, true)
+	}
+
+	order, cycle := s.sort()
+	if cycle != nil {
+		names := make([]string, len(cycle))
+		for i, c := range cycle {
+			names[i] = s.constructors[c].String()
+		}
+		errs = append(errs, "cyclic dependency: "+strings.Join(names, " -> "))
+	}
+	s.order = order
+
+	if errs != nil {
+		s.err = fmt.Errorf("static wiring of %s:\n\t%s", s.name, strings.Join(errs, "\n\t"))
+	}
+}
+
+// sort orders the constructors so each comes after its dependencies, keeping parser order
+// otherwise. It returns a cycle instead when there is one.
+func (s *staticSet) sort() ([]int, []int) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(s.constructors))
+	order := []int(nil)
+	stack := []int(nil)
+	var cycle []int
+
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, dep := range s.constructors[i].deps {
+			if dep < 0 {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				if !visit(dep) {
+					return false
+				}
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != dep {
+					start--
+				}
+				cycle = append(append([]int(nil), stack[start:]...), dep)
+				return false
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		order = append(order, i)
+		return true
+	}
+
+	for i := range s.constructors {
+		if state[i] == unvisited && !visit(i) {
+			return nil, cycle
+		}
+	}
+	return order, nil
+}
+
+// buildStaticWire writes a Wire function that calls the constructors and updaters of set in
+// order with typed variables, and a struct holding what they provide.
+func buildStaticWire(writer *bytes.Buffer, set *staticSet) error {
+	imports := newImportSet()
+	qualifier := imports.qualifier
+
+	structName := strings.ToUpper(set.name[:1]) + set.name[1:] + "Wired"
+	hookName := strings.ToLower(set.name[:1]) + set.name[1:] + "Hook"
+	fields := map[string]string{}
+	used := map[string]struct{}{"hooks": {}, "started": {}}
+	for _, t := range set.provided {
+		fields[types.TypeString(t, nil)] = fieldName(t, used)
+	}
+	field := func(t types.Type) string {
+		_, provided, _ := set.provider(t)
+		return "w." + fields[types.TypeString(provided, nil)]
+	}
+	args := func(sc *staticComponent) string {
+		list := make([]string, len(sc.params))
+		for i, t := range sc.params {
+			if sc.deps[i] < 0 {
+				list[i] = "ctx"
+				continue
+			}
+			list[i] = field(t)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	body := bytes.Buffer{}
+	cleanup := 0
+	call := func(sc *staticComponent, updater bool) {
+		lhs := []string(nil)
+		cleanups := []string(nil)
+		for _, t := range sc.results {
+			lhs = append(lhs, "w."+fields[types.TypeString(t, nil)])
+		}
+		for _, t := range sc.cleanups {
+			name := "cleanup" + strconv.Itoa(cleanup)
+			cleanup++
+			fmt.Fprintf(&body, "\tvar %s %s\n", name, types.TypeString(t, qualifier))
+			lhs = append(lhs, name)
+			cleanups = append(cleanups, name)
+		}
+		if sc.hasError {
+			lhs = append(lhs, "err")
+		}
+
+		callExpr := fmt.Sprintf("%s.%s(%s)", imports.name(sc.PackagePath, sc.PackageName), sc.FunctionName, args(sc))
+		if len(lhs) == 0 {
+			fmt.Fprintf(&body, "\t%s\n", callExpr)
+		} else {
+			fmt.Fprintf(&body, "\t%s = %s\n", strings.Join(lhs, ", "), callExpr)
+		}
+		if sc.hasError {
+			fmt.Fprintf(&body, "\tif err != nil {\n\t\treturn nil, w.fail(ctx, %q, err)\n\t}\n", sc.String())
+		}
+
+		for i, name := range cleanups {
+			if isCleanupErrorType(sc.cleanups[i]) {
+				fmt.Fprintf(&body, "\tif %s != nil {\n\t\tw.hooks = append(w.hooks, %s{stop: func(context.Context) error { return %s() }})\n\t}\n", name, hookName, name)
+				continue
+			}
+			fmt.Fprintf(&body, "\tif %s != nil {\n\t\tw.hooks = append(w.hooks, %s{stop: func(context.Context) error { %s(); return nil }})\n\t}\n", name, hookName, name)
+		}
+		if updater {
+			return
+		}
+		for _, t := range sc.results {
+			f := "w." + fields[types.TypeString(t, nil)]
+			// closures read the field when called, so a nil component is skipped, not dereferenced
+			hook := func(method string) string {
+				if !hasLifecycleMethod(t, method) {
+					return ""
+				}
+				if isNilable(t) {
+					return fmt.Sprintf("%s: func(ctx context.Context) error {\nif %s == nil {\nreturn nil\n}\nreturn %s.%s(ctx)\n},\n", strings.ToLower(method), f, f, method)
+				}
+				return fmt.Sprintf("%s: func(ctx context.Context) error { return %s.%s(ctx) },\n", strings.ToLower(method), f, method)
+			}
+			start, stop := hook("Start"), hook("Stop")
+			if start != "" || stop != "" {
+				fmt.Fprintf(&body, "\tw.hooks = append(w.hooks, %s{\n%s%s})\n", hookName, start, stop)
+			}
+		}
+	}
+
+	hasError := false
+	for _, i := range set.order {
+		sc := set.constructors[i]
+		hasError = hasError || sc.hasError
+		call(sc, false)
+	}
+	for _, sc := range set.updaters {
+		hasError = hasError || sc.hasError
+		call(sc, true)
+	}
+
+	fieldDecls := bytes.Buffer{}
+	for _, t := range set.provided {
+		fmt.Fprintf(&fieldDecls, "\t%s %s\n", fields[types.TypeString(t, nil)], types.TypeString(t, qualifier))
+	}
+
+	writer.WriteString("// Code generated by lux generate component --static. DO NOT EDIT.\n\n")
+	writer.WriteString("package ")
+	writer.WriteString(ComponentsSetPackage)
+	writer.WriteString("\n\n")
+
+	writer.WriteString("import (\n")
+	writer.WriteString("\t\"context\"\n")
+	writer.WriteString("\t\"errors\"\n")
+	writer.WriteString("\t\"fmt\"\n")
+	imports.write(writer)
+	writer.WriteString(")\n\n")
+
+	fmt.Fprintf(writer, "// %s holds what the constructors of %s provide.\n", structName, set.name)
+	fmt.Fprintf(writer, "type %s struct {\n", structName)
+	writer.Write(fieldDecls.Bytes())
+	writer.WriteString("\n\thooks   []" + hookName + "\n")
+	writer.WriteString("\tstarted int\n")
+	writer.WriteString("}\n\n")
+
+	fmt.Fprintf(writer, "// %s starts and stops a component, or runs a cleanup as stop.\n", hookName)
+	fmt.Fprintf(writer, "type %s struct {\n", hookName)
+	writer.WriteString("\tstart func(context.Context) error\n")
+	writer.WriteString("\tstop  func(context.Context) error\n")
+	writer.WriteString("}\n\n")
+
+	fmt.Fprintf(writer, "// Wire%s calls the constructors of %s in dependency order, then its updaters,\n", strings.TrimSuffix(structName, "Wired"), set.name)
+	writer.WriteString("// without reflection. When one fails, what was constructed is stopped again.\n")
+	fmt.Fprintf(writer, "func Wire%s(ctx context.Context) (*%s, error) {\n", strings.TrimSuffix(structName, "Wired"), structName)
+	fmt.Fprintf(writer, "\tw := &%s{}\n", structName)
+	if hasError {
+		writer.WriteString("\tvar err error\n")
+	}
+	writer.Write(body.Bytes())
+	writer.WriteString("\treturn w, nil\n")
+	writer.WriteString("}\n\n")
+
+	writer.WriteString("// Start starts the components that have a Start method, in construction order. When one\n")
+	writer.WriteString("// fails, the ones started before it are stopped again.\n")
+	fmt.Fprintf(writer, "func (w *%s) Start(ctx context.Context) error {\n", structName)
+	writer.WriteString("\tfor ; w.started < len(w.hooks); w.started++ {\n")
+	writer.WriteString("\t\tif start := w.hooks[w.started].start; start != nil {\n")
+	writer.WriteString("\t\t\tif err := start(ctx); err != nil {\n")
+	writer.WriteString("\t\t\t\treturn errors.Join(err, w.rollback(ctx))\n")
+	writer.WriteString("\t\t\t}\n")
+	writer.WriteString("\t\t}\n")
+	writer.WriteString("\t}\n")
+	writer.WriteString("\treturn nil\n")
+	writer.WriteString("}\n\n")
+
+	writer.WriteString("// rollback stops the components Start started, in reverse order.\n")
+	fmt.Fprintf(writer, "func (w *%s) rollback(ctx context.Context) error {\n", structName)
+	writer.WriteString("\terrs := []error(nil)\n")
+	writer.WriteString("\tfor ; w.started > 0; w.started-- {\n")
+	writer.WriteString("\t\tif hook := w.hooks[w.started-1]; hook.start != nil && hook.stop != nil {\n")
+	writer.WriteString("\t\t\terrs = append(errs, hook.stop(ctx))\n")
+	writer.WriteString("\t\t}\n")
+	writer.WriteString("\t}\n")
+	writer.WriteString("\treturn errors.Join(errs...)\n")
+	writer.WriteString("}\n\n")
+
+	writer.WriteString("// Stop stops the components that were started or have no Start method and runs the\n")
+	writer.WriteString("// cleanups, in reverse construction order.\n")
+	fmt.Fprintf(writer, "func (w *%s) Stop(ctx context.Context) error {\n", structName)
+	writer.WriteString("\terrs := []error(nil)\n")
+	writer.WriteString("\tfor i := len(w.hooks) - 1; i >= 0; i-- {\n")
+	writer.WriteString("\t\thook := w.hooks[i]\n")
+	writer.WriteString("\t\tif hook.stop == nil || hook.start != nil && i >= w.started {\n")
+	writer.WriteString("\t\t\tcontinue\n")
+	writer.WriteString("\t\t}\n")
+	writer.WriteString("\t\terrs = append(errs, hook.stop(ctx))\n")
+	writer.WriteString("\t}\n")
+	writer.WriteString("\tw.hooks = nil\n")
+	writer.WriteString("\tw.started = 0\n")
+	writer.WriteString("\treturn errors.Join(errs...)\n")
+	writer.WriteString("}\n\n")
+
+	fmt.Fprintf(writer, "func (w *%s) fail(ctx context.Context, constructor string, err error) error {\n", structName)
+	writer.WriteString("\treturn errors.Join(fmt.Errorf(\"construct %s: %w\", constructor, err), w.Stop(ctx))\n")
+	writer.WriteString("}\n")
+
+	return nil
+}
+
+// importSet names the packages the generated code refers to, renaming clashes.
+type importSet struct {
+	names map[string]string
+	taken map[string]struct{}
+}
+
+func newImportSet() *importSet {
+	return &importSet{
+		names: map[string]string{},
+		taken: map[string]struct{}{"context": {}, "errors": {}, "fmt": {}},
+	}
+}
+
+func (s *importSet) name(path string, name string) string {
+	if n, ok := s.names[path]; ok {
+		return n
+	}
+	if path == "context" {
+		return "context"
+	}
+	n := name
+	for i := 1; ; i++ {
+		if _, ok := s.taken[n]; !ok {
+			break
+		}
+		n = name + strconv.Itoa(i)
+	}
+	s.names[path] = n
+	s.taken[n] = struct{}{}
+	return n
+}
+
+func (s *importSet) qualifier(pkg *types.Package) string {
+	return s.name(pkg.Path(), pkg.Name())
+}
+
+func (s *importSet) write(writer *bytes.Buffer) {
+	paths := make([]string, 0, len(s.names))
+	for path := range s.names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(writer, "\t%s %q\n", s.names[path], path)
+	}
+}
+
+// fieldName names the struct field holding a value of type t after the type.
+func fieldName(t types.Type, used map[string]struct{}) string {
+	base := "Value"
+	inner := t
+	for {
+		switch x := inner.(type) {
+		case *types.Pointer:
+			inner = x.Elem()
+			continue
+		case *types.Slice:
+			inner = x.Elem()
+			continue
+		}
+		break
+	}
+	if named, ok := inner.(*types.Named); ok {
+		base = named.Obj().Name()
+		if _, ok := used[base]; ok && named.Obj().Pkg() != nil {
+			pkg := named.Obj().Pkg().Name()
+			base = strings.ToUpper(pkg[:1]) + pkg[1:] + base
+		}
+	}
+	base = strings.ToUpper(base[:1]) + base[1:]
+
+	name := base
+	for i := 1; ; i++ {
+		if _, ok := used[name]; !ok {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	used[name] = struct{}{}
+	return name
+}
+
+// exported reports whether generated code in another package can name t.
+func exported(t types.Type) bool {
+	switch x := t.(type) {
+	case *types.Named:
+		if x.Obj().Pkg() != nil && !x.Obj().Exported() {
+			return false
+		}
+		args := x.TypeArgs()
+		for i := 0; i < args.Len(); i++ {
+			if !exported(args.At(i)) {
+				return false
+			}
+		}
+		return true
+	case *types.Pointer:
+		return exported(x.Elem())
+	case *types.Slice:
+		return exported(x.Elem())
+	case *types.Array:
+		return exported(x.Elem())
+	case *types.Chan:
+		return exported(x.Elem())
+	case *types.Map:
+		return exported(x.Key()) && exported(x.Elem())
+	}
+	return true
+}
+
+func isErrorType(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+func isCleanupType(t types.Type) bool {
+	sig, ok := t.(*types.Signature)
+	if !ok || sig.Params().Len() != 0 {
+		return false
+	}
+	return sig.Results().Len() == 0 || isCleanupErrorType(t)
+}
+
+func isCleanupErrorType(t types.Type) bool {
+	sig, ok := t.(*types.Signature)
+	return ok && sig.Params().Len() == 0 && sig.Results().Len() == 1 && isErrorType(sig.Results().At(0).Type())
+}
+
+func isContextType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+// isNilable reports whether a value of t can be nil.
+func isNilable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface, *types.Map, *types.Slice, *types.Chan, *types.Signature:
+		return true
+	}
+	return false
+}
+
+// hasLifecycleMethod reports whether t has a method name(context.Context) error, like
+// provider.Starter and provider.Stopper.
+func hasLifecycleMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 1 && isContextType(sig.Params().At(0).Type()) &&
+		sig.Results().Len() == 1 && isErrorType(sig.Results().At(0).Type())
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/parser"
+)
+
+// staticTestSource is the package comp of the module writeStaticModule writes.
+const staticTestSource = `package comp
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	journal         []string
+	FailConstruct   bool
+	FailStart       bool
+)
+
+func Record(entry string) { journal = append(journal, entry) }
+
+func Journal() string {
+	defer func() { journal = nil }()
+	return strings.Join(journal, ", ")
+}
+
+type Config struct{}
+
+func NewConfig() (*Config, func()) { return &Config{}, func() { Record("cleanup config") } }
+
+func NewOtherConfig() *Config { return &Config{} }
+
+type DB struct{}
+
+func NewDB(ctx context.Context, c *Config) (*DB, error) {
+	if FailConstruct {
+		return nil, errors.New("refused")
+	}
+	return &DB{}, nil
+}
+
+func (d *DB) Start(ctx context.Context) error {
+	Record("start db")
+	if FailStart {
+		return errors.New("refused")
+	}
+	return nil
+}
+
+func (d *DB) Stop(ctx context.Context) error { Record("stop db"); return nil }
+
+type Store interface{ Load() string }
+
+type PgStore struct{ db *DB }
+
+func (s PgStore) Load() string { return "pg" }
+
+func NewPgStore(db *DB) PgStore { return PgStore{db: db} }
+
+type MemStore struct{}
+
+func (MemStore) Load() string { return "mem" }
+
+func NewMemStore() MemStore { return MemStore{} }
+
+func WrapStore(s Store) Store { return s }
+
+type API struct{ store Store }
+
+func NewAPI(s Store) *API { return &API{store: s} }
+
+func (a *API) Start(ctx context.Context) error { Record("start api " + a.store.Load()); return nil }
+
+func (a *API) Stop(ctx context.Context) error { Record("stop api"); return nil }
+
+type Cache struct{ entries map[string]string }
+
+func NewCache() *Cache { return nil }
+
+func (c *Cache) Stop(ctx context.Context) error { Record("stop cache " + c.entries[""]); return nil }
+
+type (
+	CycleA struct{}
+	CycleB struct{}
+	Missing struct{}
+	Orphan  struct{}
+	hidden  struct{}
+)
+
+func NewCycleA(b CycleB) CycleA { return CycleA{} }
+func NewCycleB(a CycleA) CycleB { return CycleB{} }
+func NewOrphan(m Missing) Orphan { return Orphan{} }
+func NewHidden() hidden          { return hidden{} }
+`
+
+// writeStaticModule writes a module example.com/wapp with the package comp to a temporary
+// directory and changes into it for the test, as the source importer resolves packages from
+// the working directory.
+func writeStaticModule(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/wapp\n\ngo 1.20\n",
+		"comp/comp.go": staticTestSource,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return root
+}
+
+func compComponents(names ...string) []parser.Component {
+	comps := make([]parser.Component, len(names))
+	for i, name := range names {
+		comps[i] = parser.Component{PackagePath: "example.com/wapp/comp", PackageName: "comp", FunctionName: name}
+	}
+	return comps
+}
+
+func TestLoadStaticSet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to type check from source")
+	}
+	root := writeStaticModule(t)
+
+	tests := []struct {
+		name     string
+		cons     []string
+		upds     []string
+		tolerant bool
+		loadErr  string
+		setErr   string
+		order    string
+	}{
+		{
+			name:  "wired",
+			cons:  []string{"NewAPI", "NewPgStore", "NewDB", "NewConfig", "NewCache"},
+			order: "comp.NewConfig, comp.NewDB, comp.NewPgStore, comp.NewAPI, comp.NewCache",
+		},
+		{
+			name:   "missing",
+			cons:   []string{"NewOrphan"},
+			setErr: "missing dependency: comp.NewOrphan needs example.com/wapp/comp.Missing, which no constructor of app provides",
+		},
+		{
+			name:   "cycle",
+			cons:   []string{"NewCycleA", "NewCycleB"},
+			setErr: "cyclic dependency: comp.NewCycleA -> comp.NewCycleB -> comp.NewCycleA",
+		},
+		{
+			name:   "ambiguous",
+			cons:   []string{"NewPgStore", "NewMemStore", "NewAPI", "NewDB", "NewConfig"},
+			setErr: "comp.NewAPI needs ambiguous dependency: example.com/wapp/comp.Store",
+		},
+		{
+			name:   "unexported",
+			cons:   []string{"NewHidden"},
+			setErr: "comp.NewHidden returns example.com/wapp/comp.hidden, which cannot be named outside its package",
+		},
+		{
+			name:    "duplicate",
+			cons:    []string{"NewConfig", "NewOtherConfig"},
+			loadErr: "duplicate provider of *example.com/wapp/comp.Config: comp.NewConfig and comp.NewOtherConfig",
+		},
+		{
+			name:     "tolerated duplicate",
+			cons:     []string{"NewConfig", "NewOtherConfig"},
+			tolerant: true,
+			setErr:   "duplicate provider of *example.com/wapp/comp.Config: comp.NewConfig and comp.NewOtherConfig",
+		},
+		{
+			name:   "updater returns an interface",
+			cons:   []string{"NewPgStore", "NewDB", "NewConfig"},
+			upds:   []string{"WrapStore"},
+			setErr: "comp.WrapStore returns example.com/wapp/comp.Store, but must return the example.com/wapp/comp.PgStore that comp.NewPgStore provides",
+		},
+		{
+			name:    "not a function",
+			cons:    []string{"Config"},
+			loadErr: "comp.Config is not a function",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := loadStaticSet(root, "app", compComponents(tt.cons...), compComponents(tt.upds...), tt.tolerant)
+			if tt.loadErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.loadErr) {
+					t.Errorf("loadStaticSet = %v, want %q", err, tt.loadErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.setErr == "" && set.err != nil {
+				t.Fatalf("set error = %v", set.err)
+			}
+			if tt.setErr != "" && (set.err == nil || !strings.Contains(set.err.Error(), tt.setErr)) {
+				t.Errorf("set error = %v, want %q", set.err, tt.setErr)
+			}
+			if tt.order != "" {
+				names := []string(nil)
+				for _, i := range set.order {
+					names = append(names, set.constructors[i].String())
+				}
+				if got := strings.Join(names, ", "); got != tt.order {
+					t.Errorf("order = %s, want %s", got, tt.order)
+				}
+			}
+		})
+	}
+}
+
+// staticTestMain runs the generated wiring of the set app in three scenarios and prints
+// what the components did in each.
+const staticTestMain = `package main
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/wapp/comp"
+	"example.com/wapp/gen/components"
+)
+
+func main() {
+	ctx := context.Background()
+	for _, scenario := range []string{"ok", "construct fails", "start fails"} {
+		comp.FailConstruct = scenario == "construct fails"
+		comp.FailStart = scenario == "start fails"
+
+		w, err := components.WireApp(ctx)
+		if err == nil {
+			err = w.Start(ctx)
+			if stopErr := w.Stop(ctx); stopErr != nil {
+				err = stopErr
+			}
+		}
+		fmt.Printf("%s: %v: %s\n", scenario, err, comp.Journal())
+	}
+}
+`
+
+func TestStaticWireOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs a generated program")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to build the generated wiring")
+	}
+	root := writeStaticModule(t)
+
+	set, err := loadStaticSet(root, "app", compComponents("NewAPI", "NewPgStore", "NewDB", "NewConfig", "NewCache"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.err != nil {
+		t.Fatal(set.err)
+	}
+	writer := bytes.Buffer{}
+	if err := buildStaticWire(&writer, set); err != nil {
+		t.Fatal(err)
+	}
+	file, err := prettyFormat(writer.Bytes())
+	if err != nil {
+		t.Fatalf("generated code does not format: %v\n%s", err, writer.String())
+	}
+	if bytes.Contains(file, []byte(`"reflect"`)) || bytes.Contains(file, []byte("provider.")) {
+		t.Errorf("generated wiring uses reflection:\n%s", file)
+	}
+
+	for name, content := range map[string][]byte{
+		filepath.Join("gen", "components", "app"+StaticWireFileSuffix): file,
+		"main.go": []byte(staticTestMain),
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s\n%s", err, out, file)
+	}
+
+	want := []string{
+		// the nil cache is not stopped, and stops come in reverse construction order
+		"ok: <nil>: start db, start api pg, stop api, stop db, cleanup config",
+		// what was constructed before the failing constructor is stopped again
+		"construct fails: construct comp.NewDB: refused: cleanup config",
+		// the failed db and the api never started are not stopped
+		"start fails: refused: start db, cleanup config",
+	}
+	if got := strings.Split(strings.TrimSpace(string(out)), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
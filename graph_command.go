@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/snowmerak/lux/v3/parser"
+	"github.com/snowmerak/lux/v3/provider"
+	"github.com/urfave/cli/v2"
+)
+
+func graphCommand(ctx *cli.Context) error {
+	ps := parser.New()
+	if err := ps.ParseFromRoot(); err != nil {
+		return fmt.Errorf("failed to parse module: %w", err)
+	}
+
+	keys := map[string]struct{}{}
+	for key := range ps.Constructors {
+		keys[key] = struct{}{}
+	}
+	for key := range ps.Updaters {
+		keys[key] = struct{}{}
+	}
+	names := []string(nil)
+	for name := range keys {
+		if only := ctx.String("set"); only != "" && name != only {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return fmt.Errorf("no component sets found")
+	}
+
+	graphs := map[string]provider.Graph{}
+	for _, name := range names {
+		set, err := loadStaticSet(ps.RootPath, name, ps.Constructors[name], ps.Updaters[name], true)
+		if err != nil {
+			return err
+		}
+		if set.err != nil {
+			log.Println(set.err)
+		}
+		graphs[name] = staticGraph(set)
+	}
+
+	writer := bytes.Buffer{}
+	switch format := ctx.String("format"); format {
+	case "dot":
+		for i, name := range names {
+			if i > 0 {
+				writer.WriteString("\n")
+			}
+			if err := graphs[name].WriteDOT(&writer, name); err != nil {
+				return err
+			}
+		}
+	case "mermaid":
+		for i, name := range names {
+			if i > 0 {
+				writer.WriteString("\n")
+			}
+			writer.WriteString("%% " + name + "\n")
+			if err := graphs[name].WriteMermaid(&writer); err != nil {
+				return err
+			}
+		}
+	case "json":
+		data, err := json.MarshalIndent(graphs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode graph: %w", err)
+		}
+		writer.Write(data)
+		writer.WriteString("\n")
+	default:
+		return fmt.Errorf("unknown format %q, must be dot, mermaid or json", format)
+	}
+
+	var out io.Writer = os.Stdout
+	if path := ctx.String("output"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if _, err := out.Write(writer.Bytes()); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
+// staticGraph describes a component set the way provider.Provider.Graph describes a provider.
+func staticGraph(set *staticSet) provider.Graph {
+	qualifier := func(pkg *types.Package) string {
+		return pkg.Name()
+	}
+	typeStrings := func(ts []types.Type) []string {
+		s := make([]string, len(ts))
+		for i, t := range ts {
+			s[i] = types.TypeString(t, qualifier)
+		}
+		return s
+	}
+
+	order := map[int]int{}
+	for i, c := range set.order {
+		order[c] = i + 1
+	}
+
+	component := func(id string, sc *staticComponent) provider.Constructor {
+		c := provider.Constructor{
+			ID:       id,
+			Name:     sc.String(),
+			Lifetime: provider.Singleton.String(),
+			Provides: typeStrings(sc.results),
+			Needs:    []provider.Dependency{},
+		}
+		for i, t := range sc.params {
+			dep := provider.Dependency{Type: types.TypeString(t, qualifier), Providers: []string{}}
+			switch {
+			case sc.deps[i] >= 0:
+				_, provided, _ := set.provider(t)
+				if dups, ok := set.duplicates[types.TypeString(provided, nil)]; ok {
+					for _, d := range dups {
+						dep.Providers = append(dep.Providers, "n"+strconv.Itoa(d))
+					}
+					dep.Duplicate = true
+					break
+				}
+				dep.Providers = append(dep.Providers, "n"+strconv.Itoa(sc.deps[i]))
+			case isContextType(t):
+				dep.External = true
+			default:
+				dep.Missing = true
+			}
+			c.Needs = append(c.Needs, dep)
+		}
+		return c
+	}
+
+	g := provider.Graph{}
+	for i, sc := range set.constructors {
+		c := component("n"+strconv.Itoa(i), sc)
+		c.Order = order[i]
+		g.Constructors = append(g.Constructors, c)
+	}
+	for i, sc := range set.updaters {
+		c := component("u"+strconv.Itoa(i), sc)
+		c.Updater = true
+		g.Constructors = append(g.Constructors, c)
+	}
+	return g
+}
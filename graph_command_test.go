@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+func TestStaticGraph(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to type check from source")
+	}
+	writeStaticModule(t)
+
+	tests := []struct {
+		name string
+		cons []string
+		upds []string
+		// needs lists each constructor with what it needs and where that comes from
+		needs   []string
+		order   string
+		missing string
+		unused  string
+	}{
+		{
+			name: "wired",
+			cons: []string{"NewAPI", "NewPgStore", "NewDB", "NewConfig"},
+			needs: []string{
+				"n0 comp.NewAPI: comp.Store<-n1",
+				"n1 comp.NewPgStore: *comp.DB<-n2",
+				"n2 comp.NewDB: context.Context<-external *comp.Config<-n3",
+				"n3 comp.NewConfig:",
+			},
+			order:  "comp.NewConfig, comp.NewDB, comp.NewPgStore, comp.NewAPI",
+			unused: "n0",
+		},
+		{
+			name: "duplicate and missing",
+			cons: []string{"NewDB", "NewConfig", "NewOtherConfig", "NewOrphan"},
+			needs: []string{
+				"n0 comp.NewDB: context.Context<-external *comp.Config<-duplicate n1,n2",
+				"n1 comp.NewConfig:",
+				"n2 comp.NewOtherConfig:",
+				"n3 comp.NewOrphan: comp.Missing<-missing",
+			},
+			order:   "comp.NewConfig, comp.NewDB, comp.NewOtherConfig, comp.NewOrphan",
+			missing: "comp.NewOrphan needs comp.Missing",
+			unused:  "n0,n3",
+		},
+		{
+			name: "updater",
+			cons: []string{"NewDB", "NewConfig"},
+			upds: []string{"NewPgStore"},
+			needs: []string{
+				"n0 comp.NewDB: context.Context<-external *comp.Config<-n1",
+				"n1 comp.NewConfig:",
+				"u0 comp.NewPgStore: *comp.DB<-n0",
+			},
+			order: "comp.NewConfig, comp.NewDB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := loadStaticSet(".", "app", compComponents(tt.cons...), compComponents(tt.upds...), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Unused is set when the graph is written out, as lux graph does
+			data, err := json.Marshal(staticGraph(set))
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := provider.Graph{}
+			if err := json.Unmarshal(data, &g); err != nil {
+				t.Fatal(err)
+			}
+
+			needs := []string(nil)
+			unused := []string(nil)
+			for _, c := range g.Constructors {
+				line := c.ID + " " + c.Name + ":"
+				for _, dep := range c.Needs {
+					from := strings.Join(dep.Providers, ",")
+					switch {
+					case dep.External:
+						from = "external"
+					case dep.Missing:
+						from = "missing"
+					case dep.Duplicate:
+						from = "duplicate " + from
+					}
+					line += " " + dep.Type + "<-" + from
+				}
+				needs = append(needs, line)
+				if c.Unused {
+					unused = append(unused, c.ID)
+				}
+			}
+			if got, want := strings.Join(needs, "\n"), strings.Join(tt.needs, "\n"); got != want {
+				t.Errorf("needs:\n%s\nwant:\n%s", got, want)
+			}
+			if got := strings.Join(g.Order(), ", "); got != tt.order {
+				t.Errorf("order = %s, want %s", got, tt.order)
+			}
+			if got := strings.Join(g.Missing(), ", "); got != tt.missing {
+				t.Errorf("missing = %s, want %s", got, tt.missing)
+			}
+			if got := strings.Join(unused, ","); got != tt.unused {
+				t.Errorf("unused = %s, want %s", got, tt.unused)
+			}
+		})
+	}
+}
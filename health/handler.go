@@ -0,0 +1,53 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/lux"
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+// Register serves liveness at livenessRoute and readiness at readinessRoute, e.g. "/healthz"
+// and "/readyz", and fails readiness as soon as l starts shutting down.
+func (h *Health) Register(l *lux.Lux, livenessRoute string, readinessRoute string) {
+	lux.RegisterOnShutdown(l, h.ShutDown)
+	l.AddRestController(livenessRoute, controller.GET, controller.RestController{
+		Handler: func(lc *context.LuxContext) error {
+			return replyReport(lc, h.Liveness(lc.RequestContext))
+		},
+	})
+	l.AddRestController(readinessRoute, controller.GET, controller.RestController{
+		Handler: func(lc *context.LuxContext) error {
+			return replyReport(lc, h.Readiness(lc.RequestContext))
+		},
+	})
+}
+
+// Provide builds a Health discovering checkers in p and serving /healthz and /readyz on l,
+// for registering as a provider constructor.
+func Provide(p *provider.Provider, l *lux.Lux) *Health {
+	h := New(Config{})
+	h.Discover(p)
+	h.Register(l, "/healthz", "/readyz")
+	return h
+}
+
+func replyReport(lc *context.LuxContext, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		lc.SetInternalServerError()
+		return err
+	}
+
+	header := lc.Response.Header()
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "no-store")
+	if report.Status != StatusUp {
+		lc.Response.WriteHeader(http.StatusServiceUnavailable)
+	}
+	lc.Response.Write(body)
+	return nil
+}
@@ -0,0 +1,290 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+const (
+	DefaultTimeout = 2 * time.Second
+
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+type Status string
+
+// HealthChecker is implemented by components that can tell whether they can serve traffic.
+// Components in a provider implementing it are checked by readiness automatically.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// LivenessChecker is implemented by components that can tell whether the process must be
+// restarted. Only these are checked by liveness.
+type LivenessChecker interface {
+	CheckLiveness(ctx context.Context) error
+}
+
+// Named lets a discovered component choose its name in the report instead of its type.
+type Named interface {
+	HealthName() string
+}
+
+type CheckFunc func(ctx context.Context) error
+
+func (f CheckFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+func (f CheckFunc) CheckLiveness(ctx context.Context) error {
+	return f(ctx)
+}
+
+type Config struct {
+	// Timeout bounds each check, DefaultTimeout when zero.
+	Timeout time.Duration
+	// CacheTTL reuses a check's result for this long. Zero runs checks on every probe.
+	CacheTTL time.Duration
+}
+
+type CheckResult struct {
+	Status    Status        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type Health struct {
+	cfg          Config
+	readiness    map[string]HealthChecker
+	liveness     map[string]LivenessChecker
+	providers    []*provider.Provider
+	cache        map[string]*cachedCheck
+	shuttingDown atomic.Bool
+	lock         sync.RWMutex
+}
+
+type cachedCheck struct {
+	result CheckResult
+	lock   sync.Mutex
+}
+
+func New(cfg Config) *Health {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Health{
+		cfg:       cfg,
+		readiness: make(map[string]HealthChecker),
+		liveness:  make(map[string]LivenessChecker),
+		cache:     make(map[string]*cachedCheck),
+	}
+}
+
+// Add checks checker on readiness under name.
+func (h *Health) Add(name string, checker HealthChecker) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.readiness[name] = checker
+}
+
+// AddLiveness checks checker on liveness under name.
+func (h *Health) AddLiveness(name string, checker LivenessChecker) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.liveness[name] = checker
+}
+
+// Discover checks every value of p implementing HealthChecker or LivenessChecker, including
+// the ones provided after this call.
+func (h *Health) Discover(p *provider.Provider) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.providers = append(h.providers, p)
+}
+
+// ShutDown makes readiness fail from now on while liveness keeps passing.
+func (h *Health) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *Health) IsShuttingDown() bool {
+	return h.shuttingDown.Load()
+}
+
+func (h *Health) Readiness(ctx context.Context) Report {
+	h.lock.RLock()
+	checks := make(map[string]func(context.Context) error, len(h.readiness))
+	for name, checker := range h.readiness {
+		checks[name] = checker.CheckHealth
+	}
+	providers := h.providers
+	h.lock.RUnlock()
+
+	discover(providers, checks, HealthChecker.CheckHealth)
+
+	report := h.run(ctx, "readiness", checks)
+	if h.IsShuttingDown() {
+		report.Status = StatusDown
+		report.Checks["shutdown"] = CheckResult{Status: StatusDown, Error: ErrShuttingDown.Error(), CheckedAt: time.Now()}
+	}
+	return report
+}
+
+func (h *Health) Liveness(ctx context.Context) Report {
+	h.lock.RLock()
+	checks := make(map[string]func(context.Context) error, len(h.liveness))
+	for name, checker := range h.liveness {
+		checks[name] = checker.CheckLiveness
+	}
+	providers := h.providers
+	h.lock.RUnlock()
+
+	discover(providers, checks, LivenessChecker.CheckLiveness)
+
+	return h.run(ctx, "liveness", checks)
+}
+
+// run checks concurrently, each under the timeout and through the cache.
+func (h *Health) run(ctx context.Context, kind string, checks map[string]func(context.Context) error) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checks))}
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]CheckResult, len(names))
+	wg := sync.WaitGroup{}
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = h.cached(ctx, kind+"/"+name, checks[name])
+		}(i, name)
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (h *Health) cached(ctx context.Context, key string, check func(context.Context) error) CheckResult {
+	if h.cfg.CacheTTL <= 0 {
+		return h.check(ctx, check)
+	}
+
+	h.lock.Lock()
+	entry, ok := h.cache[key]
+	if !ok {
+		entry = new(cachedCheck)
+		h.cache[key] = entry
+	}
+	h.lock.Unlock()
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	if !entry.result.CheckedAt.IsZero() && time.Since(entry.result.CheckedAt) < h.cfg.CacheTTL {
+		return entry.result
+	}
+	entry.result = h.check(ctx, check)
+	return entry.result
+}
+
+// check runs check without waiting past the timeout for checks ignoring their context.
+func (h *Health) check(ctx context.Context, check func(context.Context) error) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	err := error(nil)
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s: %w", h.cfg.Timeout, ctx.Err())
+	}
+
+	result := CheckResult{
+		Status:    StatusUp,
+		Duration:  time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// discover adds the checks of the components providers hold, named by their HealthName or
+// else by the provider key they are registered under, so named instances of one type are
+// checked apart. Checks added by hand keep their names; a HealthName two components share
+// gets the key of the later one appended.
+func discover[T any](providers []*provider.Provider, checks map[string]func(context.Context) error, check func(T, context.Context) error) {
+	added := make(map[string]struct{}, len(checks))
+	for name := range checks {
+		added[name] = struct{}{}
+	}
+	keys := make(map[string]string)
+
+	for _, p := range providers {
+		collected := provider.CollectKeyed[T](p)
+		sorted := make([]string, 0, len(collected))
+		for key := range collected {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			component := collected[key]
+			name := key
+			if named, ok := any(component).(Named); ok {
+				name = named.HealthName()
+			}
+			if _, ok := added[name]; ok {
+				continue
+			}
+			if other, ok := keys[name]; ok {
+				if other == key {
+					continue
+				}
+				name += " (" + key + ")"
+			}
+			keys[name] = key
+			checks[name] = func(ctx context.Context) error {
+				return check(component, ctx)
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/lux/v3/lux"
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+type testDB struct {
+	err error
+}
+
+func (d *testDB) CheckHealth(context.Context) error {
+	return d.err
+}
+
+type testQueue struct {
+	name string
+}
+
+func (q *testQueue) HealthName() string {
+	return q.name
+}
+
+func (q *testQueue) CheckHealth(context.Context) error {
+	return nil
+}
+
+func (q *testQueue) CheckLiveness(context.Context) error {
+	return errors.New("stuck")
+}
+
+func checkNames(report Report) []string {
+	names := make([]string, 0, len(report.Checks))
+	for name, result := range report.Checks {
+		names = append(names, name+"="+string(result.Status))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		check  CheckFunc
+		status Status
+		err    string
+	}{
+		{"up", Config{}, func(context.Context) error { return nil }, StatusUp, ""},
+		{"down", Config{}, func(context.Context) error { return errors.New("refused") }, StatusDown, "refused"},
+		{"panic", Config{}, func(context.Context) error { panic("oops") }, StatusDown, "check panicked: oops"},
+		{
+			"timeout honoring the context",
+			Config{Timeout: 10 * time.Millisecond},
+			func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+			StatusDown,
+			"context deadline exceeded",
+		},
+		{
+			"timeout ignoring the context",
+			Config{Timeout: 10 * time.Millisecond},
+			func(context.Context) error { time.Sleep(time.Second); return nil },
+			StatusDown,
+			"check timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(tt.cfg)
+			h.Add("check", tt.check)
+			start := time.Now()
+			report := h.Readiness(context.Background())
+			if time.Since(start) > 500*time.Millisecond {
+				t.Errorf("readiness took %s", time.Since(start))
+			}
+			result := report.Checks["check"]
+			if report.Status != tt.status || result.Status != tt.status || !strings.Contains(result.Error, tt.err) {
+				t.Errorf("report %s, check %+v, want %s with %q", report.Status, result, tt.status, tt.err)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name         string
+		constructors []any
+		added        []string
+		readiness    []string
+		liveness     []string
+	}{
+		{
+			name:         "by type",
+			constructors: []any{func() *testDB { return &testDB{} }},
+			readiness:    []string{"*health.testDB=up"},
+			liveness:     []string{},
+		},
+		{
+			name: "named instances",
+			constructors: []any{
+				provider.Annotate(func() *testDB { return &testDB{} }, provider.Named("primary")),
+				provider.Annotate(func() *testDB { return &testDB{err: errors.New("down")} }, provider.Named("replica")),
+			},
+			readiness: []string{`*health.testDB name:"primary"=up`, `*health.testDB name:"replica"=down`},
+			liveness:  []string{},
+		},
+		{
+			name: "health name",
+			constructors: []any{
+				func() *testQueue { return &testQueue{name: "queue"} },
+			},
+			readiness: []string{"queue=up"},
+			liveness:  []string{"queue=down"},
+		},
+		{
+			name: "shared health name",
+			constructors: []any{
+				provider.Annotate(func() *testQueue { return &testQueue{name: "queue"} }, provider.Named("a")),
+				provider.Annotate(func() *testQueue { return &testQueue{name: "queue"} }, provider.Named("b")),
+			},
+			readiness: []string{`queue (*health.testQueue name:"b")=up`, "queue=up"},
+			liveness:  []string{`queue (*health.testQueue name:"b")=down`, "queue=down"},
+		},
+		{
+			name: "provided as an interface too",
+			constructors: []any{
+				provider.Annotate(func() *testDB { return &testDB{} }, provider.As(new(HealthChecker))),
+			},
+			readiness: []string{"*health.testDB=up"},
+			liveness:  []string{},
+		},
+		{
+			name:         "added by hand wins",
+			constructors: []any{func() *testQueue { return &testQueue{name: "queue"} }},
+			added:        []string{"queue"},
+			readiness:    []string{"queue=up"},
+			liveness:     []string{"queue=down"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := provider.New()
+			if err := p.Register(tt.constructors...); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			h := New(Config{})
+			for _, name := range tt.added {
+				h.Add(name, CheckFunc(func(context.Context) error { return nil }))
+			}
+			h.Discover(p)
+
+			if got := checkNames(h.Readiness(context.Background())); strings.Join(got, "|") != strings.Join(tt.readiness, "|") {
+				t.Errorf("readiness = %q, want %q", got, tt.readiness)
+			}
+			if got := checkNames(h.Liveness(context.Background())); strings.Join(got, "|") != strings.Join(tt.liveness, "|") {
+				t.Errorf("liveness = %q, want %q", got, tt.liveness)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		calls int32
+	}{
+		{"uncached", 0, 3},
+		{"cached", time.Minute, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := int32(0)
+			h := New(Config{CacheTTL: tt.ttl})
+			h.Add("db", CheckFunc(func(context.Context) error {
+				atomic.AddInt32(&calls, 1)
+				return nil
+			}))
+			for i := 0; i < 3; i++ {
+				h.Readiness(context.Background())
+			}
+			// readiness and liveness are cached apart
+			h.AddLiveness("db", CheckFunc(func(context.Context) error { return errors.New("dead") }))
+			if report := h.Liveness(context.Background()); report.Status != StatusDown {
+				t.Errorf("liveness answered from the readiness cache")
+			}
+			if calls != tt.calls {
+				t.Errorf("check ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		ready     error
+		shutdown  bool
+		liveness  int
+		readiness int
+	}{
+		{"healthy", nil, false, http.StatusOK, http.StatusOK},
+		{"not ready", errors.New("db down"), false, http.StatusOK, http.StatusServiceUnavailable},
+		{"shutting down", nil, true, http.StatusOK, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lux.New()
+			h := New(Config{})
+			h.Add("db", CheckFunc(func(context.Context) error { return tt.ready }))
+			h.Register(l, "/healthz", "/readyz")
+			if tt.shutdown {
+				h.ShutDown()
+			}
+
+			for route, status := range map[string]int{"/healthz": tt.liveness, "/readyz": tt.readiness} {
+				w := httptest.NewRecorder()
+				l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, route, nil))
+				if w.Code != status {
+					t.Errorf("%s = %d, want %d", route, w.Code, status)
+				}
+				report := Report{}
+				if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+					t.Fatalf("%s: %v", route, err)
+				}
+				if (report.Status == StatusUp) != (status == http.StatusOK) {
+					t.Errorf("%s reported %s with %d", route, report.Status, status)
+				}
+				if w.Header().Get("Cache-Control") != "no-store" {
+					t.Errorf("%s: Cache-Control = %q", route, w.Header().Get("Cache-Control"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package lux
+
+import (
+	"github.com/snowmerak/lux/v3/middleware"
+)
+
+// SetAccessLog runs accessLog, e.g. middleware.AccessLog(cfg), on every REST request ahead of
+// the body limit and the route's own middlewares, so the requests they reject are logged too.
+func SetAccessLog(l *Lux, accessLog middleware.AccessLogMiddleware) {
+	l.accessLog = accessLog
+}
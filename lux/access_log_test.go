@@ -0,0 +1,68 @@
+package lux
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rs/zerolog"
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/middleware"
+)
+
+func TestSetAccessLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		bytes  int
+	}{
+		{"served", "/items", "", http.StatusOK, 5},
+		{"rejected by the body limit", "/items", "0123456789", http.StatusRequestEntityTooLarge, 0},
+		{"rejected by a middleware", "/items?deny=1", "", http.StatusForbidden, 0},
+		{"static", "/files/a.txt", "", http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			logger := zerolog.New(out)
+			l := New()
+			SetErrorHandler(l, func(lc *context.LuxContext, err error) {})
+			SetMaxBodySize(l, 4)
+			SetAccessLog(l, middleware.AccessLog(middleware.AccessLogConfig{Logger: &logger}))
+			l.AddRestController("/items", controller.POST, controller.RestController{
+				RequestMiddlewares: []middleware.Request{func(lc *context.LuxContext) (*context.LuxContext, int) {
+					if lc.GetURLQuery("deny") != "" {
+						return lc, http.StatusForbidden
+					}
+					return lc, http.StatusOK
+				}},
+				Handler: func(lc *context.LuxContext) error { return lc.ReplyString("hello") },
+			})
+			l.ServeStaticWithConfig("/files", fstest.MapFS{"a.txt": {Data: []byte("a")}}, StaticConfig{})
+
+			method := http.MethodPost
+			if strings.HasPrefix(tt.target, "/files") {
+				method = http.MethodGet
+			}
+			l.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, tt.target, strings.NewReader(tt.body)))
+
+			event := struct {
+				Status int
+				Bytes  int
+			}{}
+			if err := json.Unmarshal(out.Bytes(), &event); err != nil {
+				t.Fatalf("%v in %q", err, out)
+			}
+			if event.Status != tt.status || event.Bytes != tt.bytes {
+				t.Errorf("logged %d with %d bytes, want %d with %d", event.Status, event.Bytes, tt.status, tt.bytes)
+			}
+		})
+	}
+}
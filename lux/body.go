@@ -0,0 +1,122 @@
+package lux
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+// SetMaxBodySize limits request bodies of routes without a RestController.MaxBodySize.
+// Zero means unlimited.
+func SetMaxBodySize(l *Lux, n int64) {
+	l.maxBodySize = n
+}
+
+// SetMinBodyRate aborts request bodies arriving slower than bytesPerSecond once grace
+// has passed, answering 408.
+func SetMinBodyRate(l *Lux, bytesPerSecond int64, grace time.Duration) {
+	l.minBodyRate = bytesPerSecond
+	l.minBodyRateGrace = grace
+}
+
+func SetJSONConfig(l *Lux, cfg context.JSONConfig) {
+	l.jsonConfig = cfg
+}
+
+// limitBody applies the body policies of rc to the request of lc. It answers 413 and
+// returns false when Content-Length alone exceeds the limit.
+func (l *Lux) limitBody(w http.ResponseWriter, lc *context.LuxContext, rc *controller.RestController) bool {
+	r := lc.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+
+	limit := rc.MaxBodySize
+	if limit == 0 {
+		limit = l.maxBodySize
+	}
+	if limit > 0 {
+		if r.ContentLength > limit {
+			lc.Response.Header().Set("Connection", "close")
+			lc.Response.WriteHeader(http.StatusRequestEntityTooLarge)
+			l.handleError(lc, &http.MaxBytesError{Limit: limit})
+			return false
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	if l.minBodyRate > 0 {
+		r.Body = &rateReader{
+			ReadCloser: r.Body,
+			controller: http.NewResponseController(w),
+			rate:       l.minBodyRate,
+			grace:      l.minBodyRateGrace,
+			start:      time.Now(),
+		}
+	}
+	return true
+}
+
+// rateReader moves the connection read deadline along with the minimum rate, so a
+// stalled client fails the read instead of holding the handler.
+type rateReader struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	rate       int64
+	grace      time.Duration
+	start      time.Time
+	read       int64
+	noDeadline bool
+	done       bool
+}
+
+func (r *rateReader) Read(p []byte) (int, error) {
+	if r.done {
+		return r.ReadCloser.Read(p)
+	}
+
+	if !r.noDeadline {
+		if err := r.controller.SetReadDeadline(r.deadline(int64(len(p)))); err != nil {
+			r.noDeadline = true
+		}
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return n, context.ErrBodyTooSlow
+	case err != nil:
+		r.finish()
+		return n, err
+	case r.noDeadline && time.Now().After(r.deadline(0)):
+		return n, context.ErrBodyTooSlow
+	}
+	return n, nil
+}
+
+func (r *rateReader) deadline(next int64) time.Time {
+	allowed := time.Duration(float64(r.read+next) / float64(r.rate) * float64(time.Second))
+	return r.start.Add(r.grace + allowed)
+}
+
+// finish lifts the deadline once the body is consumed, since the server keeps reading the
+// connection in the background and a passed deadline would cancel the request context.
+func (r *rateReader) finish() {
+	r.done = true
+	if !r.noDeadline {
+		r.controller.SetReadDeadline(time.Time{})
+	}
+}
+
+func (r *rateReader) Close() error {
+	if !r.done {
+		r.finish()
+	}
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,126 @@
+package lux
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+// onlyReader hides the length of a body, as a chunked request has none.
+type onlyReader struct {
+	io.Reader
+}
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  int64
+		route   int64
+		body    string
+		chunked bool
+		status  int
+		handled bool
+	}{
+		{"unlimited", 0, 0, "0123456789", false, http.StatusOK, true},
+		{"within the server limit", 10, 0, "0123456789", false, http.StatusOK, true},
+		{"content-length above the server limit", 5, 0, "0123456789", false, http.StatusRequestEntityTooLarge, false},
+		{"chunked above the server limit", 5, 0, "0123456789", true, http.StatusRequestEntityTooLarge, true},
+		{"route raises the limit", 5, 20, "0123456789", false, http.StatusOK, true},
+		{"route lowers the limit", 20, 5, "0123456789", false, http.StatusRequestEntityTooLarge, false},
+		{"route without a limit", 5, -1, "0123456789", true, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled := false
+			l := New()
+			SetMaxBodySize(l, tt.server)
+			l.AddRestController("/echo", controller.POST, controller.RestController{
+				MaxBodySize: tt.route,
+				Handler: func(lc *context.LuxContext) error {
+					handled = true
+					body, err := lc.GetBody()
+					if err != nil {
+						return err
+					}
+					return lc.ReplyBinary(body)
+				},
+			})
+
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = onlyReader{body}
+			}
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", body))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if handled != tt.handled {
+				t.Errorf("handler ran %v, want %v", handled, tt.handled)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestMinBodyRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		stall  bool
+		status int
+	}{
+		{"steady", false, http.StatusOK},
+		{"stalled", true, http.StatusRequestTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New()
+			SetMinBodyRate(l, 1024, 100*time.Millisecond)
+			l.AddRestController("/upload", controller.POST, controller.RestController{Handler: func(lc *context.LuxContext) error {
+				if _, err := lc.GetBody(); err != nil {
+					return err
+				}
+				return lc.ReplyString("ok")
+			}})
+			server := httptest.NewServer(l)
+			defer server.Close()
+
+			conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			body := "hello"
+			length := len(body)
+			if tt.stall {
+				length = 1 << 20
+			}
+			if _, err := io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: test\r\nContent-Length: "+strconv.Itoa(length)+"\r\n\r\n"+body); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package lux
+
+import (
+	ctx "context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snowmerak/lux/v3/context"
+)
+
+// Config is the server configuration, e.g. loaded with config.Provide[lux.Config](loader, "server")
+// and applied by NewFromConfig. Zero durations keep the defaults of net/http and lux.
+type Config struct {
+	ListenAddress     string        `config:"listen_address" default:":8080" validate:"required"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" validate:"min=0s"`
+	ReadTimeout       time.Duration `config:"read_timeout" validate:"min=0s"`
+	WriteTimeout      time.Duration `config:"write_timeout" validate:"min=0s"`
+	IdleTimeout       time.Duration `config:"idle_timeout" validate:"min=0s"`
+	MaxHeaderBytes    int           `config:"max_header_bytes" validate:"min=0"`
+	HandlerTimeout    time.Duration `config:"handler_timeout" validate:"min=0s"`
+	ShutdownDelay     time.Duration `config:"shutdown_delay" validate:"min=0s"`
+	ShutdownTimeout   time.Duration `config:"shutdown_timeout" validate:"min=0s"`
+	TLS               TLSConfig     `config:"tls"`
+	JWT               JWTConfig     `config:"jwt"`
+}
+
+// TLSConfig makes ListenAndServeConfig serve TLS when both files are set.
+type TLSConfig struct {
+	CertFile string `config:"cert_file"`
+	KeyFile  string `config:"key_file"`
+}
+
+// Validate fails when only one of the files is set, rather than serving plain HTTP.
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("needs both cert_file and key_file, or neither")
+	}
+	return nil
+}
+
+// JWTConfig sets the JWT signing of context.LuxContext when SigningKey is set, best given in
+// a file, e.g. with APP_SERVER_JWT_SIGNING_KEY_FILE. SigningMethod is one of the HMAC methods.
+type JWTConfig struct {
+	SigningKey    []byte `config:"signing_key"`
+	SigningMethod string `config:"signing_method" default:"HS256"`
+	Domain        string `config:"domain"`
+	Path          string `config:"path" default:"/"`
+}
+
+// NewFromConfig is New with cfg applied.
+func NewFromConfig(cfg Config) (*Lux, error) {
+	l := New()
+	SetReadHeaderTimeout(l, cfg.ReadHeaderTimeout)
+	SetReadTimeout(l, cfg.ReadTimeout)
+	SetWriteTimeout(l, cfg.WriteTimeout)
+	SetIdleTimeout(l, cfg.IdleTimeout)
+	SetMaxHeaderBytes(l, cfg.MaxHeaderBytes)
+	SetHandlerTimeout(l, cfg.HandlerTimeout)
+	SetShutdownDelay(l, cfg.ShutdownDelay)
+	SetShutdownTimeout(l, cfg.ShutdownTimeout)
+
+	if len(cfg.JWT.SigningKey) > 0 {
+		method := jwt.GetSigningMethod(cfg.JWT.SigningMethod)
+		if method == nil {
+			return nil, fmt.Errorf("unknown JWT signing method %q", cfg.JWT.SigningMethod)
+		}
+		// the key is a shared secret, which RSA, ECDSA and EdDSA methods cannot sign with
+		if _, ok := method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("JWT signing method %q is not supported, must be HS256, HS384 or HS512", cfg.JWT.SigningMethod)
+		}
+		SetJWTConfig(l, &context.JWTConfig{
+			SigningKey:    cfg.JWT.SigningKey,
+			SigningMethod: method,
+			Domain:        cfg.JWT.Domain,
+			Path:          cfg.JWT.Path,
+		})
+	}
+	return l, nil
+}
+
+// ListenAddressFrom provides the listen address of cfg to ListenAndServe1 and the like.
+func ListenAddressFrom(cfg Config) ListenAddress {
+	return ListenAddress(cfg.ListenAddress)
+}
+
+// ListenAndServeConfig serves HTTP/1 on the address of cfg, over TLS when cfg sets its files.
+func ListenAndServeConfig(ctx ctx.Context, lx *Lux, cfg Config) error {
+	if err := cfg.TLS.Validate(); err != nil {
+		return fmt.Errorf("tls %w", err)
+	}
+	if cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "" {
+		return lx.ListenAndServe1TLS(ctx, cfg.ListenAddress, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	}
+	return lx.ListenAndServe1(ctx, cfg.ListenAddress)
+}
@@ -0,0 +1,109 @@
+package lux
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snowmerak/lux/v3/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+		err  bool
+	}{
+		{
+			name: "defaults",
+			want: Config{ListenAddress: ":8080", JWT: JWTConfig{SigningMethod: "HS256", Path: "/"}},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"SERVER_LISTEN_ADDRESS":     ":9000",
+				"SERVER_HANDLER_TIMEOUT":    "3s",
+				"SERVER_TLS_CERT_FILE":      "cert.pem",
+				"SERVER_TLS_KEY_FILE":       "key.pem",
+				"SERVER_JWT_SIGNING_METHOD": "HS512",
+			},
+			want: Config{
+				ListenAddress:  ":9000",
+				HandlerTimeout: 3 * time.Second,
+				TLS:            TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"},
+				JWT:            JWTConfig{SigningMethod: "HS512", Path: "/"},
+			},
+		},
+		{
+			name: "cert without key",
+			env:  map[string]string{"SERVER_TLS_CERT_FILE": "cert.pem"},
+			err:  true,
+		},
+		{
+			name: "negative timeout",
+			env:  map[string]string{"SERVER_READ_TIMEOUT": "-1s"},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := config.New()
+			config.SetArgs(l, nil)
+			config.SetLookupEnv(l, func(name string) (string, bool) {
+				v, ok := tt.env[name]
+				return v, ok
+			})
+
+			got, err := config.Load[Config](l, "server")
+			if tt.err {
+				var validation config.ErrValidation
+				if !errors.As(err, &validation) {
+					t.Errorf("Load = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ListenAddress != tt.want.ListenAddress || got.HandlerTimeout != tt.want.HandlerTimeout || got.TLS != tt.want.TLS || got.JWT.SigningMethod != tt.want.JWT.SigningMethod || got.JWT.Path != tt.want.JWT.Path {
+				t.Errorf("Load = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		jwt    JWTConfig
+		method jwt.SigningMethod
+		err    bool
+	}{
+		{name: "no key"},
+		{name: "hmac", jwt: JWTConfig{SigningKey: []byte("secret"), SigningMethod: "HS384", Path: "/"}, method: jwt.SigningMethodHS384},
+		{name: "unknown method", jwt: JWTConfig{SigningKey: []byte("secret"), SigningMethod: "HS1"}, err: true},
+		{name: "asymmetric method", jwt: JWTConfig{SigningKey: []byte("secret"), SigningMethod: "RS256"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewFromConfig(Config{ListenAddress: ":8080", HandlerTimeout: time.Second, JWT: tt.jwt})
+			if (err != nil) != tt.err {
+				t.Fatalf("NewFromConfig = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if l.handlerTimeout != time.Second {
+				t.Errorf("handler timeout = %v, want 1s", l.handlerTimeout)
+			}
+			switch {
+			case tt.method == nil && l.jwtConfig != nil:
+				t.Errorf("JWT config set without a key")
+			case tt.method != nil && (l.jwtConfig == nil || l.jwtConfig.SigningMethod != tt.method || string(l.jwtConfig.SigningKey) != "secret"):
+				t.Errorf("JWT config = %+v, want %s", l.jwtConfig, tt.method.Alg())
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package lux
+
+import (
+	"github.com/snowmerak/lux/v3/context"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorHandler receives every error returned by a REST controller, and timeouts, before
+// the response is written. It may rewrite lc.Response, e.g. to render an error body.
+type ErrorHandler func(lc *context.LuxContext, err error)
+
+func SetErrorHandler(l *Lux, handler ErrorHandler) {
+	l.errorHandler = handler
+}
+
+// handleError answers body errors the handler left unanswered before calling the ErrorHandler.
+func (l *Lux) handleError(lc *context.LuxContext, err error) {
+	if status := context.BodyErrorStatus(err, 0); status != 0 && lc.IsOk() {
+		lc.Response.WriteHeader(status)
+	}
+	trace.SpanFromContext(lc.RequestContext).RecordError(err)
+	l.metrics.errors.With(lc.Route, lc.Request.Method).Inc()
+	l.errorHandler(lc, err)
+}
+
+func (l *Lux) logError(lc *context.LuxContext, err error) {
+	l.logger.Error().Str("error", err.Error()).Str("route", lc.Route).Str("method", lc.Request.Method).Int("status", lc.Response.StatusCode).Msg("Controller error")
+}
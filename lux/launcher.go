@@ -1,6 +1,10 @@
 package lux
 
-import "context"
+import (
+	"context"
+
+	"github.com/snowmerak/lux/v3/provider"
+)
 
 type ListenAddress string
 
@@ -37,3 +41,19 @@ func ListenAndServe2AudoTLS(addr ...string) func(ctx context.Context, lx *Lux) e
 		return lx.ListenAndServe2AutoTLS(ctx, addr)
 	}
 }
+
+// ManageLifecycle starts the components of p and stops them in reverse order once lx has
+// shut down. It also opens a request scope of p per request. Run it before ListenAndServe,
+// e.g. provider.JustRun(p, lux.ManageLifecycle).
+func ManageLifecycle(ctx context.Context, lx *Lux, p *provider.Provider) error {
+	SetRequestScope(lx, p)
+	if err := p.Start(ctx); err != nil {
+		return err
+	}
+	RegisterAfterShutdown(lx, func() {
+		if err := p.Stop(context.Background()); err != nil {
+			lx.logger.Error().Str("error", err.Error()).Msg("Provider stop error")
+		}
+	})
+	return nil
+}
@@ -2,12 +2,14 @@ package lux
 
 import (
 	ctx "context"
-	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
@@ -15,6 +17,11 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/snowmerak/lux/v3/context"
 	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/metrics"
+	"github.com/snowmerak/lux/v3/middleware"
+	"github.com/snowmerak/lux/v3/provider"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/http2"
 )
 
@@ -24,14 +31,51 @@ type Lux struct {
 	builtRouter *httprouter.Router
 	jwtConfig   *context.JWTConfig
 	ctx         ctx.Context
+
+	errorHandler     ErrorHandler
+	handlerTimeout   time.Duration
+	maxClientTimeout time.Duration
+
+	maxBodySize      int64
+	minBodyRate      int64
+	minBodyRateGrace time.Duration
+	jsonConfig       context.JSONConfig
+
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+
+	metricsRegistry *metrics.Registry
+	metrics         *serverMetrics
+
+	scopeProvider *provider.Provider
+	accessLog     middleware.AccessLogMiddleware
+
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+	onShutdown      []func()
+	afterShutdown   []func()
+	shutdownOnce    sync.Once
+	shutdownDone    chan struct{}
+	shutdownErr     error
+	shutdownLock    sync.Mutex
+
+	socketHooks  []SocketHook
+	socketActive atomic.Int64
+	socketTotal  atomic.Uint64
 }
 
 func New() *Lux {
-	return &Lux{
+	l := &Lux{
 		logger:      &log.Logger,
 		server:      new(http.Server),
 		builtRouter: httprouter.New(),
 	}
+	l.errorHandler = l.logError
+	l.metricsRegistry = metrics.NewRegistry()
+	l.metrics = newServerMetrics(l.metricsRegistry)
+	l.socketHooks = []SocketHook{l.metrics}
+	l.shutdownDone = make(chan struct{})
+	return l
 }
 
 func SetLogger(l *Lux, logger *zerolog.Logger) {
@@ -64,39 +108,131 @@ func SetJWTConfig(l *Lux, cfg *context.JWTConfig) {
 
 func (l *Lux) AddRestController(route string, method controller.Method, controller controller.RestController) {
 	l.builtRouter.Handle(string(method), route, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		luxCtx := new(context.LuxContext)
-		luxCtx.Request = r
-		luxCtx.Response = context.NewResponse()
-		luxCtx.RouteParams = p
-		luxCtx.Context = l.ctx
-		luxCtx.RequestContext = r.Context()
-		luxCtx.Logger = l.logger
-
-		if err := controller.Serve(luxCtx); err != nil {
-			l.logger.Error().Str("error", err.Error()).Msg("Controller error")
-		}
-
-		for key, values := range luxCtx.Response.Headers {
-			for _, value := range values {
-				w.Header().Add(key, value)
+		l.handle(route, w, r, p, func(w http.ResponseWriter, luxCtx *context.LuxContext) *context.LuxContext {
+			if l.limitBody(w, luxCtx, &controller) {
+				luxCtx = l.serve(luxCtx, &controller)
 			}
-		}
-		w.WriteHeader(luxCtx.Response.StatusCode)
-		w.Write(luxCtx.Response.Body)
+			for key, values := range luxCtx.Response.Headers {
+				for _, value := range values {
+					w.Header().Add(key, value)
+				}
+			}
+			luxCtx.BytesWritten = writeResponse(w, luxCtx.Response)
+			return luxCtx
+		})
 	})
 }
 
+// handle serves a request of route with what every route gets: metrics, tracing, the request
+// scope and the access log. serve writes the response and returns the context it wrote, with
+// the status in Response and BytesWritten set.
+func (l *Lux) handle(route string, w http.ResponseWriter, r *http.Request, p httprouter.Params, serve func(w http.ResponseWriter, lc *context.LuxContext) *context.LuxContext) {
+	record := l.metrics.requestStarted(route, r.Method)
+	defer record.finish()
+
+	luxCtx := l.newLuxContext(route, r, p)
+	span := l.startRequestSpan(luxCtx)
+	l.beginScope(luxCtx)
+	if l.accessLog != nil {
+		luxCtx, _ = l.accessLog(luxCtx)
+	}
+
+	// ended in a defer so that a panicking handler still ends its span and closes its scope
+	defer func() {
+		panicked := recover()
+		if panicked != nil {
+			luxCtx.Response.StatusCode = http.StatusInternalServerError
+		}
+		endRequestSpan(span, luxCtx)
+		record.status = luxCtx.Response.StatusCode
+		record.size = luxCtx.BytesWritten
+		luxCtx.Complete()
+		if panicked != nil {
+			panic(panicked)
+		}
+	}()
+	luxCtx = serve(w, luxCtx)
+}
+
+func (l *Lux) serve(luxCtx *context.LuxContext, controller *controller.RestController) *context.LuxContext {
+	if timeout, fromClient := l.timeoutOf(controller, luxCtx.Request); timeout > 0 {
+		return l.serveWithTimeout(luxCtx, controller, timeout, fromClient)
+	}
+	if err := controller.Serve(luxCtx); err != nil {
+		l.handleError(luxCtx, err)
+	}
+	return luxCtx
+}
+
+func (l *Lux) newLuxContext(route string, r *http.Request, p httprouter.Params) *context.LuxContext {
+	luxCtx := new(context.LuxContext)
+	luxCtx.Route = route
+	luxCtx.Request = r
+	luxCtx.Response = context.NewResponse()
+	luxCtx.RouteParams = p
+	luxCtx.Context = l.ctx
+	luxCtx.RequestContext = r.Context()
+	luxCtx.Logger = l.logger
+	luxCtx.JSONConfig = l.jsonConfig
+	luxCtx.JWTConfig = l.jwtConfig
+	luxCtx.StartTime = time.Now()
+	return luxCtx
+}
+
+// writeResponse writes res to w and returns how many body bytes got through.
+func writeResponse(w http.ResponseWriter, res *context.Response) int64 {
+	if closer, ok := res.Stream.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	w.WriteHeader(res.StatusCode)
+	n, _ := w.Write(res.Body)
+	written := int64(n)
+	if res.Stream != nil {
+		copied, _ := io.Copy(w, res.Stream)
+		written += copied
+	}
+	return written
+}
+
 func (l *Lux) AddSocketController(route string, controller controller.SocketController) {
 	l.builtRouter.Handle(http.MethodGet, route, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		info := SocketInfo{
+			Route:      route,
+			RemoteAddr: r.RemoteAddr,
+			Request:    r,
+		}
+
+		defer l.metrics.recordPanic(route)
+
+		r, span := l.startSocketSpan(r, info)
+		info.Request = r
+
 		conn, _, _, err := ws.UpgradeHTTP(r, w)
 		if err != nil {
-			l.logger.Error().Str("error", err.Error()).Msg("Socket upgrade error")
+			l.socketUpgraded(info, err)
+			endSocketUpgradeSpan(span, err)
 			return
 		}
+		defer conn.Close()
 
-		if err := controller.Serve(conn); err != nil && errors.Is(err, wsutil.ClosedError{}) {
-			l.logger.Error().Str("error", err.Error()).Msg("Socket controller error")
-		}
+		info.ID = l.socketTotal.Add(1)
+		l.socketUpgraded(info, nil)
+		l.socketOpened(info)
+		// closed in a defer so that a panicking controller still lowers the active count
+		defer func() {
+			p := recover()
+			if p != nil {
+				err = fmt.Errorf("socket controller panic: %v", p)
+			}
+			l.socketClosed(info, err)
+			endSocketSpan(span, info, err)
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		err = controller.ServeContext(r.Context(), conn)
 	})
 }
 
@@ -113,7 +249,7 @@ func (l *Lux) buildServer(_ ctx.Context, addr string) {
 func (l *Lux) ListenAndServe1(ctx ctx.Context, addr string) error {
 	l.buildServer(ctx, addr)
 	l.ctx = ctx
-	if err := l.server.ListenAndServe(); err != nil {
+	if err := l.serveUntilDone(ctx, l.server.ListenAndServe); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Listen and serve error")
 		return err
 	}
@@ -123,7 +259,7 @@ func (l *Lux) ListenAndServe1(ctx ctx.Context, addr string) error {
 func (l *Lux) ListenAndServe1TLS(ctx ctx.Context, addr string, certFile string, keyFile string) error {
 	l.buildServer(ctx, addr)
 	l.ctx = ctx
-	if err := l.server.ListenAndServeTLS(certFile, keyFile); err != nil {
+	if err := l.serveUntilDone(ctx, func() error { return l.server.ListenAndServeTLS(certFile, keyFile) }); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Listen and serve TLS error")
 		return err
 	}
@@ -134,15 +270,31 @@ func (l *Lux) ListenAndServe1AutoTLS(ctx ctx.Context, addr []string) error {
 	if len(addr) == 0 {
 		addr = []string{"localhost:443"}
 	}
-	l.buildServer(ctx, addr[0])
+	if err := l.buildAutoTLSServer(ctx, addr); err != nil {
+		l.logger.Fatal().Str("error", err.Error()).Msg("Auto TLS configuration error")
+		return err
+	}
 	l.ctx = ctx
-	if err := certmagic.HTTPS(addr, l.builtRouter); err != nil {
+	if err := l.serveUntilDone(ctx, func() error { return l.server.ListenAndServeTLS("", "") }); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Listen and serve Auto TLS error")
 		return err
 	}
 	return nil
 }
 
+// buildAutoTLSServer serves on the HTTPS port with certificates certmagic obtains and renews
+// for domains. They are solved by the TLS-ALPN challenge on that port, so no plain HTTP
+// listener is left running outside the server's shutdown.
+func (l *Lux) buildAutoTLSServer(ctx ctx.Context, domains []string) error {
+	tlsConfig, err := certmagic.TLS(domains)
+	if err != nil {
+		return err
+	}
+	l.buildServer(ctx, fmt.Sprintf(":%d", certmagic.HTTPSPort))
+	l.server.TLSConfig = tlsConfig
+	return nil
+}
+
 func (l *Lux) ListenAndServe2(ctx ctx.Context, addr string) error {
 	l.buildServer(ctx, addr)
 	l.ctx = ctx
@@ -150,7 +302,7 @@ func (l *Lux) ListenAndServe2(ctx ctx.Context, addr string) error {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Http2 configuration error")
 		return err
 	}
-	if err := l.server.ListenAndServe(); err != nil {
+	if err := l.serveUntilDone(ctx, l.server.ListenAndServe); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Listen and serve http2 error")
 		return err
 	}
@@ -164,7 +316,7 @@ func (l *Lux) ListenAndServe2TLS(ctx ctx.Context, addr string, certFile string,
 		l.logger.Fatal().Str("error", err.Error()).Msg("Http2 configuration error")
 		return err
 	}
-	if err := l.server.ListenAndServeTLS(certFile, keyFile); err != nil {
+	if err := l.serveUntilDone(ctx, func() error { return l.server.ListenAndServeTLS(certFile, keyFile) }); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Listen and serve http2 TLS error")
 		return err
 	}
@@ -175,13 +327,16 @@ func (l *Lux) ListenAndServe2AutoTLS(ctx ctx.Context, addr []string) error {
 	if len(addr) == 0 {
 		addr = []string{"localhost:443"}
 	}
-	l.buildServer(ctx, addr[0])
+	if err := l.buildAutoTLSServer(ctx, addr); err != nil {
+		l.logger.Fatal().Str("error", err.Error()).Msg("Auto TLS configuration error")
+		return err
+	}
 	l.ctx = ctx
 	if err := http2.ConfigureServer(l.server, nil); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Http2 configuration error")
 		return err
 	}
-	if err := certmagic.HTTPS(addr, l.builtRouter); err != nil {
+	if err := l.serveUntilDone(ctx, func() error { return l.server.ListenAndServeTLS("", "") }); err != nil {
 		l.logger.Fatal().Str("error", err.Error()).Msg("Listen and serve http2 Auto TLS error")
 		return err
 	}
@@ -0,0 +1,118 @@
+package lux
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/metrics"
+	"github.com/snowmerak/lux/v3/middleware"
+)
+
+type serverMetrics struct {
+	requests        *metrics.CounterVec
+	duration        *metrics.HistogramVec
+	responseSize    *metrics.HistogramVec
+	inFlight        *metrics.GaugeVec
+	errors          *metrics.CounterVec
+	panics          *metrics.CounterVec
+	sockets         *metrics.GaugeVec
+	socketsTotal    *metrics.CounterVec
+	socketFailures  *metrics.CounterVec
+	upgradeFailures *metrics.CounterVec
+}
+
+func newServerMetrics(registry *metrics.Registry) *serverMetrics {
+	return &serverMetrics{
+		requests:        registry.NewCounterVec("lux_http_requests_total", "REST requests served.", "route", "method", "status"),
+		duration:        registry.NewHistogramVec("lux_http_request_duration_seconds", "Time to serve REST requests.", metrics.DefaultBuckets, "route", "method", "status"),
+		responseSize:    registry.NewHistogramVec("lux_http_response_size_bytes", "Size of REST response bodies.", metrics.SizeBuckets, "route", "method", "status"),
+		inFlight:        registry.NewGaugeVec("lux_http_requests_in_flight", "REST requests being served.", "route", "method"),
+		errors:          registry.NewCounterVec("lux_http_errors_total", "Errors returned by REST controllers, timeouts included.", "route", "method"),
+		panics:          registry.NewCounterVec("lux_panics_total", "Panics in REST and socket controllers.", "route"),
+		sockets:         registry.NewGaugeVec("lux_websocket_connections", "Open websocket connections.", "route"),
+		socketsTotal:    registry.NewCounterVec("lux_websocket_connections_total", "Websocket connections opened.", "route"),
+		socketFailures:  registry.NewCounterVec("lux_websocket_errors_total", "Websocket sessions ended by an error.", "route"),
+		upgradeFailures: registry.NewCounterVec("lux_websocket_upgrade_errors_total", "Failed websocket upgrades.", "route"),
+	}
+}
+
+// Metrics returns the registry lux records into, for adding application metrics.
+func (l *Lux) Metrics() *metrics.Registry {
+	return l.metricsRegistry
+}
+
+// AddMetricsController exposes every metric of the server in Prometheus text format at route.
+func (l *Lux) AddMetricsController(route string, middlewares ...middleware.Request) {
+	l.AddRestController(route, controller.GET, controller.RestController{
+		RequestMiddlewares: middlewares,
+		Handler: func(lc *context.LuxContext) error {
+			lc.Response.Header().Set("Content-Type", metrics.ContentType)
+			lc.Response.Header().Set("Cache-Control", "no-store")
+			return l.metricsRegistry.WriteText(lc.Response)
+		},
+	})
+}
+
+type requestRecord struct {
+	metrics *serverMetrics
+	route   string
+	method  string
+	start   time.Time
+	status  int
+	size    int64
+}
+
+func (m *serverMetrics) requestStarted(route string, method string) *requestRecord {
+	m.inFlight.With(route, method).Inc()
+	return &requestRecord{
+		metrics: m,
+		route:   route,
+		method:  method,
+		start:   time.Now(),
+	}
+}
+
+// finish must be deferred, it counts the request or the panic that ended it.
+func (r *requestRecord) finish() {
+	m := r.metrics
+	m.inFlight.With(r.route, r.method).Dec()
+	if p := recover(); p != nil {
+		m.panics.With(r.route).Inc()
+		panic(p)
+	}
+
+	code := strconv.Itoa(r.status)
+	m.requests.With(r.route, r.method, code).Inc()
+	m.duration.With(r.route, r.method, code).Observe(time.Since(r.start).Seconds())
+	m.responseSize.With(r.route, r.method, code).Observe(float64(r.size))
+}
+
+// recordPanic counts a panic in progress and lets it continue.
+func (m *serverMetrics) recordPanic(route string) {
+	if p := recover(); p != nil {
+		m.panics.With(route).Inc()
+		panic(p)
+	}
+}
+
+func (m *serverMetrics) OnSocketUpgrade(info SocketInfo, err error) {
+	if err != nil {
+		m.upgradeFailures.With(info.Route).Inc()
+	}
+}
+
+func (m *serverMetrics) OnSocketOpen(info SocketInfo) {
+	m.sockets.With(info.Route).Inc()
+	m.socketsTotal.With(info.Route).Inc()
+}
+
+func (m *serverMetrics) OnSocketClose(info SocketInfo, _ ws.StatusCode, _ string) {
+	m.sockets.With(info.Route).Dec()
+}
+
+func (m *serverMetrics) OnSocketError(info SocketInfo, _ error) {
+	m.socketFailures.With(info.Route).Inc()
+}
@@ -0,0 +1,57 @@
+package lux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+func TestServerMetrics(t *testing.T) {
+	l := New()
+	SetErrorHandler(l, func(lc *context.LuxContext, err error) {})
+	l.AddRestController("/ok", controller.GET, controller.RestController{Handler: func(lc *context.LuxContext) error {
+		return lc.ReplyString("hello")
+	}})
+	l.AddRestController("/fail", controller.GET, controller.RestController{Handler: func(lc *context.LuxContext) error {
+		lc.SetInternalServerError()
+		return errors.New("boom")
+	}})
+	l.AddRestController("/panic", controller.GET, controller.RestController{Handler: func(lc *context.LuxContext) error {
+		panic("boom")
+	}})
+	l.AddMetricsController("/metrics")
+
+	for _, target := range []string{"/ok", "/ok", "/fail", "/panic"} {
+		func() {
+			defer func() { recover() }()
+			l.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		}()
+	}
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	exposition := w.Body.String()
+
+	tests := []string{
+		`lux_http_requests_total{route="/ok",method="GET",status="200"} 2`,
+		`lux_http_requests_total{route="/fail",method="GET",status="500"} 1`,
+		`lux_http_request_duration_seconds_count{route="/ok",method="GET",status="200"} 2`,
+		`lux_http_response_size_bytes_sum{route="/ok",method="GET",status="200"} 10`,
+		`lux_http_requests_in_flight{route="/ok",method="GET"} 0`,
+		`lux_http_requests_in_flight{route="/metrics",method="GET"} 1`,
+		`lux_http_errors_total{route="/fail",method="GET"} 1`,
+		`lux_panics_total{route="/panic"} 1`,
+	}
+	for _, sample := range tests {
+		if !strings.Contains(exposition, sample+"\n") {
+			t.Errorf("missing %s", sample)
+		}
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+}
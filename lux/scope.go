@@ -0,0 +1,85 @@
+package lux
+
+import (
+	ctx "context"
+	"sync"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+// SetRequestScope opens a scope of p for every request, resolved from with context.Resolve or
+// provider.ScopeFrom(lc.RequestContext) and closed once the response has been written, so
+// request scoped values live as long as their request.
+func SetRequestScope(l *Lux, p *provider.Provider) {
+	l.scopeProvider = p
+}
+
+func (l *Lux) beginScope(lc *context.LuxContext) {
+	if l.scopeProvider == nil {
+		return
+	}
+
+	// contexts detached from the request, e.g. for background work, get a scope of their own
+	lc.OnDetach(l.openScope)
+	l.openScope(lc)
+}
+
+func (l *Lux) openScope(lc *context.LuxContext) {
+	scope := &requestScope{scope: l.scopeProvider.NewScope(lc.RequestContext)}
+	lc.RequestContext = ctx.WithValue(lc.RequestContext, requestScopeKey{}, scope)
+	lc.RequestContext = context.WithScope(provider.WithScope(lc.RequestContext, scope.scope), scope.scope)
+	lc.Request = lc.Request.WithContext(lc.RequestContext)
+	lc.OnComplete(scope.complete)
+}
+
+type requestScopeKey struct{}
+
+// requestScope closes the scope of a request once the request is complete and no handler
+// still runs with it, as one that timed out may.
+type requestScope struct {
+	scope     *provider.Scope
+	running   int
+	completed *context.LuxContext
+	lock      sync.Mutex
+}
+
+// holdScope keeps the scope of lc open until release is called, if lc has one.
+func holdScope(lc *context.LuxContext) (release func()) {
+	scope, ok := lc.RequestContext.Value(requestScopeKey{}).(*requestScope)
+	if !ok {
+		return func() {}
+	}
+
+	scope.lock.Lock()
+	scope.running++
+	scope.lock.Unlock()
+	return func() {
+		scope.lock.Lock()
+		scope.running--
+		completed := scope.completed
+		if scope.running > 0 {
+			completed = nil
+		}
+		scope.lock.Unlock()
+		if completed != nil {
+			scope.close(completed)
+		}
+	}
+}
+
+func (s *requestScope) complete(lc *context.LuxContext) {
+	s.lock.Lock()
+	s.completed = lc
+	running := s.running
+	s.lock.Unlock()
+	if running == 0 {
+		s.close(lc)
+	}
+}
+
+func (s *requestScope) close(lc *context.LuxContext) {
+	if err := s.scope.Close(ctx.Background()); err != nil {
+		lc.Logger.Error().Str("error", err.Error()).Str("route", lc.Route).Msg("Request scope close error")
+	}
+}
@@ -0,0 +1,82 @@
+package lux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+type requestTx struct{ id int }
+
+func TestRequestScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scoped bool
+		bodies []string
+		closed []int
+	}{
+		{"scoped", true, []string{"1 1", "2 2"}, []int{1, 2}},
+		{"no scope", false, []string{"no request scope", "no request scope"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock := sync.Mutex{}
+			opened, closed := 0, []int(nil)
+			p := provider.New()
+			err := p.Register(provider.Annotate(func() (*requestTx, func()) {
+				lock.Lock()
+				defer lock.Unlock()
+				opened++
+				tx := &requestTx{id: opened}
+				return tx, func() {
+					lock.Lock()
+					defer lock.Unlock()
+					closed = append(closed, tx.id)
+				}
+			}, provider.WithLifetime(provider.RequestScoped)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l := New()
+			if tt.scoped {
+				SetRequestScope(l, p)
+			}
+			l.AddRestController("/tx", controller.GET, controller.RestController{Handler: func(lc *context.LuxContext) error {
+				first, err := context.Resolve[*requestTx](lc)
+				if errors.Is(err, context.ErrNoScope) {
+					return lc.ReplyString(err.Error())
+				}
+				if err != nil {
+					return err
+				}
+				second, err := context.Resolve[*requestTx](lc)
+				if err != nil {
+					return err
+				}
+				return lc.ReplyString(strconv.Itoa(first.id) + " " + strconv.Itoa(second.id))
+			}})
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tx", nil))
+				if w.Body.String() != tt.bodies[i] {
+					t.Errorf("body = %q, want %q", w.Body.String(), tt.bodies[i])
+				}
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if len(closed) != len(tt.closed) || len(closed) == 2 && (closed[0] != 1 || closed[1] != 2) {
+				t.Errorf("closed = %v, want %v", closed, tt.closed)
+			}
+		})
+	}
+}
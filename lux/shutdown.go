@@ -0,0 +1,97 @@
+package lux
+
+import (
+	ctx "context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+// SetShutdownDelay keeps serving this long after shutdown begins, so load balancers can
+// notice failing readiness before connections are refused.
+func SetShutdownDelay(l *Lux, duration time.Duration) {
+	l.shutdownDelay = duration
+}
+
+// SetShutdownTimeout bounds how long in-flight requests may finish, DefaultShutdownTimeout by default.
+func SetShutdownTimeout(l *Lux, duration time.Duration) {
+	l.shutdownTimeout = duration
+}
+
+// RegisterOnShutdown calls fn as soon as shutdown begins, before the shutdown delay.
+func RegisterOnShutdown(l *Lux, fn func()) {
+	l.shutdownLock.Lock()
+	defer l.shutdownLock.Unlock()
+	l.onShutdown = append(l.onShutdown, fn)
+}
+
+// RegisterAfterShutdown calls fn once the server has stopped and in-flight requests are done,
+// in reverse order of registration.
+func RegisterAfterShutdown(l *Lux, fn func()) {
+	l.shutdownLock.Lock()
+	defer l.shutdownLock.Unlock()
+	l.afterShutdown = append(l.afterShutdown, fn)
+}
+
+// Shutdown stops the server gracefully. The server also shuts down when the context given
+// to ListenAndServe is done. Calling it more than once waits for the first call.
+func (l *Lux) Shutdown() error {
+	l.shutdownOnce.Do(func() {
+		defer close(l.shutdownDone)
+
+		l.shutdownLock.Lock()
+		hooks := append([]func(){}, l.onShutdown...)
+		afterHooks := append([]func(){}, l.afterShutdown...)
+		l.shutdownLock.Unlock()
+		defer func() {
+			for i := len(afterHooks) - 1; i >= 0; i-- {
+				afterHooks[i]()
+			}
+		}()
+
+		l.logger.Info().Dur("delay", l.shutdownDelay).Msg("Server is shutting down")
+		for _, hook := range hooks {
+			hook()
+		}
+		time.Sleep(l.shutdownDelay)
+
+		timeout := l.shutdownTimeout
+		if timeout <= 0 {
+			timeout = DefaultShutdownTimeout
+		}
+		shutdownCtx, cancel := ctx.WithTimeout(ctx.Background(), timeout)
+		defer cancel()
+		l.shutdownErr = l.server.Shutdown(shutdownCtx)
+		if l.shutdownErr != nil {
+			l.logger.Error().Str("error", l.shutdownErr.Error()).Msg("Server shutdown error")
+			l.server.Close()
+			return
+		}
+		l.logger.Info().Msg("Server is shut down")
+	})
+	<-l.shutdownDone
+	return l.shutdownErr
+}
+
+// serveUntilDone runs listen until it fails or c is done, in which case it returns nil
+// after shutting down gracefully.
+func (l *Lux) serveUntilDone(c ctx.Context, listen func() error) error {
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-c.Done():
+			l.Shutdown()
+		case <-stopped:
+		}
+	}()
+
+	err := listen()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-l.shutdownDone
+		return nil
+	}
+	return err
+}
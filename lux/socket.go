@@ -0,0 +1,132 @@
+package lux
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+type SocketInfo struct {
+	ID         uint64
+	Route      string
+	RemoteAddr string
+	Request    *http.Request
+}
+
+type SocketHook interface {
+	OnSocketUpgrade(info SocketInfo, err error)
+	OnSocketOpen(info SocketInfo)
+	OnSocketClose(info SocketInfo, code ws.StatusCode, reason string)
+	OnSocketError(info SocketInfo, err error)
+}
+
+// SocketHookFuncs adapts plain functions to SocketHook. Nil fields are skipped.
+type SocketHookFuncs struct {
+	Upgrade func(info SocketInfo, err error)
+	Open    func(info SocketInfo)
+	Close   func(info SocketInfo, code ws.StatusCode, reason string)
+	Error   func(info SocketInfo, err error)
+}
+
+func (f SocketHookFuncs) OnSocketUpgrade(info SocketInfo, err error) {
+	if f.Upgrade != nil {
+		f.Upgrade(info, err)
+	}
+}
+
+func (f SocketHookFuncs) OnSocketOpen(info SocketInfo) {
+	if f.Open != nil {
+		f.Open(info)
+	}
+}
+
+func (f SocketHookFuncs) OnSocketClose(info SocketInfo, code ws.StatusCode, reason string) {
+	if f.Close != nil {
+		f.Close(info, code, reason)
+	}
+}
+
+func (f SocketHookFuncs) OnSocketError(info SocketInfo, err error) {
+	if f.Error != nil {
+		f.Error(info, err)
+	}
+}
+
+func AddSocketHook(l *Lux, hook SocketHook) {
+	l.socketHooks = append(l.socketHooks, hook)
+}
+
+type SocketStats struct {
+	Active int64
+	Total  uint64
+}
+
+func (l *Lux) SocketStats() SocketStats {
+	return SocketStats{
+		Active: l.socketActive.Load(),
+		Total:  l.socketTotal.Load(),
+	}
+}
+
+func (l *Lux) socketUpgraded(info SocketInfo, err error) {
+	if err != nil {
+		l.logger.Error().Str("error", err.Error()).Str("route", info.Route).Str("remote", info.RemoteAddr).Msg("Socket upgrade error")
+	} else {
+		l.logger.Debug().Uint64("id", info.ID).Str("route", info.Route).Str("remote", info.RemoteAddr).Msg("Socket upgraded")
+	}
+	for _, hook := range l.socketHooks {
+		hook.OnSocketUpgrade(info, err)
+	}
+}
+
+func (l *Lux) socketOpened(info SocketInfo) {
+	l.socketActive.Add(1)
+	l.logger.Debug().Uint64("id", info.ID).Str("route", info.Route).Str("remote", info.RemoteAddr).Msg("Socket opened")
+	for _, hook := range l.socketHooks {
+		hook.OnSocketOpen(info)
+	}
+}
+
+func (l *Lux) socketClosed(info SocketInfo, err error) {
+	l.socketActive.Add(-1)
+
+	code, reason, failed := socketCloseStatus(err)
+	if failed {
+		l.logger.Error().Str("error", err.Error()).Uint64("id", info.ID).Str("route", info.Route).Str("remote", info.RemoteAddr).Msg("Socket controller error")
+		for _, hook := range l.socketHooks {
+			hook.OnSocketError(info, err)
+		}
+	}
+
+	l.logger.Debug().Uint64("id", info.ID).Str("route", info.Route).Str("remote", info.RemoteAddr).Uint16("code", uint16(code)).Str("reason", reason).Msg("Socket closed")
+	for _, hook := range l.socketHooks {
+		hook.OnSocketClose(info, code, reason)
+	}
+}
+
+// socketCloseStatus maps the error returned by a socket handler to the close code
+// seen on the wire and reports whether it was a real failure rather than a close.
+func socketCloseStatus(err error) (ws.StatusCode, string, bool) {
+	if err == nil {
+		return ws.StatusNormalClosure, "", false
+	}
+
+	closed := wsutil.ClosedError{}
+	if errors.As(err, &closed) {
+		switch closed.Code {
+		case ws.StatusNormalClosure, ws.StatusGoingAway, ws.StatusNoStatusRcvd:
+			return closed.Code, closed.Reason, false
+		}
+		return closed.Code, closed.Reason, true
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return ws.StatusAbnormalClosure, "", false
+	}
+
+	return ws.StatusInternalServerError, err.Error(), true
+}
@@ -0,0 +1,166 @@
+package lux
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+func TestSocketCloseStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   ws.StatusCode
+		reason string
+		failed bool
+	}{
+		{"nil", nil, ws.StatusNormalClosure, "", false},
+		{"normal close", wsutil.ClosedError{Code: ws.StatusNormalClosure, Reason: "bye"}, ws.StatusNormalClosure, "bye", false},
+		{"going away", wsutil.ClosedError{Code: ws.StatusGoingAway}, ws.StatusGoingAway, "", false},
+		{"no status", wsutil.ClosedError{Code: ws.StatusNoStatusRcvd}, ws.StatusNoStatusRcvd, "", false},
+		{"protocol error", wsutil.ClosedError{Code: ws.StatusProtocolError, Reason: "bad"}, ws.StatusProtocolError, "bad", true},
+		{"wrapped close", fmt.Errorf("reading: %w", wsutil.ClosedError{Code: ws.StatusGoingAway}), ws.StatusGoingAway, "", false},
+		{"eof", io.EOF, ws.StatusAbnormalClosure, "", false},
+		{"unexpected eof", fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), ws.StatusAbnormalClosure, "", false},
+		{"closed connection", net.ErrClosed, ws.StatusAbnormalClosure, "", false},
+		{"handler error", errors.New("boom"), ws.StatusInternalServerError, "boom", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason, failed := socketCloseStatus(tt.err)
+			if code != tt.code || reason != tt.reason || failed != tt.failed {
+				t.Errorf("socketCloseStatus(%v) = %d, %q, %v, want %d, %q, %v", tt.err, code, reason, failed, tt.code, tt.reason, tt.failed)
+			}
+		})
+	}
+}
+
+// socketEvents records what the socket hooks of a server see.
+type socketEvents struct {
+	events []string
+	closed chan struct{}
+	lock   sync.Mutex
+}
+
+func (e *socketEvents) add(event string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *socketEvents) list() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]string(nil), e.events...)
+}
+
+func (e *socketEvents) hook() SocketHookFuncs {
+	return SocketHookFuncs{
+		Upgrade: func(info SocketInfo, err error) {
+			e.add(fmt.Sprintf("upgrade %s %v", info.Route, err != nil))
+		},
+		Open: func(info SocketInfo) {
+			e.add("open")
+		},
+		Close: func(info SocketInfo, code ws.StatusCode, reason string) {
+			e.add(fmt.Sprintf("close %d %s", code, reason))
+			close(e.closed)
+		},
+		Error: func(info SocketInfo, err error) {
+			e.add("error " + err.Error())
+		},
+	}
+}
+
+func TestSocketHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler controller.SocketHandler
+		events  []string
+	}{
+		{
+			name: "echo",
+			handler: func(wc *context.WSContext) error {
+				data, err := wc.ReadText()
+				if err != nil {
+					return err
+				}
+				return wc.WriteText(data)
+			},
+			events: []string{"upgrade /ws false", "open", "close 1000 "},
+		},
+		{
+			name: "handler error",
+			handler: func(wc *context.WSContext) error {
+				if _, err := wc.ReadText(); err != nil {
+					return err
+				}
+				return errors.New("boom")
+			},
+			events: []string{"upgrade /ws false", "open", "error boom", "close 1011 boom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := &socketEvents{closed: make(chan struct{})}
+			l := New()
+			AddSocketHook(l, events.hook())
+			l.AddSocketController("/ws", controller.SocketController{Handler: tt.handler})
+			server := httptest.NewServer(l)
+			defer server.Close()
+
+			conn, _, _, err := ws.Dial(gocontext.Background(), "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if err := wsutil.WriteClientText(conn, []byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-events.closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("socket was not closed")
+			}
+			if got := events.list(); strings.Join(got, "|") != strings.Join(tt.events, "|") {
+				t.Errorf("events = %q, want %q", got, tt.events)
+			}
+			if stats := l.SocketStats(); stats.Active != 0 || stats.Total != 1 {
+				t.Errorf("stats = %+v, want 0 active of 1", stats)
+			}
+		})
+	}
+}
+
+func TestSocketUpgradeFailure(t *testing.T) {
+	events := &socketEvents{closed: make(chan struct{})}
+	l := New()
+	AddSocketHook(l, events.hook())
+	l.AddSocketController("/ws", controller.SocketController{Handler: func(wc *context.WSContext) error {
+		t.Error("handler ran without an upgrade")
+		return nil
+	}})
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if got := events.list(); len(got) != 1 || got[0] != "upgrade /ws true" {
+		t.Errorf("events = %q, want a failed upgrade only", got)
+	}
+	if stats := l.SocketStats(); stats.Total != 0 {
+		t.Errorf("total = %d, want 0", stats.Total)
+	}
+}
@@ -0,0 +1,307 @@
+package lux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog"
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/util"
+)
+
+type StaticConfig struct {
+	// IndexFile is served for directory requests, "index.html" when empty.
+	IndexFile string
+	// SPA serves IndexFile for unknown paths without an extension so client-side routing works.
+	SPA bool
+	// Precompressed serves "name.br" or "name.gz" next to "name" when the client accepts them.
+	Precompressed bool
+	// MaxAge sets Cache-Control for everything except HTML, which is always revalidated.
+	MaxAge time.Duration
+	// AllowHidden serves path segments that begin with a dot, such as ".well-known".
+	AllowHidden bool
+}
+
+// ServeStatic serves fsys under prefix. Any fs.FS works, e.g. an embed.FS or os.DirFS.
+func (l *Lux) ServeStatic(prefix string, fsys fs.FS) {
+	l.ServeStaticWithConfig(prefix, fsys, StaticConfig{Precompressed: true})
+}
+
+func (l *Lux) ServeSPA(prefix string, fsys fs.FS) {
+	l.ServeStaticWithConfig(prefix, fsys, StaticConfig{SPA: true, Precompressed: true})
+}
+
+// ServeStaticWithConfig serves fsys under prefix. An empty or "/" prefix serves the files
+// for every request no other route matches.
+func (l *Lux) ServeStaticWithConfig(prefix string, fsys fs.FS, cfg StaticConfig) {
+	if cfg.IndexFile == "" {
+		cfg.IndexFile = "index.html"
+	}
+
+	s := &staticServer{
+		fsys:   fsys,
+		cfg:    cfg,
+		logger: l.logger,
+	}
+
+	prefix = strings.TrimRight(prefix, "/")
+	route := prefix + "/*filepath"
+	// served like REST routes, so static files are measured, traced and logged too
+	handle := func(w http.ResponseWriter, r *http.Request, p httprouter.Params, name string) {
+		l.handle(route, w, r, p, func(w http.ResponseWriter, lc *context.LuxContext) *context.LuxContext {
+			counted := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+			s.serve(counted, lc.Request, name)
+			lc.Response.StatusCode = counted.status
+			lc.BytesWritten = counted.written
+			return lc
+		})
+	}
+
+	if prefix == "" {
+		l.builtRouter.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.NotFound(w, r)
+				return
+			}
+			handle(w, r, nil, r.URL.Path)
+		})
+		return
+	}
+
+	l.builtRouter.GET(route, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		handle(w, r, p, p.ByName("filepath"))
+	})
+	l.builtRouter.HEAD(route, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		handle(w, r, p, p.ByName("filepath"))
+	})
+}
+
+// countingWriter remembers the status and body size written through it.
+type countingWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+func (w *countingWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type staticServer struct {
+	fsys   fs.FS
+	cfg    StaticConfig
+	logger *zerolog.Logger
+	etags  sync.Map
+}
+
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (s *staticServer) serve(w http.ResponseWriter, r *http.Request, name string) {
+	if strings.ContainsAny(name, "\\\x00") {
+		http.NotFound(w, r)
+		return
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) || (!s.cfg.AllowHidden && hasHiddenSegment(name)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	requested := name
+	name, f, info, err := s.open(name)
+	if errors.Is(err, fs.ErrNotExist) && s.cfg.SPA && path.Ext(requested) == "" {
+		name, f, info, err = s.open(s.cfg.IndexFile)
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case err != nil:
+		s.logger.Error().Str("error", err.Error()).Str("path", r.URL.Path).Msg("Static file error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	header := w.Header()
+	ext := path.Ext(name)
+	if contentType, ok := util.LookupContentTypeFromExt(ext); ok {
+		header.Set("Content-Type", contentType)
+	} else if contentType := mime.TypeByExtension(ext); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	if strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+		header.Set("Cache-Control", "no-cache")
+	} else if s.cfg.MaxAge > 0 {
+		header.Set("Cache-Control", "public, max-age="+strconv.FormatInt(int64(s.cfg.MaxAge/time.Second), 10))
+	}
+
+	servedName := name
+	if s.cfg.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		for _, pre := range precompressedEncodings {
+			if !acceptsEncoding(r.Header.Get("Accept-Encoding"), pre.encoding) {
+				continue
+			}
+			cf, err := s.fsys.Open(name + pre.ext)
+			if err != nil {
+				continue
+			}
+			cinfo, err := cf.Stat()
+			if err != nil || cinfo.IsDir() {
+				cf.Close()
+				continue
+			}
+			defer cf.Close()
+			f, info, servedName = cf, cinfo, name+pre.ext
+			header.Set("Content-Encoding", pre.encoding)
+			break
+		}
+	}
+
+	content, err := readSeeker(f)
+	if err != nil {
+		s.logger.Error().Str("error", err.Error()).Str("path", r.URL.Path).Msg("Static file error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	etag, err := s.etag(servedName, info, content)
+	if err != nil {
+		s.logger.Error().Str("error", err.Error()).Str("path", r.URL.Path).Msg("Static file error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	header.Set("ETag", etag)
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// open resolves name to a regular file, descending into IndexFile for directories.
+func (s *staticServer) open(name string) (string, fs.File, fs.FileInfo, error) {
+	for i := 0; i < 2; i++ {
+		f, err := s.fsys.Open(name)
+		if err != nil {
+			return name, nil, nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return name, nil, nil, err
+		}
+		if !info.IsDir() {
+			return name, f, info, nil
+		}
+		f.Close()
+		name = path.Join(name, s.cfg.IndexFile)
+	}
+	return name, nil, nil, fs.ErrNotExist
+}
+
+// etag derives a validator from the modification time and size, or hashes the content
+// when the file system carries no modification times, as embed.FS does.
+func (s *staticServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size()), nil
+	}
+
+	key := name + ":" + strconv.FormatInt(info.Size(), 10)
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	h := fnv.New64a()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf("\"%x\"", h.Sum64())
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func hasHiddenSegment(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if len(segment) > 1 && segment[0] == '.' {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsEncoding(header string, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package lux
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		want     bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"GZIP", "gzip", true},
+		{"deflate, gzip;q=0.5", "gzip", true},
+		{"br;q=0", "br", false},
+		{"br;q=0.0, gzip", "br", false},
+		{"br ; q=1", "br", true},
+		{"gzip", "br", false},
+		{"*", "br", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.header, tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestHasHiddenSegment(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{".", false},
+		{"index.html", false},
+		{"a/b.c/d", false},
+		{".env", true},
+		{"assets/.git/config", true},
+		{".well-known/security.txt", true},
+	}
+	for _, tt := range tests {
+		if got := hasHiddenSegment(tt.name); got != tt.want {
+			t.Errorf("hasHiddenSegment(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServeStatic(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("<p>home</p>"), ModTime: modTime},
+		"app.js":            {Data: []byte("console.log(1)"), ModTime: modTime},
+		"app.js.br":         {Data: []byte("brotli"), ModTime: modTime},
+		"app.js.gz":         {Data: []byte("gzipped"), ModTime: modTime},
+		"docs/index.html":   {Data: []byte("<p>docs</p>"), ModTime: modTime},
+		".env":              {Data: []byte("SECRET=1"), ModTime: modTime},
+		"embedded/data.txt": {Data: []byte("no modtime")},
+	}
+	jsETag := fmt.Sprintf("\"%x-%x\"", modTime.UnixNano(), len("console.log(1)"))
+	h := fnv.New64a()
+	h.Write([]byte("no modtime"))
+	hashETag := fmt.Sprintf("\"%x\"", h.Sum64())
+
+	tests := []struct {
+		name    string
+		config  StaticConfig
+		method  string
+		path    string
+		header  http.Header
+		status  int
+		body    string
+		headers map[string]string
+	}{
+		{
+			name:    "file",
+			path:    "/s/app.js",
+			status:  http.StatusOK,
+			body:    "console.log(1)",
+			headers: map[string]string{"ETag": jsETag, "Cache-Control": "public, max-age=60"},
+		},
+		{
+			name:    "html is revalidated",
+			path:    "/s/index.html",
+			status:  http.StatusOK,
+			body:    "<p>home</p>",
+			headers: map[string]string{"Cache-Control": "no-cache"},
+		},
+		{
+			name:   "directory index",
+			path:   "/s/docs/",
+			status: http.StatusOK,
+			body:   "<p>docs</p>",
+		},
+		{
+			name:   "missing",
+			path:   "/s/missing.js",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "hidden",
+			path:   "/s/.env",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "hidden allowed",
+			config: StaticConfig{AllowHidden: true},
+			path:   "/s/.env",
+			status: http.StatusOK,
+			body:   "SECRET=1",
+		},
+		{
+			name:   "traversal",
+			path:   "/s/../../etc/passwd",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "brotli preferred",
+			config:  StaticConfig{Precompressed: true},
+			path:    "/s/app.js",
+			header:  http.Header{"Accept-Encoding": {"gzip, br"}},
+			status:  http.StatusOK,
+			body:    "brotli",
+			headers: map[string]string{"Content-Encoding": "br", "Vary": "Accept-Encoding"},
+		},
+		{
+			name:    "gzip",
+			config:  StaticConfig{Precompressed: true},
+			path:    "/s/app.js",
+			header:  http.Header{"Accept-Encoding": {"gzip, br;q=0"}},
+			status:  http.StatusOK,
+			body:    "gzipped",
+			headers: map[string]string{"Content-Encoding": "gzip"},
+		},
+		{
+			name:    "identity",
+			config:  StaticConfig{Precompressed: true},
+			path:    "/s/app.js",
+			status:  http.StatusOK,
+			body:    "console.log(1)",
+			headers: map[string]string{"Content-Encoding": ""},
+		},
+		{
+			name:   "not modified",
+			path:   "/s/app.js",
+			header: http.Header{"If-None-Match": {jsETag}},
+			status: http.StatusNotModified,
+		},
+		{
+			name:   "range",
+			path:   "/s/app.js",
+			header: http.Header{"Range": {"bytes=0-6"}},
+			status: http.StatusPartialContent,
+			body:   "console",
+		},
+		{
+			name:   "head",
+			method: http.MethodHead,
+			path:   "/s/app.js",
+			status: http.StatusOK,
+		},
+		{
+			name:   "spa fallback",
+			config: StaticConfig{SPA: true},
+			path:   "/s/users/42",
+			status: http.StatusOK,
+			body:   "<p>home</p>",
+		},
+		{
+			name:   "spa keeps missing assets",
+			config: StaticConfig{SPA: true},
+			path:   "/s/missing.css",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "hashed etag without modtime",
+			path:    "/s/embedded/data.txt",
+			status:  http.StatusOK,
+			body:    "no modtime",
+			headers: map[string]string{"ETag": hashETag},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			if cfg.MaxAge == 0 {
+				cfg.MaxAge = time.Minute
+			}
+			l := New()
+			l.ServeStaticWithConfig("/s", fsys, cfg)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			r.URL.Path = tt.path
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if method == http.MethodHead && w.Body.Len() != 0 {
+				t.Errorf("HEAD wrote a body of %d bytes", w.Body.Len())
+			}
+			for key, want := range tt.headers {
+				if got := w.Header().Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServeStaticFallback(t *testing.T) {
+	l := New()
+	l.AddRestController("/api", "GET", controller.RestController{Handler: func(lc *context.LuxContext) error {
+		return lc.ReplyString("api")
+	}})
+	l.ServeStaticWithConfig("", fstest.MapFS{"index.html": {Data: []byte("home")}}, StaticConfig{SPA: true})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/api", http.StatusOK, "api"},
+		{http.MethodGet, "/", http.StatusOK, "home"},
+		{http.MethodGet, "/deep/link", http.StatusOK, "home"},
+		{http.MethodPost, "/deep/link", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+}
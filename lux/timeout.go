@@ -0,0 +1,145 @@
+package lux
+
+import (
+	ctx "context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// SetHandlerTimeout bounds every REST handler that has no RestController.Timeout of its own.
+func SetHandlerTimeout(l *Lux, duration time.Duration) {
+	l.handlerTimeout = duration
+}
+
+// SetDeadlineHeaders honors deadlines sent by clients in X-Request-Timeout or grpc-timeout,
+// never granting more than max. Zero ignores the headers.
+func SetDeadlineHeaders(l *Lux, max time.Duration) {
+	l.maxClientTimeout = max
+}
+
+func (l *Lux) timeoutOf(rc *controller.RestController, r *http.Request) (time.Duration, bool) {
+	timeout := rc.Timeout
+	if timeout <= 0 {
+		timeout = l.handlerTimeout
+	}
+
+	if l.maxClientTimeout > 0 {
+		if requested, ok := parseDeadlineHeaders(r.Header); ok {
+			if requested > l.maxClientTimeout {
+				requested = l.maxClientTimeout
+			}
+			if timeout <= 0 || requested < timeout {
+				return requested, true
+			}
+		}
+	}
+
+	return timeout, false
+}
+
+// serveWithTimeout runs rc on its own goroutine so a handler ignoring RequestContext cannot
+// hold the response. It returns the context whose response should be written.
+func (l *Lux) serveWithTimeout(lc *context.LuxContext, rc *controller.RestController, timeout time.Duration, fromClient bool) *context.LuxContext {
+	requestContext, cancel := ctx.WithTimeout(lc.RequestContext, timeout)
+	defer cancel()
+	lc.RequestContext = requestContext
+	lc.Request = lc.Request.WithContext(requestContext)
+	// the handler may outlive the timeout, so the answer comes from a fork it does not share
+	timedOut := lc.Fork()
+
+	done := make(chan error, 1)
+	panicked := make(chan any, 1)
+	// a handler that times out keeps the request scope open until it returns
+	release := holdScope(lc)
+	go func() {
+		defer release()
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		done <- rc.Serve(lc)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			l.handleError(lc, err)
+		}
+		return lc
+	case p := <-panicked:
+		panic(p)
+	case <-requestContext.Done():
+	}
+
+	if !errors.Is(requestContext.Err(), ctx.DeadlineExceeded) {
+		return timedOut
+	}
+
+	status := http.StatusServiceUnavailable
+	if fromClient {
+		status = http.StatusGatewayTimeout
+	}
+	timedOut.Response.WriteHeader(status)
+	l.handleError(timedOut, fmt.Errorf("%s %s after %s: %w", timedOut.Request.Method, timedOut.Route, timeout, ErrHandlerTimeout))
+	return timedOut
+}
+
+// maxDeadlineSeconds is the longest X-Request-Timeout in seconds a time.Duration can hold.
+const maxDeadlineSeconds = float64(math.MaxInt64) / float64(time.Second)
+
+// parseDeadlineHeaders reads X-Request-Timeout, as a Go duration or in seconds, and the
+// gRPC style grpc-timeout, such as "100m" for 100 milliseconds. Values too large for a
+// time.Duration are ignored rather than wrapped around to negative ones.
+func parseDeadlineHeaders(header http.Header) (time.Duration, bool) {
+	if value := strings.TrimSpace(header.Get("X-Request-Timeout")); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d, true
+		}
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 && seconds < maxDeadlineSeconds {
+			if d := time.Duration(seconds * float64(time.Second)); d > 0 {
+				return d, true
+			}
+		}
+	}
+
+	value := strings.TrimSpace(header.Get("Grpc-Timeout"))
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || amount <= 0 {
+		return 0, false
+	}
+	unit := time.Duration(0)
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	if amount > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(amount) * unit, true
+}
@@ -0,0 +1,149 @@
+package lux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+)
+
+func TestParseDeadlineHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+		ok     bool
+	}{
+		{"none", nil, 0, false},
+		{"duration", map[string]string{"X-Request-Timeout": "250ms"}, 250 * time.Millisecond, true},
+		{"seconds", map[string]string{"X-Request-Timeout": "1.5"}, 1500 * time.Millisecond, true},
+		{"zero", map[string]string{"X-Request-Timeout": "0"}, 0, false},
+		{"negative", map[string]string{"X-Request-Timeout": "-1s"}, 0, false},
+		{"overflowing seconds", map[string]string{"X-Request-Timeout": "1e300"}, 0, false},
+		{"garbage", map[string]string{"X-Request-Timeout": "soon"}, 0, false},
+		{"grpc milliseconds", map[string]string{"Grpc-Timeout": "100m"}, 100 * time.Millisecond, true},
+		{"grpc hours", map[string]string{"Grpc-Timeout": "2H"}, 2 * time.Hour, true},
+		{"grpc nanoseconds", map[string]string{"Grpc-Timeout": "5n"}, 5, true},
+		{"grpc unknown unit", map[string]string{"Grpc-Timeout": "5x"}, 0, false},
+		{"grpc no amount", map[string]string{"Grpc-Timeout": "S"}, 0, false},
+		{"grpc too many digits", map[string]string{"Grpc-Timeout": "123456789S"}, 0, false},
+		{"grpc overflow", map[string]string{"Grpc-Timeout": "99999999H"}, 0, false},
+		{"grpc zero", map[string]string{"Grpc-Timeout": "0S"}, 0, false},
+		{"x-request-timeout first", map[string]string{"X-Request-Timeout": "1s", "Grpc-Timeout": "2S"}, time.Second, true},
+		{"grpc after a bad x-request-timeout", map[string]string{"X-Request-Timeout": "soon", "Grpc-Timeout": "2S"}, 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+			got, ok := parseDeadlineHeaders(header)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseDeadlineHeaders(%v) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTimeoutOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     time.Duration
+		maxClient  time.Duration
+		route      time.Duration
+		header     string
+		want       time.Duration
+		fromClient bool
+	}{
+		{"none", 0, 0, 0, "", 0, false},
+		{"server", time.Second, 0, 0, "", time.Second, false},
+		{"route overrides server", time.Second, 0, 2 * time.Second, "", 2 * time.Second, false},
+		{"headers ignored", time.Second, 0, 0, "100ms", time.Second, false},
+		{"client shorter", time.Second, time.Minute, 0, "100ms", 100 * time.Millisecond, true},
+		{"client longer", time.Second, time.Minute, 0, "10s", time.Second, false},
+		{"client capped", 0, 5 * time.Second, 0, "10s", 5 * time.Second, true},
+		{"client without server timeout", 0, time.Minute, 0, "10s", 10 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New()
+			SetHandlerTimeout(l, tt.server)
+			SetDeadlineHeaders(l, tt.maxClient)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Request-Timeout", tt.header)
+			}
+			got, fromClient := l.timeoutOf(&controller.RestController{Timeout: tt.route}, r)
+			if got != tt.want || fromClient != tt.fromClient {
+				t.Errorf("timeoutOf = %v, %v, want %v, %v", got, fromClient, tt.want, tt.fromClient)
+			}
+		})
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		header  string
+		status  int
+		body    string
+		timeout bool
+	}{
+		{"in time", 0, "", http.StatusOK, "done", false},
+		{"server timeout", time.Second, "", http.StatusServiceUnavailable, "", true},
+		{"client timeout", time.Second, "10ms", http.StatusGatewayTimeout, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			lock := sync.Mutex{}
+			l := New()
+			SetHandlerTimeout(l, 50*time.Millisecond)
+			SetDeadlineHeaders(l, time.Minute)
+			SetErrorHandler(l, func(lc *context.LuxContext, err error) {
+				lock.Lock()
+				defer lock.Unlock()
+				errs = append(errs, err)
+			})
+			finished := make(chan struct{})
+			l.AddRestController("/slow", controller.GET, controller.RestController{Handler: func(lc *context.LuxContext) error {
+				defer close(finished)
+				select {
+				case <-time.After(tt.delay):
+				case <-lc.RequestContext.Done():
+					// the timed out handler writes to a context nobody reads anymore
+					lc.ReplyString("late")
+					return lc.RequestContext.Err()
+				}
+				return lc.ReplyString("done")
+			}})
+
+			r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Request-Timeout", tt.header)
+			}
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, r)
+			<-finished
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if timedOut := len(errs) > 0 && errors.Is(errs[0], ErrHandlerTimeout); timedOut != tt.timeout {
+				t.Errorf("errors = %v, want a timeout %v", errs, tt.timeout)
+			}
+		})
+	}
+}
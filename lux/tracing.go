@@ -0,0 +1,103 @@
+package lux
+
+import (
+	"net/http"
+
+	"github.com/snowmerak/lux/v3/context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/snowmerak/lux/v3/lux"
+
+// SetTracerProvider sets where request and websocket spans go, the global provider of otel when unset.
+func SetTracerProvider(l *Lux, tp trace.TracerProvider) {
+	l.tracerProvider = tp
+}
+
+// SetPropagator sets how the caller's trace is read from request headers, W3C trace context by default.
+func SetPropagator(l *Lux, propagator propagation.TextMapPropagator) {
+	l.propagator = propagator
+}
+
+func (l *Lux) tracer() trace.Tracer {
+	if l.tracerProvider != nil {
+		return l.tracerProvider.Tracer(tracerName)
+	}
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+func (l *Lux) extractTrace(r *http.Request) *http.Request {
+	propagator := l.propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return r.WithContext(propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header)))
+}
+
+// startRequestSpan starts the server span of a REST request and makes it the parent of
+// everything the controller does through RequestContext.
+func (l *Lux) startRequestSpan(lc *context.LuxContext) trace.Span {
+	r := l.extractTrace(lc.Request)
+	spanCtx, span := l.tracer().Start(r.Context(), r.Method+" "+lc.Route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPRouteKey.String(lc.Route),
+			semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+			semconv.HTTPUserAgentKey.String(r.UserAgent()),
+			semconv.NetSockPeerAddrKey.String(r.RemoteAddr),
+		),
+	)
+	lc.RequestContext = spanCtx
+	lc.Request = r.WithContext(spanCtx)
+	return span
+}
+
+func endRequestSpan(span trace.Span, lc *context.LuxContext) {
+	status := lc.Response.StatusCode
+	span.SetAttributes(
+		semconv.HTTPStatusCodeKey.Int(status),
+		semconv.HTTPResponseContentLengthKey.Int64(lc.BytesWritten),
+	)
+	if status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+func (l *Lux) startSocketSpan(r *http.Request, info SocketInfo) (*http.Request, trace.Span) {
+	r = l.extractTrace(r)
+	spanCtx, span := l.tracer().Start(r.Context(), "WS "+info.Route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPRouteKey.String(info.Route),
+			semconv.NetSockPeerAddrKey.String(info.RemoteAddr),
+		),
+	)
+	return r.WithContext(spanCtx), span
+}
+
+func endSocketSpan(span trace.Span, info SocketInfo, err error) {
+	code, reason, failed := socketCloseStatus(err)
+	span.SetAttributes(
+		attribute.Int64("websocket.id", int64(info.ID)),
+		attribute.Int("websocket.close_code", int(code)),
+	)
+	if failed {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, reason)
+	}
+	span.End()
+}
+
+func endSocketUpgradeSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "websocket upgrade failed")
+	span.End()
+}
@@ -0,0 +1,161 @@
+package lux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttribute(span sdktrace.ReadOnlySpan, name attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == name {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestRequestSpans(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parent = "00-" + traceID + "-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name        string
+		handler     controller.RestHandler
+		middlewares []middleware.Request
+		header      map[string]string
+		status      int
+		spanStatus  codes.Code
+		children    []string
+		remoteTrace bool
+	}{
+		{
+			name:       "ok",
+			handler:    func(lc *context.LuxContext) error { return lc.ReplyString("ok") },
+			status:     http.StatusOK,
+			spanStatus: codes.Unset,
+			children:   []string{"handler"},
+		},
+		{
+			name:       "client error",
+			handler:    func(lc *context.LuxContext) error { lc.SetBadRequest(); return nil },
+			status:     http.StatusBadRequest,
+			spanStatus: codes.Unset,
+			children:   []string{"handler"},
+		},
+		{
+			name: "server error",
+			handler: func(lc *context.LuxContext) error {
+				lc.SetInternalServerError()
+				return errors.New("boom")
+			},
+			status:     http.StatusInternalServerError,
+			spanStatus: codes.Error,
+			children:   []string{"handler"},
+		},
+		{
+			name:       "panic",
+			handler:    func(lc *context.LuxContext) error { panic("boom") },
+			status:     http.StatusInternalServerError,
+			spanStatus: codes.Error,
+		},
+		{
+			name:        "continued trace",
+			handler:     func(lc *context.LuxContext) error { return lc.ReplyString("ok") },
+			header:      map[string]string{"traceparent": parent},
+			status:      http.StatusOK,
+			spanStatus:  codes.Unset,
+			children:    []string{"handler"},
+			remoteTrace: true,
+		},
+		{
+			name: "child spans",
+			handler: func(lc *context.LuxContext) error {
+				_, end := middleware.StartSpan(lc, "load user")
+				defer end()
+				return lc.ReplyString("ok")
+			},
+			middlewares: []middleware.Request{func(lc *context.LuxContext) (*context.LuxContext, int) {
+				return lc, http.StatusOK
+			}},
+			status:     http.StatusOK,
+			spanStatus: codes.Unset,
+			children:   []string{"request middleware", "load user", "handler"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			l := New()
+			SetTracerProvider(l, tp)
+			l.AddRestController("/items/:id", controller.GET, controller.RestController{
+				RequestMiddlewares: tt.middlewares,
+				Handler:            tt.handler,
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			func() {
+				defer func() { recover() }()
+				l.ServeHTTP(w, r)
+			}()
+
+			var server sdktrace.ReadOnlySpan
+			var children []string
+			for _, span := range recorder.Ended() {
+				if span.SpanKind() == trace.SpanKindServer {
+					server = span
+					continue
+				}
+				children = append(children, span.Name())
+			}
+			if server == nil {
+				t.Fatalf("no server span among %d", len(recorder.Ended()))
+			}
+			if server.Name() != "GET /items/:id" {
+				t.Errorf("name = %q", server.Name())
+			}
+			if got := spanAttribute(server, "http.status_code").AsInt64(); got != int64(tt.status) {
+				t.Errorf("http.status_code = %d, want %d", got, tt.status)
+			}
+			if got := spanAttribute(server, "http.route").AsString(); got != "/items/:id" {
+				t.Errorf("http.route = %q", got)
+			}
+			if server.Status().Code != tt.spanStatus {
+				t.Errorf("span status = %v, want %v", server.Status().Code, tt.spanStatus)
+			}
+			if remote := server.SpanContext().TraceID().String() == traceID; remote != tt.remoteTrace {
+				t.Errorf("trace %s continues the caller's: %v, want %v", server.SpanContext().TraceID(), remote, tt.remoteTrace)
+			}
+
+			if len(children) != len(tt.children) {
+				t.Fatalf("child spans = %q, want %q", children, tt.children)
+			}
+			for i, name := range tt.children {
+				if !strings.HasPrefix(children[i], name) {
+					t.Errorf("child span %d = %q, want %q", i, children[i], name)
+				}
+			}
+			for _, span := range recorder.Ended() {
+				if span.SpanContext().TraceID() != server.SpanContext().TraceID() {
+					t.Errorf("%q is not in the trace of the server span", span.Name())
+				}
+			}
+		})
+	}
+}
@@ -35,6 +35,30 @@ func main() {
 				Action:    runAppCommand,
 				Args:      true,
 			},
+			{
+				Name:      "graph",
+				Usage:     "Print the dependency graph of the component sets",
+				UsageText: "lux graph [--format dot|mermaid|json] [--set name] [--output file]\n\n" + "Example:\n" + "  lux graph --format dot | dot -Tsvg > graph.svg",
+				Action:    graphCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "Output format: dot, mermaid or json",
+						Value:   "dot",
+					},
+					&cli.StringFlag{
+						Name:    "set",
+						Aliases: []string{"s"},
+						Usage:   "Only print this component set",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Write to this file instead of stdout",
+					},
+				},
+			},
 			{
 				Name:      "generate",
 				Aliases:   []string{"g"},
@@ -54,6 +78,13 @@ func main() {
 						Usage:   "Generate a component",
 						Args:    true,
 						Action:  generateComponentsSetCommand,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "static",
+								Usage: "Also generate a Wire function that constructs the components without reflection",
+								Value: false,
+							},
+						},
 					},
 					{
 						Name:    "middleware",
@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets suit request latencies in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets suit payload sizes in bytes.
+var SizeBuckets = []float64{100, 1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20, 100 << 20}
+
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+type Counter struct {
+	value atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add increases the counter by v. Negative values are ignored, counters only go up.
+func (c *Counter) Add(v float64) {
+	if v > 0 {
+		c.value.Add(v)
+	}
+}
+
+func (c *Counter) Value() float64 {
+	return c.value.Load()
+}
+
+type Gauge struct {
+	value atomicFloat
+}
+
+func (g *Gauge) Set(v float64) {
+	g.value.Set(v)
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.value.Add(v)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.value.Load()
+}
+
+// Histogram counts observations per bucket, the last one being +Inf, so the buckets
+// always add up to the count.
+type Histogram struct {
+	upperBounds []float64
+	counts      []atomic.Uint64
+	sum         atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		upperBounds: buckets,
+		counts:      make([]atomic.Uint64, len(buckets)+1),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.upperBounds, v)].Add(1)
+	h.sum.Add(v)
+}
+
+func (h *Histogram) Count() uint64 {
+	count := uint64(0)
+	for i := range h.counts {
+		count += h.counts[i].Load()
+	}
+	return count
+}
+
+func (h *Histogram) Sum() float64 {
+	return h.sum.Load()
+}
+
+// vec holds one child per combination of label values.
+type vec[T any] struct {
+	name     string
+	help     string
+	labels   []string
+	newChild func() *T
+	children map[string]*labeled[T]
+	lock     sync.RWMutex
+}
+
+type labeled[T any] struct {
+	values []string
+	metric *T
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	key := labelKey(values)
+	v.lock.RLock()
+	child, ok := v.children[key]
+	v.lock.RUnlock()
+	if ok {
+		return child.metric
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if child, ok := v.children[key]; ok {
+		return child.metric
+	}
+	child = &labeled[T]{values: append([]string(nil), values...), metric: v.newChild()}
+	v.children[key] = child
+	return child.metric
+}
+
+// sorted returns the children ordered by label values, for stable output.
+func (v *vec[T]) sorted() []*labeled[T] {
+	v.lock.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	children := make([]*labeled[T], len(keys))
+	for i, key := range keys {
+		children[i] = v.children[key]
+	}
+	v.lock.RUnlock()
+	return children
+}
+
+type CounterVec struct {
+	vec[Counter]
+}
+
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.with(values)
+}
+
+type GaugeVec struct {
+	vec[Gauge]
+}
+
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.with(values)
+}
+
+type HistogramVec struct {
+	vec[Histogram]
+}
+
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func labelKey(values []string) string {
+	size := 0
+	for _, value := range values {
+		size += len(value) + 1
+	}
+	key := make([]byte, 0, size)
+	for _, value := range values {
+		key = append(key, value...)
+		key = append(key, 0xff)
+	}
+	return string(key)
+}
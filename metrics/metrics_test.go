@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter []float64
+		gauge   []float64
+		want    [2]float64
+	}{
+		{"empty", nil, nil, [2]float64{0, 0}},
+		{"adds", []float64{1, 2.5}, []float64{1, 2.5}, [2]float64{3.5, 3.5}},
+		{"negative", []float64{2, -1}, []float64{2, -1}, [2]float64{2, 1}},
+	}
+	for _, tt := range tests {
+		c, g := &Counter{}, &Gauge{}
+		for _, v := range tt.counter {
+			c.Add(v)
+		}
+		for _, v := range tt.gauge {
+			g.Add(v)
+		}
+		if c.Value() != tt.want[0] || g.Value() != tt.want[1] {
+			t.Errorf("%s: counter %v gauge %v, want %v", tt.name, c.Value(), g.Value(), tt.want)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		counts []uint64
+		sum    float64
+	}{
+		{"empty", nil, []uint64{0, 0, 0}, 0},
+		{"on the bounds", []float64{1, 2}, []uint64{1, 1, 0}, 3},
+		{"between", []float64{0.5, 1.5, 3}, []uint64{1, 1, 1}, 5},
+		{"above", []float64{10, math.Inf(1)}, []uint64{0, 0, 2}, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		h := newHistogram([]float64{1, 2})
+		for _, v := range tt.values {
+			h.Observe(v)
+		}
+		for i, want := range tt.counts {
+			if got := h.counts[i].Load(); got != want {
+				t.Errorf("%s: bucket %d = %d, want %d", tt.name, i, got, want)
+			}
+		}
+		if h.Count() != uint64(len(tt.values)) || h.Sum() != tt.sum {
+			t.Errorf("%s: count %d sum %v, want %d %v", tt.name, h.Count(), h.Sum(), len(tt.values), tt.sum)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests\nserved by \\route.", "route", "status")
+	requests.With("/b", "200").Add(2)
+	requests.With("/a", "500").Inc()
+	requests.With(`/"q"`, "200").Inc()
+	r.NewGauge("in_flight", "").Set(3)
+	duration := r.NewHistogram("duration_seconds", "Latency.", []float64{1, 0.1, math.Inf(1)})
+	duration.Observe(0.05)
+	duration.Observe(0.5)
+	duration.Observe(5)
+
+	want := strings.Join([]string{
+		"# HELP duration_seconds Latency.",
+		"# TYPE duration_seconds histogram",
+		`duration_seconds_bucket{le="0.1"} 1`,
+		`duration_seconds_bucket{le="1"} 2`,
+		`duration_seconds_bucket{le="+Inf"} 3`,
+		"duration_seconds_sum 5.55",
+		"duration_seconds_count 3",
+		"# TYPE in_flight gauge",
+		"in_flight 3",
+		`# HELP requests_total Requests\nserved by \\route.`,
+		"# TYPE requests_total counter",
+		`requests_total{route="/\"q\"",status="200"} 1`,
+		`requests_total{route="/a",status="500"} 1`,
+		`requests_total{route="/b",status="200"} 2`,
+		"",
+	}, "\n")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+	if got := w.Body.String(); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{0.25, "0.25"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.value); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric string
+		labels []string
+	}{
+		{"metric name", "bad-name", nil},
+		{"leading digit", "1st", nil},
+		{"label name", "ok", []string{"bad-label"}},
+		{"reserved label", "ok", []string{"__name"}},
+		{"le label", "ok", []string{"le"}},
+		{"duplicate", "taken", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.NewCounter("taken", "")
+			defer func() {
+				if recover() == nil {
+					t.Error("registration did not panic")
+				}
+			}()
+			r.NewCounterVec(tt.metric, "", tt.labels...)
+		})
+	}
+}
+
+func TestVecLabels(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("split_total", "", "a", "b")
+	if v.With("x", "yz") == v.With("xy", "z") {
+		t.Error("label values are not kept apart")
+	}
+	if v.With("x", "y") != v.With("x", "y") {
+		t.Error("same label values made two children")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("wrong label count did not panic")
+		}
+	}()
+	v.With("x")
+}
+
+func TestConcurrentObserve(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("concurrent", "", []float64{1}, "worker")
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h.With("w").Observe(float64(j % 3))
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	scraped := make(chan struct{})
+	go func() {
+		defer close(scraped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				r.WriteText(&strings.Builder{})
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-scraped
+
+	if got := h.With("w").Count(); got != 8000 {
+		t.Errorf("count = %d, want 8000", got)
+	}
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+type family interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and writes them in the Prometheus text exposition format.
+type Registry struct {
+	families map[string]family
+	lock     sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]family),
+	}
+}
+
+func (r *Registry) register(name string, labels []string, f family) {
+	if !metricNamePattern.MatchString(name) {
+		panic("metrics: invalid metric name " + strconv.Quote(name))
+	}
+	for _, label := range labels {
+		if !labelNamePattern.MatchString(label) || strings.HasPrefix(label, "__") || label == "le" {
+			panic("metrics: invalid label name " + strconv.Quote(label) + " for " + name)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic("metrics: " + name + " is already registered")
+	}
+	r.families[name] = f
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	v := &CounterVec{vec[Counter]{
+		name:     name,
+		help:     help,
+		labels:   labels,
+		newChild: func() *Counter { return new(Counter) },
+		children: make(map[string]*labeled[Counter]),
+	}}
+	r.register(name, labels, v)
+	return v
+}
+
+func (r *Registry) NewCounter(name string, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+func (r *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{vec[Gauge]{
+		name:     name,
+		help:     help,
+		labels:   labels,
+		newChild: func() *Gauge { return new(Gauge) },
+		children: make(map[string]*labeled[Gauge]),
+	}}
+	r.register(name, labels, v)
+	return v
+}
+
+func (r *Registry) NewGauge(name string, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewHistogramVec makes histograms with the given upper bounds, DefaultBuckets when empty.
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	if math.IsInf(buckets[len(buckets)-1], 1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+
+	v := &HistogramVec{vec[Histogram]{
+		name:     name,
+		help:     help,
+		labels:   labels,
+		newChild: func() *Histogram { return newHistogram(buckets) },
+		children: make(map[string]*labeled[Histogram]),
+	}}
+	r.register(name, labels, v)
+	return v
+}
+
+func (r *Registry) NewHistogram(name string, help string, buckets []float64) *Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+// WriteText writes every family, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]family, len(names))
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.lock.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, v.name, v.help, "counter")
+	for _, child := range v.sorted() {
+		writeSample(w, v.name, v.labels, child.values, "", "", child.metric.Value())
+	}
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	writeHeader(w, v.name, v.help, "gauge")
+	for _, child := range v.sorted() {
+		writeSample(w, v.name, v.labels, child.values, "", "", child.metric.Value())
+	}
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, v.name, v.help, "histogram")
+	for _, child := range v.sorted() {
+		h := child.metric
+		cumulative := uint64(0)
+		for i, upper := range h.upperBounds {
+			cumulative += h.counts[i].Load()
+			writeSample(w, v.name+"_bucket", v.labels, child.values, "le", formatFloat(upper), float64(cumulative))
+		}
+		// the +Inf bucket holds everything, so the count is read from the same loads
+		count := cumulative + h.counts[len(h.upperBounds)].Load()
+		writeSample(w, v.name+"_bucket", v.labels, child.values, "le", "+Inf", float64(count))
+		writeSample(w, v.name+"_sum", v.labels, child.values, "", "", h.Sum())
+		writeSample(w, v.name+"_count", v.labels, child.values, "", "", float64(count))
+	}
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, kind string) {
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, helpEscaper.Replace(help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSample(w *bufio.Writer, name string, labels []string, values []string, extraLabel string, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, label, values[i])
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name string, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	w.WriteString(labelEscaper.Replace(value))
+	w.WriteByte('"')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
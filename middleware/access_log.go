@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snowmerak/lux/v3/context"
+)
+
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON logs every detail as a field of the event.
+	AccessLogJSON AccessLogFormat = iota
+	// AccessLogCommon logs the Apache common log line as the message.
+	AccessLogCommon
+	// AccessLogCombined logs the Apache combined log line, with referer and user agent, as the message.
+	AccessLogCombined
+)
+
+const redacted = "REDACTED"
+
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+type AccessLogConfig struct {
+	// Logger receives the events, the request's Logger when nil.
+	Logger *zerolog.Logger
+	Format AccessLogFormat
+	// Level of the events, zerolog.InfoLevel when unset. 5xx responses log at error level.
+	Level *zerolog.Level
+	// SampleRate logs that share of requests below 500. Zero logs every request.
+	SampleRate float64
+	// ExcludePaths are skipped, e.g. "/healthz". A trailing "*" matches a prefix.
+	ExcludePaths []string
+	// Headers are request headers added to JSON events.
+	Headers []string
+	// RedactHeaders replaces the values of these headers, DefaultRedactedHeaders when nil.
+	RedactHeaders []string
+	// RedactQuery replaces the values of these query parameters in the logged path.
+	RedactQuery []string
+}
+
+type AccessLogMiddleware Request
+
+// AccessLog writes one event per request once its response has been written. Set it with
+// lux.SetAccessLog to log every request; as a route middleware it only sees the requests that
+// the body limit and the middlewares before it let through.
+func AccessLog(cfg AccessLogConfig) AccessLogMiddleware {
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = DefaultRedactedHeaders
+	}
+	level := zerolog.InfoLevel
+	if cfg.Level != nil {
+		level = *cfg.Level
+	}
+	redactHeaders := make(map[string]struct{}, len(cfg.RedactHeaders))
+	for _, header := range cfg.RedactHeaders {
+		redactHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	redactQuery := make(map[string]struct{}, len(cfg.RedactQuery))
+	for _, name := range cfg.RedactQuery {
+		redactQuery[name] = struct{}{}
+	}
+
+	return func(l *context.LuxContext) (*context.LuxContext, int) {
+		if excludedPath(l.Request.URL.Path, cfg.ExcludePaths) {
+			return l, http.StatusOK
+		}
+
+		l.OnComplete(func(l *context.LuxContext) {
+			status := l.Response.StatusCode
+			if status < 500 && cfg.SampleRate > 0 && rand.Float64() >= cfg.SampleRate {
+				return
+			}
+
+			logger := cfg.Logger
+			if logger == nil {
+				logger = l.Logger
+			}
+			if logger == nil {
+				return
+			}
+
+			eventLevel := level
+			if status >= 500 && eventLevel < zerolog.ErrorLevel {
+				eventLevel = zerolog.ErrorLevel
+			}
+			event := logger.WithLevel(eventLevel)
+			if event == nil {
+				return
+			}
+
+			r := l.Request
+			uri := redactURI(r.URL, redactQuery)
+			switch cfg.Format {
+			case AccessLogCommon:
+				event.Msg(commonLogLine(l, uri))
+			case AccessLogCombined:
+				event.Msg(commonLogLine(l, uri) + " " + quoteOrDash(r.Referer()) + " " + quoteOrDash(r.UserAgent()))
+			default:
+				event = event.
+					Str("method", r.Method).
+					Str("route", l.Route).
+					Str("path", uri).
+					Int("status", status).
+					Int64("bytes", l.BytesWritten).
+					Dur("latency", time.Since(l.StartTime)).
+					Str("remote_ip", l.GetRemoteIP()).
+					Str("user_agent", r.UserAgent())
+				if id := l.RequestID(); id != "" && cfg.Logger != nil {
+					event = event.Str("request_id", id)
+				}
+				if len(cfg.Headers) > 0 {
+					headers := zerolog.Dict()
+					for _, name := range cfg.Headers {
+						value := r.Header.Get(name)
+						if value == "" {
+							continue
+						}
+						if _, ok := redactHeaders[http.CanonicalHeaderKey(name)]; ok {
+							value = redacted
+						}
+						headers = headers.Str(name, value)
+					}
+					event = event.Dict("headers", headers)
+				}
+				event.Msg("Request")
+			}
+		})
+		return l, http.StatusOK
+	}
+}
+
+func excludedPath(path string, excluded []string) bool {
+	for _, pattern := range excluded {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func redactURI(u *url.URL, names map[string]struct{}) string {
+	if len(names) == 0 || u.RawQuery == "" {
+		return u.RequestURI()
+	}
+
+	query := u.Query()
+	for name := range query {
+		if _, ok := names[name]; ok {
+			for i := range query[name] {
+				query[name][i] = redacted
+			}
+		}
+	}
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.RequestURI()
+}
+
+// commonLogLine formats l in the Apache common log format.
+func commonLogLine(l *context.LuxContext, uri string) string {
+	r := l.Request
+	user := "-"
+	if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	bytes := "-"
+	if l.BytesWritten > 0 {
+		bytes = strconv.FormatInt(l.BytesWritten, 10)
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(l.GetRemoteIP())
+	builder.WriteString(" - ")
+	builder.WriteString(user)
+	builder.WriteString(" [")
+	builder.WriteString(l.StartTime.Format("02/Jan/2006:15:04:05 -0700"))
+	builder.WriteString("] \"")
+	builder.WriteString(r.Method)
+	builder.WriteString(" ")
+	builder.WriteString(uri)
+	builder.WriteString(" ")
+	builder.WriteString(r.Proto)
+	builder.WriteString("\" ")
+	builder.WriteString(strconv.Itoa(l.Response.StatusCode))
+	builder.WriteString(" ")
+	builder.WriteString(bytes)
+	return builder.String()
+}
+
+func quoteOrDash(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
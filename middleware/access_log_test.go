@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAccessLog(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	debug := zerolog.DebugLevel
+
+	tests := []struct {
+		name    string
+		cfg     AccessLogConfig
+		target  string
+		header  map[string]string
+		status  int
+		message string
+		fields  map[string]any
+		level   string
+		skipped bool
+	}{
+		{
+			name:   "json",
+			target: "/items?id=1",
+			status: http.StatusOK,
+			level:  "info",
+			fields: map[string]any{"method": "GET", "path": "/items?id=1", "status": 200.0, "bytes": 5.0, "remote_ip": "192.0.2.1", "message": "Request"},
+		},
+		{
+			name:   "server error",
+			target: "/items",
+			status: http.StatusBadGateway,
+			level:  "error",
+		},
+		{
+			name:   "level",
+			cfg:    AccessLogConfig{Level: &debug},
+			target: "/items",
+			status: http.StatusOK,
+			level:  "debug",
+		},
+		{
+			name:    "common",
+			cfg:     AccessLogConfig{Format: AccessLogCommon},
+			target:  "/items",
+			header:  map[string]string{"Authorization": "Basic YWxpY2U6c2VjcmV0"},
+			status:  http.StatusOK,
+			message: `192.0.2.1 - alice [02/Jan/2024:03:04:05 +0000] "GET /items HTTP/1.1" 200 5`,
+		},
+		{
+			name:    "combined",
+			cfg:     AccessLogConfig{Format: AccessLogCombined},
+			target:  "/items",
+			header:  map[string]string{"Referer": "https://example.com/", "User-Agent": "test \"agent\""},
+			status:  http.StatusOK,
+			message: `192.0.2.1 - - [02/Jan/2024:03:04:05 +0000] "GET /items HTTP/1.1" 200 5 "https://example.com/" "test \"agent\""`,
+		},
+		{
+			name:    "combined without headers",
+			cfg:     AccessLogConfig{Format: AccessLogCombined},
+			target:  "/items",
+			status:  http.StatusOK,
+			message: `192.0.2.1 - - [02/Jan/2024:03:04:05 +0000] "GET /items HTTP/1.1" 200 5 "-" "-"`,
+		},
+		{
+			name:    "redacted query",
+			cfg:     AccessLogConfig{Format: AccessLogCommon, RedactQuery: []string{"token"}},
+			target:  "/items?token=secret&page=2",
+			status:  http.StatusOK,
+			message: `192.0.2.1 - - [02/Jan/2024:03:04:05 +0000] "GET /items?page=2&token=REDACTED HTTP/1.1" 200 5`,
+		},
+		{
+			name:   "headers",
+			cfg:    AccessLogConfig{Headers: []string{"Authorization", "X-Tenant", "X-Missing"}},
+			target: "/items",
+			header: map[string]string{"Authorization": "Bearer secret", "X-Tenant": "acme"},
+			status: http.StatusOK,
+			fields: map[string]any{"headers": map[string]any{"Authorization": "REDACTED", "X-Tenant": "acme"}},
+		},
+		{
+			name:    "excluded",
+			cfg:     AccessLogConfig{ExcludePaths: []string{"/healthz"}},
+			target:  "/healthz",
+			status:  http.StatusOK,
+			skipped: true,
+		},
+		{
+			name:    "excluded prefix",
+			cfg:     AccessLogConfig{ExcludePaths: []string{"/static/*"}},
+			target:  "/static/app.js",
+			status:  http.StatusOK,
+			skipped: true,
+		},
+		{
+			name:   "prefix is not a path",
+			cfg:    AccessLogConfig{ExcludePaths: []string{"/static"}},
+			target: "/static/app.js",
+			status: http.StatusOK,
+		},
+		{
+			name:    "sampled out",
+			cfg:     AccessLogConfig{SampleRate: 1e-12},
+			target:  "/items",
+			status:  http.StatusOK,
+			skipped: true,
+		},
+		{
+			name:   "errors are never sampled out",
+			cfg:    AccessLogConfig{SampleRate: 1e-12},
+			target: "/items",
+			status: http.StatusInternalServerError,
+			level:  "error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			logger := zerolog.New(out)
+			cfg := tt.cfg
+			cfg.Logger = &logger
+
+			lc := newTestContext(http.MethodGet, tt.header)
+			lc.Request.URL, _ = url.Parse(tt.target)
+			lc.Request.RemoteAddr = "192.0.2.1:1234"
+			lc.StartTime = start
+			if err := ApplyRequests(lc, []Request{Request(AccessLog(cfg))}); err != nil {
+				t.Fatal(err)
+			}
+			lc.Response.WriteHeader(tt.status)
+			lc.BytesWritten = 5
+			lc.Complete()
+
+			if tt.skipped {
+				if out.Len() != 0 {
+					t.Errorf("logged %s", out)
+				}
+				return
+			}
+			event := map[string]any{}
+			if err := json.Unmarshal(out.Bytes(), &event); err != nil {
+				t.Fatalf("%v in %q", err, out)
+			}
+			if tt.message != "" && event["message"] != tt.message {
+				t.Errorf("message = %q, want %q", event["message"], tt.message)
+			}
+			if tt.level != "" && event["level"] != tt.level {
+				t.Errorf("level = %v, want %s", event["level"], tt.level)
+			}
+			for key, want := range tt.fields {
+				got, _ := json.Marshal(event[key])
+				wanted, _ := json.Marshal(want)
+				if string(got) != string(wanted) {
+					t.Errorf("%s = %s, want %s", key, got, wanted)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactURI(t *testing.T) {
+	tests := []struct {
+		target string
+		names  []string
+		want   string
+	}{
+		{"/a", []string{"token"}, "/a"},
+		{"/a?token=x", nil, "/a?token=x"},
+		{"/a?token=x&token=y", []string{"token"}, "/a?token=REDACTED&token=REDACTED"},
+		{"/a%20b?key=1", []string{"token"}, "/a%20b?key=1"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.target)
+		names := map[string]struct{}{}
+		for _, name := range tt.names {
+			names[name] = struct{}{}
+		}
+		if got := redactURI(u, names); got != tt.want {
+			t.Errorf("redactURI(%q, %q) = %q, want %q", tt.target, tt.names, got, tt.want)
+		}
+		if tt.target != u.String() {
+			t.Errorf("redactURI changed the request URL to %s", u)
+		}
+	}
+}
+
+func TestAccessLogRequestLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := zerolog.New(out)
+	lc := newTestContext(http.MethodGet, nil)
+	lc.Logger = &logger
+	lc.RequestContext = httptest.NewRequest(http.MethodGet, "/", nil).Context()
+
+	ApplyRequests(lc, []Request{Request(RequestID.New()), Request(AccessLog(AccessLogConfig{}))})
+	lc.Complete()
+	if !strings.Contains(out.String(), `"request_id":"`+lc.RequestID()+`"`) || strings.Count(out.String(), "request_id") != 1 {
+		t.Errorf("event %s does not carry the request id once", out)
+	}
+}
@@ -15,10 +15,23 @@ var CompressResponse = compressResponse{}
 
 type compressResponse struct{}
 
+// compressible reports whether res has a buffered body to compress. Informational, 204 and
+// 304 replies carry no body, so a Content-Encoding on them would describe nothing.
+func compressible(res *context.Response) bool {
+	if res.IsStream() || len(res.Body) == 0 {
+		return false
+	}
+	code := res.StatusCode
+	return code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
 type SnappyResponseMiddleware Response
 
 func (cr compressResponse) Snappy() SnappyResponseMiddleware {
 	return func(l *context.LuxContext) (*context.LuxContext, error) {
+		if !compressible(l.Response) {
+			return l, nil
+		}
 		acceptEncodings := strings.Split(l.Request.Header.Get("Accept-Encoding"), ", ")
 		if len(acceptEncodings) > 0 && acceptEncodings[0] != "snappy" || len(acceptEncodings) == 0 {
 			return l, nil
@@ -42,6 +55,9 @@ type GzipResponseMiddleware Response
 
 func (cr compressResponse) Gzip() GzipResponseMiddleware {
 	return func(l *context.LuxContext) (*context.LuxContext, error) {
+		if !compressible(l.Response) {
+			return l, nil
+		}
 		acceptEncodings := strings.Split(l.Request.Header.Get("Accept-Encoding"), ", ")
 		if len(acceptEncodings) > 0 && acceptEncodings[0] != "gzip" || len(acceptEncodings) == 0 {
 			return l, nil
@@ -69,6 +85,9 @@ type BrotliResponseMiddleware Response
 
 func (cr compressResponse) Brotli() BrotliResponseMiddleware {
 	return func(l *context.LuxContext) (*context.LuxContext, error) {
+		if !compressible(l.Response) {
+			return l, nil
+		}
 		acceptEncodings := strings.Split(l.Request.Header.Get("Accept-Encoding"), ", ")
 		if len(acceptEncodings) > 0 && acceptEncodings[0] != "br" || len(acceptEncodings) == 0 {
 			return l, nil
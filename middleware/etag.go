@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+)
+
+var ETag = etag{}
+
+type etag struct{}
+
+// HashETag tags body with its 64-bit FNV-1a hash.
+func HashETag(body []byte, weak bool) string {
+	h := fnv.New64a()
+	h.Write(body)
+	if weak {
+		return fmt.Sprintf("W/\"%016x\"", h.Sum64())
+	}
+	return fmt.Sprintf("\"%016x\"", h.Sum64())
+}
+
+type ETagResponseMiddleware Response
+
+// Strong tags buffered GET and HEAD replies and answers a matching If-None-Match with 304.
+// Put it before compression middlewares so the tag describes the uncompressed body; they
+// leave the empty body of a 304 as it is.
+func (e etag) Strong() ETagResponseMiddleware {
+	return e.tag(false)
+}
+
+func (e etag) Weak() ETagResponseMiddleware {
+	return e.tag(true)
+}
+
+func (e etag) tag(weak bool) ETagResponseMiddleware {
+	return func(l *context.LuxContext) (*context.LuxContext, error) {
+		if l.Request.Method != http.MethodGet && l.Request.Method != http.MethodHead {
+			return l, nil
+		}
+
+		res := l.Response
+		if res.IsStream() || res.StatusCode != http.StatusOK || res.Header().Get("ETag") != "" {
+			return l, nil
+		}
+		if strings.Contains(strings.ToLower(res.Header().Get("Cache-Control")), "no-store") {
+			return l, nil
+		}
+
+		value := HashETag(res.Body, weak)
+		res.Header().Set("ETag", value)
+		l.CheckPreconditions(time.Time{}, value, true)
+		return l, nil
+	}
+}
+
+// ETagResolver reports the current ETag of the resource a request targets and whether
+// the resource exists at all.
+type ETagResolver func(l *context.LuxContext) (etag string, exists bool, err error)
+
+type ETagPreconditionMiddleware Request
+
+// Precondition checks If-Match and If-None-Match on PUT, PATCH and DELETE before the
+// handler runs, answering 412 when the client's copy of the resource is out of date.
+// If-None-Match: * lets a PUT create the resource only when it does not exist yet.
+func (e etag) Precondition(resolve ETagResolver) ETagPreconditionMiddleware {
+	return func(l *context.LuxContext) (*context.LuxContext, int) {
+		switch l.Request.Method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return l, http.StatusOK
+		}
+
+		if l.Request.Header.Get("If-Match") == "" && l.Request.Header.Get("If-None-Match") == "" {
+			return l, http.StatusOK
+		}
+
+		current, exists, err := resolve(l)
+		if err != nil {
+			l.Logger.Error().Str("error", err.Error()).Msg("ETag precondition error")
+			return l, http.StatusInternalServerError
+		}
+
+		if l.CheckPreconditions(time.Time{}, current, exists) {
+			return l, Answered
+		}
+		return l, http.StatusOK
+	}
+}
+
+// FromHandler resolves the current ETag by running the resource's GET handler on a copy
+// of the request and hashing what it replies. 404 and 410 mean the resource is missing.
+func (e etag) FromHandler(handler func(*context.LuxContext) error, weak bool) ETagResolver {
+	return func(l *context.LuxContext) (string, bool, error) {
+		shadow := *l
+		shadow.Request = l.Request.Clone(l.Request.Context())
+		shadow.Request.Method = http.MethodGet
+		shadow.Request.Body = http.NoBody
+		shadow.Request.ContentLength = 0
+		shadow.Response = context.NewResponse()
+
+		if err := handler(&shadow); err != nil {
+			return "", false, err
+		}
+
+		res := shadow.Response
+		switch {
+		case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+			return "", false, nil
+		case res.StatusCode < 200 || res.StatusCode >= 300:
+			return "", false, fmt.Errorf("resolving etag of %s: %s", l.Request.URL.Path, http.StatusText(res.StatusCode))
+		}
+
+		if value := res.Header().Get("ETag"); value != "" {
+			return value, true, nil
+		}
+		return HashETag(res.Body, weak), true, nil
+	}
+}
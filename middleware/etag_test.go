@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snowmerak/lux/v3/context"
+)
+
+func newTestContext(method string, header map[string]string) *context.LuxContext {
+	r := httptest.NewRequest(method, "/resource", nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	logger := zerolog.Nop()
+	return &context.LuxContext{Request: r, Response: context.NewResponse(), Logger: &logger}
+}
+
+func TestHashETag(t *testing.T) {
+	tests := []struct {
+		body []byte
+		weak bool
+		want string
+	}{
+		{nil, false, `"cbf29ce484222325"`},
+		{[]byte("hello"), false, `"a430d84680aabd0b"`},
+		{[]byte("hello"), true, `W/"a430d84680aabd0b"`},
+	}
+	for _, tt := range tests {
+		if got := HashETag(tt.body, tt.weak); got != tt.want {
+			t.Errorf("HashETag(%q, %v) = %s, want %s", tt.body, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestETagTag(t *testing.T) {
+	body := []byte("hello")
+	strong := HashETag(body, false)
+	weak := HashETag(body, true)
+
+	tests := []struct {
+		name     string
+		weak     bool
+		method   string
+		header   map[string]string
+		status   int
+		set      map[string]string
+		wantTag  string
+		wantCode int
+	}{
+		{name: "strong", method: http.MethodGet, wantTag: strong, wantCode: http.StatusOK},
+		{name: "weak", weak: true, method: http.MethodGet, wantTag: weak, wantCode: http.StatusOK},
+		{name: "head", method: http.MethodHead, wantTag: strong, wantCode: http.StatusOK},
+		{name: "post", method: http.MethodPost, wantCode: http.StatusOK},
+		{name: "not ok", method: http.MethodGet, status: http.StatusNotFound, wantCode: http.StatusNotFound},
+		{name: "handler tag", method: http.MethodGet, set: map[string]string{"ETag": `"own"`}, wantTag: `"own"`, wantCode: http.StatusOK},
+		{name: "no-store", method: http.MethodGet, set: map[string]string{"Cache-Control": "No-Store"}, wantCode: http.StatusOK},
+		{name: "match", method: http.MethodGet, header: map[string]string{"If-None-Match": strong}, wantTag: strong, wantCode: http.StatusNotModified},
+		{name: "weak match", method: http.MethodGet, header: map[string]string{"If-None-Match": weak}, wantTag: strong, wantCode: http.StatusNotModified},
+		{name: "star", method: http.MethodGet, header: map[string]string{"If-None-Match": "*"}, wantTag: strong, wantCode: http.StatusNotModified},
+		{name: "no match", method: http.MethodGet, header: map[string]string{"If-None-Match": `"other"`}, wantTag: strong, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := newTestContext(tt.method, tt.header)
+			lc.Response.Body = append(lc.Response.Body, body...)
+			lc.Response.Header().Set("Content-Type", "text/plain")
+			if tt.status != 0 {
+				lc.Response.WriteHeader(tt.status)
+			}
+			for key, value := range tt.set {
+				lc.Response.Header().Set(key, value)
+			}
+
+			m := ETag.Strong()
+			if tt.weak {
+				m = ETag.Weak()
+			}
+			if err := ApplyResponses(lc, []Response{Response(m)}); err != nil {
+				t.Fatal(err)
+			}
+			if got := lc.Response.Header().Get("ETag"); got != tt.wantTag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantTag)
+			}
+			if lc.Response.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusNotModified && len(lc.Response.Body) != 0 {
+				t.Errorf("304 kept the body %q", lc.Response.Body)
+			}
+		})
+	}
+}
+
+func TestETagPrecondition(t *testing.T) {
+	current := `"v2"`
+	resolver := func(etag string, exists bool, err error) ETagResolver {
+		return func(*context.LuxContext) (string, bool, error) {
+			return etag, exists, err
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		header   map[string]string
+		resolve  ETagResolver
+		answered bool
+		code     int
+	}{
+		{"get is left alone", http.MethodGet, map[string]string{"If-Match": `"v1"`}, resolver(current, true, nil), false, http.StatusOK},
+		{"no condition", http.MethodPut, nil, resolver("", false, errors.New("not called")), false, http.StatusOK},
+		{"if-match current", http.MethodPut, map[string]string{"If-Match": current}, resolver(current, true, nil), false, http.StatusOK},
+		{"if-match stale", http.MethodPatch, map[string]string{"If-Match": `"v1"`}, resolver(current, true, nil), true, http.StatusPreconditionFailed},
+		{"if-match missing", http.MethodDelete, map[string]string{"If-Match": current}, resolver("", false, nil), true, http.StatusPreconditionFailed},
+		{"if-none-match star creates", http.MethodPut, map[string]string{"If-None-Match": "*"}, resolver("", false, nil), false, http.StatusOK},
+		{"if-none-match star exists", http.MethodPut, map[string]string{"If-None-Match": "*"}, resolver(current, true, nil), true, http.StatusPreconditionFailed},
+		{"resolver error", http.MethodPut, map[string]string{"If-Match": current}, resolver("", false, errors.New("db down")), false, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := newTestContext(tt.method, tt.header)
+			err := ApplyRequests(lc, []Request{Request(ETag.Precondition(tt.resolve))})
+
+			answered := ErrAnswered{}
+			if errors.As(err, &answered) != tt.answered {
+				t.Fatalf("err = %v, want answered %v", err, tt.answered)
+			}
+			if tt.answered && answered.Code != tt.code {
+				t.Errorf("answered with %d, want %d", answered.Code, tt.code)
+			}
+			if lc.Response.StatusCode != tt.code {
+				t.Errorf("status = %d, want %d", lc.Response.StatusCode, tt.code)
+			}
+		})
+	}
+}
+
+func TestETagFromHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(*context.LuxContext) error
+		etag    string
+		exists  bool
+		err     bool
+	}{
+		{
+			name:    "hashed body",
+			handler: func(lc *context.LuxContext) error { return lc.ReplyString("hello") },
+			etag:    HashETag([]byte("hello"), false),
+			exists:  true,
+		},
+		{
+			name: "handler etag",
+			handler: func(lc *context.LuxContext) error {
+				lc.Response.Header().Set("ETag", `"own"`)
+				return lc.ReplyString("hello")
+			},
+			etag:   `"own"`,
+			exists: true,
+		},
+		{
+			name:    "gone",
+			handler: func(lc *context.LuxContext) error { lc.SetNotFound(); return nil },
+		},
+		{
+			name:    "failing status",
+			handler: func(lc *context.LuxContext) error { lc.SetInternalServerError(); return nil },
+			err:     true,
+		},
+		{
+			name:    "failing handler",
+			handler: func(lc *context.LuxContext) error { return errors.New("boom") },
+			err:     true,
+		},
+		{
+			name: "runs as get",
+			handler: func(lc *context.LuxContext) error {
+				return lc.ReplyString(lc.Request.Method)
+			},
+			etag:   HashETag([]byte(http.MethodGet), false),
+			exists: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := newTestContext(http.MethodPut, nil)
+			etag, exists, err := ETag.FromHandler(tt.handler, false)(lc)
+			if (err != nil) != tt.err || etag != tt.etag || exists != tt.exists {
+				t.Errorf("got %q, %v, %v, want %q, %v, error %v", etag, exists, err, tt.etag, tt.exists, tt.err)
+			}
+			if lc.Response.StatusCode != http.StatusOK || len(lc.Response.Body) != 0 {
+				t.Error("resolver touched the response of the request")
+			}
+		})
+	}
+}
@@ -5,22 +5,47 @@ import (
 	"net/http"
 
 	"github.com/snowmerak/lux/v3/context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type Request func(*context.LuxContext) (*context.LuxContext, int)
 type Response func(*context.LuxContext) (*context.LuxContext, error)
 
+// Answered is the code a request middleware returns once it has answered the request itself,
+// e.g. with a 304 or 412 from ETag.Precondition. The rest of the request middlewares and the
+// handler are skipped and the response goes out as a reply, not through the error handler.
+const Answered = -1
+
+// ErrAnswered is what ApplyRequests returns when a middleware returned Answered.
+type ErrAnswered struct {
+	Code int
+}
+
+func (e ErrAnswered) Error() string {
+	return "answered by middleware: " + http.StatusText(e.Code)
+}
+
 func ApplyRequests(ctx *context.LuxContext, middlewares []Request) error {
 	for _, m := range middlewares {
 		if m == nil {
 			continue
 		}
 
+		span := middlewareSpan(ctx, "request middleware", m)
 		_, code := m(ctx)
+		span.SetAttributes(attribute.Int("lux.middleware.code", code))
+		if code == Answered {
+			span.End()
+			return ErrAnswered{Code: ctx.Response.StatusCode}
+		}
 		if 400 <= code && code < 600 {
+			span.SetStatus(codes.Error, http.StatusText(code))
+			span.End()
 			ctx.Response.WriteHeader(code)
 			return fmt.Errorf("middleware request reading %s: %s from %s", ctx.Request.URL.Path, http.StatusText(code), ctx.Request.RemoteAddr)
 		}
+		span.End()
 	}
 	return nil
 }
@@ -31,10 +56,15 @@ func ApplyResponses(ctx *context.LuxContext, middlewares []Response) error {
 			continue
 		}
 
+		span := middlewareSpan(ctx, "response middleware", m)
 		_, err := m(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
 			return fmt.Errorf("middleware response writing %s: %s from %s", ctx.Request.URL.Path, err.Error(), ctx.Request.RemoteAddr)
 		}
+		span.End()
 	}
 	return nil
 }
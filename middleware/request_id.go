@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	ctx "context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/snowmerak/lux/v3/context"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultRequestIDHeader = "X-Request-ID"
+
+var RequestID = requestID{}
+
+type requestID struct{}
+
+type RequestIDConfig struct {
+	// Header carries the request id both ways, DefaultRequestIDHeader when empty.
+	Header string
+	// Generate makes ids for requests without a usable one, 16 random hex bytes when nil.
+	Generate func() string
+	// IgnoreIncoming always generates a new id instead of trusting the client's.
+	IgnoreIncoming bool
+	// DisableTrace skips traceparent and tracestate handling.
+	DisableTrace bool
+}
+
+type RequestIDMiddleware Request
+
+// New accepts or generates a request id and continues or starts a W3C trace, as in WithConfig.
+func (r requestID) New() RequestIDMiddleware {
+	return r.WithConfig(RequestIDConfig{})
+}
+
+// WithConfig stores the request id and the server span's trace context on RequestContext,
+// echoes them in the response headers and replaces Logger with a child logging both.
+func (r requestID) WithConfig(cfg RequestIDConfig) RequestIDMiddleware {
+	if cfg.Header == "" {
+		cfg.Header = DefaultRequestIDHeader
+	}
+	if cfg.Generate == nil {
+		cfg.Generate = generateRequestID
+	}
+
+	return func(l *context.LuxContext) (*context.LuxContext, int) {
+		id := ""
+		if !cfg.IgnoreIncoming {
+			id = l.Request.Header.Get(cfg.Header)
+		}
+		if !validRequestID(id) {
+			id = cfg.Generate()
+		}
+
+		requestContext := context.WithRequestID(l.RequestContext, id)
+		l.Response.Header().Set(cfg.Header, id)
+
+		tc := context.TraceContext{}
+		if !cfg.DisableTrace {
+			tc = serverTraceContext(l.RequestContext, l.Request.Header)
+			requestContext = context.WithTraceContext(requestContext, tc)
+			l.Response.Header().Set("traceparent", tc.TraceParent())
+			if tc.State != "" {
+				l.Response.Header().Set("tracestate", tc.State)
+			}
+		}
+
+		if l.Logger != nil {
+			logContext := l.Logger.With().Str("request_id", id)
+			if tc.IsValid() {
+				logContext = logContext.Str("trace_id", tc.TraceIDString()).Str("span_id", tc.SpanIDString())
+			}
+			logger := logContext.Logger()
+			l.Logger = &logger
+		}
+
+		l.RequestContext = requestContext
+		l.Request = l.Request.WithContext(requestContext)
+		return l, http.StatusOK
+	}
+}
+
+// serverTraceContext reuses the OpenTelemetry server span when lux has one, otherwise it
+// continues the caller's trace under a new span id or starts a trace.
+func serverTraceContext(requestContext ctx.Context, header http.Header) context.TraceContext {
+	if sc := trace.SpanContextFromContext(requestContext); sc.IsValid() && !sc.IsRemote() {
+		return context.TraceContext{
+			TraceID: sc.TraceID(),
+			SpanID:  sc.SpanID(),
+			Flags:   byte(sc.TraceFlags()),
+			State:   sc.TraceState().String(),
+		}
+	}
+
+	parent, ok := context.ParseTraceParent(header.Get("traceparent"))
+	if !ok {
+		return context.NewTraceContext()
+	}
+	tc := parent.Child()
+	if state := strings.Join(header.Values("tracestate"), ","); validTraceState(state) {
+		tc.State = state
+	}
+	return tc
+}
+
+// validTraceState holds tracestate to the W3C limits, 512 bytes and 32 well formed list
+// members, and drops it whole otherwise.
+func validTraceState(state string) bool {
+	if len(state) > 512 {
+		return false
+	}
+	_, err := trace.ParseTraceState(state)
+	return err == nil
+}
+
+func generateRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// validRequestID keeps client ids short and printable so they are safe in logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
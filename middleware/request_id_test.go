@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/context"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"req_01H8Z:42/x", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"tab\t", false},
+		{"ünïcode", false},
+		{"\x7f", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestValidTraceState(t *testing.T) {
+	members := func(n int) string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("k%d=v", i)
+		}
+		return strings.Join(list, ",")
+	}
+
+	tests := []struct {
+		name  string
+		state string
+		want  bool
+	}{
+		{"empty", "", true},
+		{"one", "congo=t61rcWkgMzE", true},
+		{"two", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", true},
+		{"tenant", "tenant@vendor=value", true},
+		{"32 members", members(32), true},
+		{"33 members", members(33), false},
+		{"too long", "k=" + strings.Repeat("v", 511), false},
+		{"upper case key", "Congo=t61rcWkgMzE", false},
+		{"no value", "congo", false},
+		{"duplicate key", "a=1,a=2", false},
+	}
+	for _, tt := range tests {
+		if got := validTraceState(tt.state); got != tt.want {
+			t.Errorf("%s: validTraceState(%q) = %v, want %v", tt.name, tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name      string
+		cfg       RequestIDConfig
+		header    map[string]string
+		id        string
+		traceID   string
+		state     string
+		noTrace   bool
+		generated bool
+	}{
+		{name: "generated", generated: true},
+		{name: "incoming", header: map[string]string{"X-Request-ID": "client-1"}, id: "client-1"},
+		{name: "invalid incoming", header: map[string]string{"X-Request-ID": "bad id"}, generated: true},
+		{name: "ignored incoming", cfg: RequestIDConfig{IgnoreIncoming: true}, header: map[string]string{"X-Request-ID": "client-1"}, generated: true},
+		{name: "custom header", cfg: RequestIDConfig{Header: "X-Correlation-ID"}, header: map[string]string{"X-Correlation-ID": "c-1"}, id: "c-1"},
+		{name: "custom generator", cfg: RequestIDConfig{Generate: func() string { return "fixed" }}, id: "fixed"},
+		{
+			name:    "continued trace",
+			header:  map[string]string{"traceparent": parent, "tracestate": "congo=t61rcWkgMzE"},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			state:   "congo=t61rcWkgMzE",
+		},
+		{
+			name:    "invalid tracestate dropped",
+			header:  map[string]string{"traceparent": parent, "tracestate": "Bad State"},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{name: "trace disabled", cfg: RequestIDConfig{DisableTrace: true}, header: map[string]string{"traceparent": parent}, noTrace: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := newTestContext(http.MethodGet, tt.header)
+			lc.RequestContext = lc.Request.Context()
+			if err := ApplyRequests(lc, []Request{Request(RequestID.WithConfig(tt.cfg))}); err != nil {
+				t.Fatal(err)
+			}
+
+			header := tt.cfg.Header
+			if header == "" {
+				header = DefaultRequestIDHeader
+			}
+			id := lc.RequestID()
+			if lc.Response.Header().Get(header) != id || id == "" {
+				t.Errorf("request id %q, header %q", id, lc.Response.Header().Get(header))
+			}
+			if tt.generated && (len(id) != 32 || id == tt.header["X-Request-ID"]) {
+				t.Errorf("id = %q, want a generated one", id)
+			}
+			if tt.id != "" && id != tt.id {
+				t.Errorf("id = %q, want %q", id, tt.id)
+			}
+
+			tc, ok := lc.TraceContext()
+			if ok == tt.noTrace {
+				t.Fatalf("trace context set %v, want %v", ok, !tt.noTrace)
+			}
+			if tt.noTrace {
+				if lc.Response.Header().Get("traceparent") != "" {
+					t.Error("traceparent was echoed")
+				}
+				return
+			}
+			if lc.Response.Header().Get("traceparent") != tc.TraceParent() {
+				t.Errorf("traceparent = %q, want %q", lc.Response.Header().Get("traceparent"), tc.TraceParent())
+			}
+			if tt.traceID != "" && (tc.TraceIDString() != tt.traceID || tc.SpanIDString() == "00f067aa0ba902b7") {
+				t.Errorf("trace %s span %s does not continue the parent", tc.TraceIDString(), tc.SpanIDString())
+			}
+			if tc.State != tt.state || lc.Response.Header().Get("tracestate") != tt.state {
+				t.Errorf("tracestate = %q, header %q, want %q", tc.State, lc.Response.Header().Get("tracestate"), tt.state)
+			}
+			if got, _ := context.RequestIDFrom(lc.Request.Context()); got != id {
+				t.Errorf("request context carries %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/snowmerak/lux/v3/context"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/snowmerak/lux/v3/middleware"
+
+// StartSpan starts a child of the request span on the tracer provider that span came from
+// and makes it current on lc until the returned end function is called.
+func StartSpan(lc *context.LuxContext, name string, opts ...trace.SpanStartOption) (trace.Span, func()) {
+	parent := lc.RequestContext
+	if parent == nil {
+		return trace.SpanFromContext(nil), func() {}
+	}
+
+	spanCtx, span := trace.SpanFromContext(parent).TracerProvider().Tracer(tracerName).Start(parent, name, opts...)
+	lc.RequestContext = spanCtx
+	lc.Request = lc.Request.WithContext(spanCtx)
+	return span, func() {
+		span.End()
+		if lc.RequestContext == spanCtx {
+			lc.RequestContext = parent
+			lc.Request = lc.Request.WithContext(parent)
+		}
+	}
+}
+
+// middlewareSpan traces m without making its span current, since middlewares may replace
+// RequestContext for the rest of the request.
+func middlewareSpan(lc *context.LuxContext, kind string, m any) trace.Span {
+	if lc.RequestContext == nil {
+		return trace.SpanFromContext(nil)
+	}
+	_, span := trace.SpanFromContext(lc.RequestContext).TracerProvider().Tracer(tracerName).Start(lc.RequestContext, kind+" "+funcName(m))
+	return span
+}
+
+// funcName names a middleware for its span, e.g. "middleware.Auth.func1".
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "anonymous"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
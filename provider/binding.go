@@ -0,0 +1,367 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// key identifies a provided value by its type and, for named values, its name. Values in a
+// group are told apart by member, the index of their constructor plus one; a key without
+// member asks for the whole group.
+type key struct {
+	typ    reflect.Type
+	name   string
+	group  string
+	member int
+}
+
+func (k key) String() string {
+	switch {
+	case k.group != "":
+		return fmt.Sprintf("%s group:%q", k.typ, k.group)
+	case k.name != "":
+		return fmt.Sprintf("%s name:%q", k.typ, k.name)
+	}
+	return k.typ.String()
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Annotated is a constructor registered with annotations, made by Annotate.
+type Annotated struct {
+	Constructor any
+	Name        string
+	As          []reflect.Type
+	Lifetime    Lifetime
+	Lazy        bool
+	Group       string
+}
+
+type Annotation func(*Annotated)
+
+// Annotate changes what constructor provides, e.g.
+// p.Register(provider.Annotate(NewPgStore, provider.As(new(Store)), provider.Named("primary"))).
+func Annotate(constructor any, annotations ...Annotation) Annotated {
+	a := Annotated{Constructor: constructor}
+	for _, annotate := range annotations {
+		annotate(&a)
+	}
+	return a
+}
+
+// Named provides the values of a constructor under name, so that several values of one type
+// can coexist. Constructors ask for them with a `name:"..."` tag on a field of an In struct.
+func Named(name string) Annotation {
+	return func(a *Annotated) {
+		a.Name = name
+	}
+}
+
+// Group adds the values of a constructor to group instead of providing them on their own, so
+// that any number of constructors can provide the same type. Constructors get every value of
+// a group with a `group:"..."` tag on a slice field of an In struct.
+func Group(group string) Annotation {
+	return func(a *Annotated) {
+		a.Group = group
+	}
+}
+
+// As also provides the value of a constructor as each interface, given as a nil pointer,
+// e.g. As(new(Store)).
+func As(interfaces ...any) Annotation {
+	return func(a *Annotated) {
+		for _, i := range interfaces {
+			a.As = append(a.As, reflect.TypeOf(i).Elem())
+		}
+	}
+}
+
+// WithLifetime sets how often a constructor is called, Singleton by default.
+func WithLifetime(lifetime Lifetime) Annotation {
+	return func(a *Annotated) {
+		a.Lifetime = lifetime
+	}
+}
+
+// Bind provides the value of Impl as the interface I.
+func Bind[I, Impl any](p *Provider) error {
+	iface, impl := typeOf[I](), typeOf[Impl]()
+	n := &node{
+		con: reflect.MakeFunc(reflect.FuncOf([]reflect.Type{impl}, []reflect.Type{iface}, false), func(args []reflect.Value) []reflect.Value {
+			v := reflect.New(iface).Elem()
+			v.Set(args[0])
+			return []reflect.Value{v}
+		}),
+		name:     fmt.Sprintf("provider.Bind[%s, %s]", iface, impl),
+		params:   []param{{typ: impl, keys: []key{{typ: impl}}, optional: []bool{false}}},
+		provides: []key{{typ: iface}},
+		lifetime: Transient,
+		alias:    true,
+	}
+	if _, file, line, ok := runtime.Caller(1); ok {
+		n.location = fmt.Sprintf("%s:%d", file, line)
+	}
+	if iface.Kind() != reflect.Interface || !impl.Implements(iface) {
+		return ErrNotImplemented{Interface: iface, Type: impl, Constructor: n.String()}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.add(n)
+}
+
+// In marks a struct argument whose exported fields are resolved one by one, so that a
+// constructor can ask for named, optional and grouped values:
+//
+//	type StoreParams struct {
+//		provider.In
+//		Primary *sql.DB   `name:"primary"`
+//		Replica *sql.DB   `name:"replica" optional:"true"`
+//		Plugins []Plugin  `group:"plugins"`
+//	}
+type In struct{}
+
+var inType = reflect.TypeOf(In{})
+
+// param is an argument of a constructor and the keys it is resolved from: one for a plain
+// argument, one per field for an In struct.
+type param struct {
+	typ      reflect.Type
+	keys     []key
+	optional []bool
+	fields   []int
+}
+
+func newParam(t reflect.Type) (param, error) {
+	p := param{typ: t}
+	if !isIn(t) {
+		p.keys = []key{{typ: t}}
+		p.optional = []bool{false}
+		return p, nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type == inType || !field.IsExported() {
+			continue
+		}
+		k := key{typ: field.Type, name: field.Tag.Get("name"), group: field.Tag.Get("group")}
+		if k.group != "" && (field.Type.Kind() != reflect.Slice || k.name != "") {
+			return p, ErrInvalidField{Struct: t, Field: field.Name, Reason: "a group field must be an unnamed slice"}
+		}
+		optional, err := parseOptional(field.Tag.Get("optional"))
+		if err != nil {
+			return p, ErrInvalidField{Struct: t, Field: field.Name, Reason: err.Error()}
+		}
+		p.keys = append(p.keys, k)
+		p.optional = append(p.optional, optional)
+		p.fields = append(p.fields, i)
+	}
+	return p, nil
+}
+
+func parseOptional(tag string) (bool, error) {
+	if tag == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(tag)
+}
+
+type ErrInvalidField struct {
+	Struct reflect.Type
+	Field  string
+	Reason string
+}
+
+func (e ErrInvalidField) Error() string {
+	return fmt.Sprintf("invalid field %s.%s: %s", e.Struct, e.Field, e.Reason)
+}
+
+func isIn(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Anonymous && field.Type == inType {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve finds what satisfies k: k itself when it is provided, or else the one concrete
+// provided type with the same name implementing the interface k. When several do, it returns
+// them all as candidates instead.
+func (p *Provider) resolve(k key) (key, []key) {
+	if p.available(k) {
+		return k, nil
+	}
+	if k.typ.Kind() != reflect.Interface {
+		return key{}, nil
+	}
+
+	self := key{typ: reflect.TypeOf(p)}
+	seen := make(map[key]struct{})
+	candidates := []key(nil)
+	consider := func(c key) {
+		if _, ok := seen[c]; ok || c == self || c.name != k.name || c.group != "" || c.typ.Kind() == reflect.Interface || !c.typ.Implements(k.typ) {
+			return
+		}
+		seen[c] = struct{}{}
+		candidates = append(candidates, c)
+	}
+	for _, n := range p.nodes {
+		for _, c := range n.provides {
+			consider(c)
+		}
+	}
+	for c := range p.container {
+		consider(c)
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].String() < candidates[j].String() })
+	return key{}, candidates
+}
+
+// providersOf returns the constructors that provide what satisfies k: those of the group
+// members for a group, else the one that provides it, if any.
+func (p *Provider) providersOf(k key) []*node {
+	if k.group == "" || k.member != 0 {
+		if n, ok := p.providerOf(k); ok {
+			return []*node{n}
+		}
+		return nil
+	}
+
+	members := []*node(nil)
+	for _, n := range p.nodes {
+		if n.group == k.group && len(n.members(k.typ.Elem())) > 0 {
+			members = append(members, n)
+		}
+	}
+	return members
+}
+
+// providerOf returns the constructor that provides what satisfies k, if any.
+func (p *Provider) providerOf(k key) (*node, bool) {
+	resolved, _ := p.resolve(k)
+	if resolved.typ == nil {
+		return nil, false
+	}
+	n, ok := p.providers[resolved]
+	return n, ok
+}
+
+// arguments builds the arguments of a call from provided values, building transient values
+// and, within s, request scoped ones. locked tells whether the caller holds the lock of p.
+func (p *Provider) arguments(ctx context.Context, n *node, s *Scope, locked bool) ([]reflect.Value, error) {
+	args := make([]reflect.Value, len(n.params))
+	for i, param := range n.params {
+		values := make([]reflect.Value, len(param.keys))
+		for j, k := range param.keys {
+			if param.optional[j] && k.group == "" {
+				var resolved key
+				var candidates []key
+				p.reading(locked, func() {
+					resolved, candidates = p.resolve(k)
+				})
+				if resolved.typ == nil && candidates == nil {
+					values[j] = reflect.Zero(k.typ)
+					continue
+				}
+			}
+			v, err := p.instance(ctx, k, n, s, locked)
+			if err != nil {
+				return nil, err
+			}
+			values[j] = v
+		}
+
+		if param.fields == nil {
+			args[i] = values[0]
+			continue
+		}
+		args[i] = reflect.New(param.typ).Elem()
+		for j, field := range param.fields {
+			args[i].Field(field).Set(values[j])
+		}
+	}
+	return args, nil
+}
+
+func keyStrings(keys []key) []string {
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = k.String()
+	}
+	return s
+}
+
+// ErrAmbiguousDependency is returned when a constructor needs an interface that several
+// provided types implement. Bind one of them, or provide it As the interface.
+type ErrAmbiguousDependency struct {
+	Type        reflect.Type
+	Name        string
+	Constructor string
+	Candidates  []string
+}
+
+func (e ErrAmbiguousDependency) Error() string {
+	return fmt.Sprintf("ambiguous dependency: %s needs %s, which %s all implement", e.Constructor, key{typ: e.Type, name: e.Name}, strings.Join(e.Candidates, ", "))
+}
+
+type ErrNotImplemented struct {
+	Interface   reflect.Type
+	Type        reflect.Type
+	Constructor string
+}
+
+func (e ErrNotImplemented) Error() string {
+	if e.Type == nil {
+		return fmt.Sprintf("%s provides nothing implementing %s", e.Constructor, e.Interface)
+	}
+	return fmt.Sprintf("%s: %s does not implement %s", e.Constructor, e.Type, e.Interface)
+}
+
+// GetNamed returns the value of type T provided under name.
+func GetNamed[T any](provider *Provider, name string) (T, bool) {
+	return get[T](provider, key{typ: typeOf[T](), name: name})
+}
+
+// LookupNamed is GetNamed telling why there is no value.
+func LookupNamed[T any](provider *Provider, name string) (T, error) {
+	return lookup[T](provider, key{typ: typeOf[T](), name: name})
+}
+
+func get[T any](p *Provider, k key) (T, bool) {
+	v, err := lookup[T](p, k)
+	return v, err == nil && any(v) != nil
+}
+
+func lookup[T any](p *Provider, k key) (T, error) {
+	if err := p.constructLazy(k); err != nil {
+		return *new(T), err
+	}
+	return value[T](p, k)
+}
+
+func value[T any](p *Provider, k key) (T, error) {
+	var t T
+	v, err := p.instance(p.context(), k, nil, nil, false)
+	if err != nil {
+		return t, err
+	}
+	if v.IsValid() && v.CanInterface() && v.Interface() != nil {
+		t = v.Interface().(T)
+	}
+	return t, nil
+}
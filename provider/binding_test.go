@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type store interface {
+	Load() string
+}
+
+type pgStore struct{ name string }
+
+func (s *pgStore) Load() string { return "pg " + s.name }
+
+type memStore struct{}
+
+func (s *memStore) Load() string { return "mem" }
+
+func newPgStore() *pgStore   { return &pgStore{name: "primary"} }
+func newMemStore() *memStore { return &memStore{} }
+
+// storeUser needs a store, to check how the interface is resolved.
+type storeUser struct{ loaded string }
+
+func newStoreUser(s store) storeUser { return storeUser{loaded: s.Load()} }
+
+func TestBinding(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  func(p *Provider) error
+		loaded    string
+		ambiguous bool
+	}{
+		{
+			name: "one implementation",
+			register: func(p *Provider) error {
+				return p.Register(newPgStore, newStoreUser)
+			},
+			loaded: "pg primary",
+		},
+		{
+			name: "several implementations",
+			register: func(p *Provider) error {
+				return p.Register(newPgStore, newMemStore, newStoreUser)
+			},
+			ambiguous: true,
+		},
+		{
+			name: "bound",
+			register: func(p *Provider) error {
+				if err := p.Register(newPgStore, newMemStore, newStoreUser); err != nil {
+					return err
+				}
+				return Bind[store, *memStore](p)
+			},
+			loaded: "mem",
+		},
+		{
+			name: "provided as",
+			register: func(p *Provider) error {
+				return p.Register(Annotate(newPgStore, As(new(store))), newMemStore, newStoreUser)
+			},
+			loaded: "pg primary",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := tt.register(p); err != nil {
+				t.Fatal(err)
+			}
+
+			err := p.Construct(context.Background())
+			ambiguous := ErrAmbiguousDependency{}
+			if got := errors.As(err, &ambiguous); got != tt.ambiguous {
+				t.Fatalf("Construct = %v, want ambiguous %v", err, tt.ambiguous)
+			}
+			if tt.ambiguous {
+				if len(ambiguous.Candidates) != 2 {
+					t.Errorf("candidates = %q, want both stores", ambiguous.Candidates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user, _ := Get[storeUser](p); user.loaded != tt.loaded {
+				t.Errorf("loaded = %q, want %q", user.loaded, tt.loaded)
+			}
+			if s, ok := Get[store](p); !ok || s.Load() != tt.loaded {
+				t.Errorf("Get[store] = %v, %v, want %q", s, ok, tt.loaded)
+			}
+		})
+	}
+}
+
+func TestNotImplemented(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(p *Provider) error
+	}{
+		{"as", func(p *Provider) error { return p.Register(Annotate(newGraphA, As(new(store)))) }},
+		{"as a struct", func(p *Provider) error { return p.Register(Annotate(newPgStore, As(new(pgStore)))) }},
+		{"bind", func(p *Provider) error { return Bind[store, graphA](p) }},
+		{"bind a struct", func(p *Provider) error { return Bind[pgStore, *pgStore](p) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.register(New())
+			if !errors.As(err, &ErrNotImplemented{}) {
+				t.Errorf("err = %v, want ErrNotImplemented", err)
+			}
+		})
+	}
+}
+
+type replicaParams struct {
+	In
+	Primary *pgStore `name:"primary"`
+	Replica *pgStore `name:"replica"`
+	Backup  *pgStore `name:"backup" optional:"true"`
+}
+
+type replicated struct{ primary, replica, backup string }
+
+func TestNamed(t *testing.T) {
+	p := New()
+	err := p.Register(
+		Annotate(func() *pgStore { return &pgStore{name: "primary"} }, Named("primary")),
+		Annotate(func() *pgStore { return &pgStore{name: "replica"} }, Named("replica")),
+		func(params replicaParams) replicated {
+			r := replicated{primary: params.Primary.name, replica: params.Replica.name}
+			if params.Backup != nil {
+				r.backup = params.Backup.name
+			}
+			return r
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := Get[replicated](p); got != (replicated{primary: "primary", replica: "replica"}) {
+		t.Errorf("replicated = %+v", got)
+	}
+
+	tests := []struct {
+		name  string
+		found bool
+	}{
+		{"primary", true},
+		{"replica", true},
+		{"backup", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		s, ok := GetNamed[*pgStore](p, tt.name)
+		if ok != tt.found || ok && s.name != tt.name {
+			t.Errorf("GetNamed(%q) = %v, %v, want found %v", tt.name, s, ok, tt.found)
+		}
+		if _, err := LookupNamed[*pgStore](p, tt.name); (err == nil) != tt.found {
+			t.Errorf("LookupNamed(%q) = %v", tt.name, err)
+		}
+	}
+}
+
+func TestNamedMissing(t *testing.T) {
+	p := New()
+	err := p.Register(
+		Annotate(func() *pgStore { return &pgStore{name: "primary"} }, Named("primary")),
+		func(params replicaParams) replicated { return replicated{} },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := ErrMissingDependency{}
+	if err := p.Validate(); !errors.As(err, &missing) || missing.Name != "replica" {
+		t.Errorf("Validate = %v, want the replica missing", err)
+	}
+}
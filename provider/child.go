@@ -0,0 +1,295 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Child returns a provider with the constructors registered in p so far, which Register,
+// Replace and Decorate change without affecting p. The child calls its constructors itself,
+// except that it shares the singletons p has already constructed when nothing they depend on
+// was replaced, decorated or registered in the child. The child stops only what it constructed.
+func (p *Provider) Child() *Provider {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	c := New()
+	c.parent = p
+	c.tracerProvider = p.tracerProvider
+	c.concurrency = p.concurrency
+	p.lifecycleLock.Lock()
+	c.startTimeout, c.stopTimeout = p.startTimeout, p.stopTimeout
+	p.lifecycleLock.Unlock()
+
+	for _, n := range p.nodes {
+		inherited := *n
+		inherited.constructed = false
+		inherited.order = 0
+		inherited.inherited = n
+		c.nodes = append(c.nodes, &inherited)
+		for _, k := range inherited.provides {
+			c.providers[k] = &inherited
+		}
+	}
+	for k, v := range p.container {
+		if _, ok := p.providers[k]; ok || k == (key{typ: reflect.TypeOf(p)}) || k == (key{typ: getContextType()}) {
+			continue
+		}
+		c.container[k] = v
+	}
+	return c
+}
+
+// shareable reports whether n and every constructor it depends on, recursively, were
+// inherited from the parent unchanged.
+func (p *Provider) shareable(n *node, memo map[*node]bool) bool {
+	if ok, seen := memo[n]; seen {
+		return ok
+	}
+	memo[n] = false
+	if n.inherited == nil {
+		return false
+	}
+	for _, arg := range n.deps() {
+		for _, dep := range p.providersOf(arg) {
+			if !p.shareable(dep, memo) {
+				return false
+			}
+		}
+	}
+	memo[n] = true
+	return true
+}
+
+// inheritedValues returns the values the parent constructed for n, when n can share them.
+func (p *Provider) inheritedValues(n *node, memo map[*node]bool) (map[key]any, bool) {
+	if p.parent == nil || n.lifetime != Singleton || !p.shareable(n, memo) {
+		return nil, false
+	}
+
+	p.parent.lock.RLock()
+	defer p.parent.lock.RUnlock()
+	if !n.inherited.constructed {
+		return nil, false
+	}
+	values := make(map[key]any, len(n.provides))
+	for _, k := range n.provides {
+		v, ok := p.parent.container[k]
+		if !ok {
+			return nil, false
+		}
+		values[k] = v
+	}
+	return values, true
+}
+
+// Replace registers constructors in place of the ones providing the same values, e.g. a fake
+// mailer in a test. A constructor providing an interface replaces the one providing the type
+// that implements it. It fails when a replaced constructor was already called.
+func (p *Provider) Replace(constructors ...any) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, con := range constructors {
+		if err := p.replace(con); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Provider) replace(constructFunction any) error {
+	n, err := newNode(constructFunction, p.nextIndex())
+	if err != nil {
+		return err
+	}
+
+	replaced := []*node(nil)
+	seen := make(map[*node]struct{})
+	for _, k := range n.provides {
+		resolved, candidates := p.resolve(k)
+		if candidates != nil {
+			return ErrAmbiguousDependency{Type: k.typ, Name: k.name, Constructor: n.String(), Candidates: keyStrings(candidates)}
+		}
+		old, ok := p.providers[resolved]
+		if !ok {
+			continue
+		}
+		if old.constructed {
+			return ErrAlreadyConstructed{Constructor: old.String()}
+		}
+		if _, ok := seen[old]; !ok {
+			seen[old] = struct{}{}
+			replaced = append(replaced, old)
+		}
+	}
+	if replaced == nil {
+		if len(n.provides) == 0 {
+			return ErrInvalidConstructorReturn{}
+		}
+		return ErrNotProvided{Type: n.provides[0].typ, Name: n.provides[0].name}
+	}
+
+	// n takes the place of the first constructor it replaces, so that group values keep
+	// their order.
+	if n.index != replaced[0].index {
+		if n, err = newNode(constructFunction, replaced[0].index); err != nil {
+			return err
+		}
+	}
+	p.swap(replaced, n)
+	return nil
+}
+
+// swap removes the replaced constructors and puts n in place of the first one.
+func (p *Provider) swap(replaced []*node, n *node) {
+	removed := make(map[*node]struct{}, len(replaced))
+	for _, old := range replaced {
+		removed[old] = struct{}{}
+		for _, k := range old.provides {
+			delete(p.providers, k)
+		}
+	}
+
+	nodes := make([]*node, 0, len(p.nodes))
+	for _, other := range p.nodes {
+		switch _, ok := removed[other]; {
+		case other == replaced[0]:
+			nodes = append(nodes, n)
+		case !ok:
+			nodes = append(nodes, other)
+		}
+	}
+	p.nodes = nodes
+	for _, k := range n.provides {
+		p.providers[k] = n
+	}
+}
+
+// Decorate wraps a provided value: decorators get it, along with any other provided values
+// they need, and return what is provided instead, optionally with an error, e.g.
+// p.Decorate(func(m Mailer, l *Logger) Mailer { return &loggedMailer{m, l} }). A decorator of
+// a named value is annotated with Named. It fails when the constructor was already called.
+func (p *Provider) Decorate(decorators ...any) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, decorator := range decorators {
+		if err := p.decorate(decorator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Provider) decorate(decorator any) error {
+	d, err := newNode(decorator, -1)
+	if err != nil {
+		return err
+	}
+	if t := d.con.Type(); len(d.provides) != 1 || t.NumOut() == 2 && t.Out(1) != errorType {
+		return ErrInvalidDecorator{Decorator: d.String(), Reason: "it must return one value and optionally an error"}
+	}
+	// the decorated value is the argument of the type returned, named like the decorator
+	k := d.provides[0]
+	at := -1
+	for i, param := range d.params {
+		if param.fields == nil && param.keys[0].typ == k.typ {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return ErrInvalidDecorator{Decorator: d.String(), Reason: fmt.Sprintf("it must take the %s it returns", k)}
+	}
+
+	n, ok := p.providers[k]
+	if !ok {
+		return ErrNotProvided{Type: k.typ, Name: k.name}
+	}
+	if n.constructed {
+		return ErrAlreadyConstructed{Constructor: n.String()}
+	}
+	target := -1
+	for i := 0; i < n.con.Type().NumOut(); i++ {
+		if n.key(n.con.Type().Out(i)) == k {
+			target = i
+		}
+	}
+	if target < 0 {
+		return ErrInvalidDecorator{Decorator: d.String(), Reason: fmt.Sprintf("%s provides %s with As, decorate %s instead", n.name, k, n.as[k.typ])}
+	}
+
+	p.swap([]*node{n}, decorated(n, d, at, target))
+	return nil
+}
+
+// decorated returns a copy of n that passes the value it returns at target to d, as the
+// argument at, and returns what d returns in its place. The other arguments of d follow
+// those of n.
+func decorated(n *node, d *node, at int, target int) *node {
+	ins := []reflect.Type(nil)
+	params := append([]param(nil), n.params...)
+	for _, param := range n.params {
+		ins = append(ins, param.typ)
+	}
+	for i, param := range d.params {
+		if i != at {
+			ins = append(ins, param.typ)
+			params = append(params, param)
+		}
+	}
+
+	outs := []reflect.Type(nil)
+	for i := 0; i < n.con.Type().NumOut(); i++ {
+		outs = append(outs, n.con.Type().Out(i))
+	}
+	failable := len(outs) > 0 && outs[len(outs)-1] == errorType
+	decoratorFailable := d.con.Type().NumOut() == 2
+	if decoratorFailable && !failable {
+		outs = append(outs, errorType)
+	}
+
+	con, decorator := n.con, d.con
+	wrapped := *n
+	wrapped.params = params
+	wrapped.inherited = nil
+	wrapped.con = reflect.MakeFunc(reflect.FuncOf(ins, outs, false), func(args []reflect.Value) []reflect.Value {
+		returns := con.Call(args[:len(n.params)])
+		if failable && !returns[len(returns)-1].IsNil() {
+			return returns
+		}
+
+		decoratorArgs := make([]reflect.Value, 0, len(d.params))
+		decoratorArgs = append(decoratorArgs, args[len(n.params):len(n.params)+at]...)
+		decoratorArgs = append(decoratorArgs, returns[target])
+		decoratorArgs = append(decoratorArgs, args[len(n.params)+at:]...)
+		decoratorReturns := decorator.Call(decoratorArgs)
+
+		returns[target] = decoratorReturns[0]
+		switch {
+		case !decoratorFailable:
+		case failable:
+			returns[len(returns)-1] = decoratorReturns[1]
+		default:
+			returns = append(returns, decoratorReturns[1])
+		}
+		return returns
+	})
+	return &wrapped
+}
+
+type ErrAlreadyConstructed struct {
+	Constructor string
+}
+
+func (e ErrAlreadyConstructed) Error() string {
+	return "already constructed: " + e.Constructor
+}
+
+type ErrInvalidDecorator struct {
+	Decorator string
+	Reason    string
+}
+
+func (e ErrInvalidDecorator) Error() string {
+	return "invalid decorator " + e.Decorator + ": " + e.Reason
+}
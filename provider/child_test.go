@@ -0,0 +1,281 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type mailer interface {
+	Send(to string) string
+}
+
+type smtpMailer struct{}
+
+func (smtpMailer) Send(to string) string { return "smtp " + to }
+
+type fakeMailer struct{}
+
+func (fakeMailer) Send(to string) string { return "fake " + to }
+
+type loggedMailer struct {
+	mailer
+	prefix string
+}
+
+func (m loggedMailer) Send(to string) string { return m.prefix + m.mailer.Send(to) }
+
+type signup struct{ mailer mailer }
+
+func newSMTPMailer() smtpMailer  { return smtpMailer{} }
+func newSignup(m mailer) *signup { return &signup{mailer: m} }
+func newFakeMailer() mailer      { return fakeMailer{} }
+
+func TestChild(t *testing.T) {
+	tests := []struct {
+		name      string
+		construct bool
+		change    func(c *Provider) error
+		sent      string
+		shared    bool
+		// calls is how often the signup constructor runs, in the parent and the child
+		calls int
+	}{
+		{"shares what the parent constructed", true, nil, "smtp a", true, 1},
+		{"constructs what the parent did not", false, nil, "smtp a", false, 1},
+		{"replaced", true, func(c *Provider) error { return c.Replace(newFakeMailer) }, "fake a", false, 2},
+		{"decorated", true, func(c *Provider) error {
+			return c.Decorate(func(m smtpMailer) smtpMailer { return m })
+		}, "smtp a", false, 2},
+		{"registered", true, func(c *Provider) error { return c.Register(newGraphA) }, "smtp a", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			parent := New()
+			err := parent.Register(newSMTPMailer, func(m mailer) *signup {
+				calls++
+				return newSignup(m)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.construct {
+				if err := parent.Construct(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			child := parent.Child()
+			if tt.change != nil {
+				if err := tt.change(child); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := child.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			s := MustGet[*signup](child)
+			if got := s.mailer.Send("a"); got != tt.sent {
+				t.Errorf("sent %q, want %q", got, tt.sent)
+			}
+			if calls != tt.calls {
+				t.Errorf("signup constructed %d times, want %d", calls, tt.calls)
+			}
+
+			// Get would construct the parent, so it is only asked when constructed already
+			if !tt.construct {
+				return
+			}
+			p := MustGet[*signup](parent)
+			if (p == s) != tt.shared {
+				t.Errorf("shared = %v, want %v", p == s, tt.shared)
+			}
+			if got := p.mailer.Send("a"); got != "smtp a" {
+				t.Errorf("the parent sent %q, the child changed it", got)
+			}
+			if _, ok := Get[graphA](parent); ok {
+				t.Error("the child registered in the parent")
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name        string
+		construct   bool
+		replacement any
+		sent        string
+		err         any
+	}{
+		{"same type", false, func() smtpMailer { return smtpMailer{} }, "smtp a", nil},
+		{"interface replaces its implementation", false, newFakeMailer, "fake a", nil},
+		{"nothing to replace", false, newGraphA, "", &ErrNotProvided{}},
+		{"nothing provided", false, func() {}, "", &ErrInvalidConstructorReturn{}},
+		{"already constructed", true, newFakeMailer, "", &ErrAlreadyConstructed{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(newSMTPMailer, newSignup); err != nil {
+				t.Fatal(err)
+			}
+			if tt.construct {
+				if err := p.Construct(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := p.Replace(tt.replacement)
+			if tt.err != nil {
+				if !errors.As(err, tt.err) {
+					t.Errorf("Replace = %v, want %T", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := MustGet[*signup](p).mailer.Send("a"); got != tt.sent {
+				t.Errorf("sent %q, want %q", got, tt.sent)
+			}
+		})
+	}
+}
+
+func TestReplaceKeepsGroupOrder(t *testing.T) {
+	p := New()
+	err := p.Register(
+		Annotate(func() namedPlugin { return "auth" }, Group("plugins")),
+		Annotate(func() graphA { return graphA{} }, Named("a")),
+		Annotate(func() graphB { return graphB{} }, Group("plugins")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Replace(Annotate(func() graphA { return graphA{} }, Named("a"))); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Graph().Constructors[1].Provides[0]; got != `provider.graphA name:"a"` {
+		t.Errorf("second constructor provides %s, want the replacement in place", got)
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  []any
+		construct bool
+		decorator any
+		sent      string
+		err       any
+	}{
+		{
+			name:      "wraps",
+			register:  []any{newSMTPMailer},
+			decorator: func(m smtpMailer) smtpMailer { return m },
+			sent:      "smtp a",
+		},
+		{
+			name:      "with dependencies",
+			register:  []any{Annotate(newFakeMailer, WithLifetime(Singleton)), func() string { return "logged " }},
+			decorator: func(prefix string, m mailer) mailer { return loggedMailer{mailer: m, prefix: prefix} },
+			sent:      "logged fake a",
+		},
+		{
+			name:      "with a nil error",
+			register:  []any{newFakeMailer},
+			decorator: func(m mailer) (mailer, error) { return loggedMailer{mailer: m, prefix: "> "}, nil },
+			sent:      "> fake a",
+		},
+		{
+			name:      "failing",
+			register:  []any{newFakeMailer},
+			decorator: func(m mailer) (mailer, error) { return nil, errRefused },
+			err:       &ErrConstructorFailed{},
+		},
+		{
+			name:      "named",
+			register:  []any{Annotate(newFakeMailer, Named("bulk")), Annotate(newFakeMailer, Named("mail"))},
+			decorator: Annotate(func(m mailer) mailer { return loggedMailer{mailer: m, prefix: "bulk "} }, Named("bulk")),
+		},
+		{
+			name:      "not taking what it returns",
+			register:  []any{newFakeMailer},
+			decorator: func() mailer { return fakeMailer{} },
+			err:       &ErrInvalidDecorator{},
+		},
+		{
+			name:      "returning two values",
+			register:  []any{newFakeMailer},
+			decorator: func(m mailer) (mailer, string) { return m, "" },
+			err:       &ErrInvalidDecorator{},
+		},
+		{
+			name:      "provided as",
+			register:  []any{Annotate(newSMTPMailer, As(new(mailer)))},
+			decorator: func(m mailer) mailer { return m },
+			err:       &ErrInvalidDecorator{},
+		},
+		{
+			name:      "not provided",
+			decorator: func(m mailer) mailer { return m },
+			err:       &ErrNotProvided{},
+		},
+		{
+			name:      "already constructed",
+			register:  []any{newFakeMailer},
+			construct: true,
+			decorator: func(m mailer) mailer { return m },
+			err:       &ErrAlreadyConstructed{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(tt.register...); err != nil {
+				t.Fatal(err)
+			}
+			if tt.construct {
+				if err := p.Construct(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := p.Decorate(tt.decorator)
+			if err == nil {
+				err = p.Construct(context.Background())
+			}
+			if tt.err != nil {
+				if !errors.As(err, tt.err) {
+					t.Errorf("err = %v, want %T", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.name == "named" {
+				bulk, _ := GetNamed[mailer](p, "bulk")
+				mail, _ := GetNamed[mailer](p, "mail")
+				if bulk.Send("a") != "bulk fake a" || mail.Send("a") != "fake a" {
+					t.Errorf("sent %q and %q, want only bulk decorated", bulk.Send("a"), mail.Send("a"))
+				}
+				return
+			}
+			m, ok := Get[mailer](p)
+			if !ok {
+				t.Fatal("no mailer")
+			}
+			if got := m.Send("a"); got != tt.sent {
+				t.Errorf("sent %q, want %q", got, tt.sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Lazy leaves a singleton to be constructed when it is first needed, by Get or Resolve,
+// unless a constructor Construct calls depends on it.
+func Lazy() Annotation {
+	return func(a *Annotated) {
+		a.Lazy = true
+	}
+}
+
+// SetConcurrency lets Construct call up to n constructors of one graph level at once.
+// Constructors run one at a time by default, as they may not be safe to run concurrently.
+func (p *Provider) SetConcurrency(n int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.concurrency = n
+}
+
+// Construct calls every singleton constructor not called yet, except lazy ones nothing else
+// needs, each after the constructors it depends on. It fails before calling any when Validate
+// would. When a constructor fails, what was constructed by this call is stopped again.
+func (p *Provider) Construct(ctx context.Context) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.container[key{typ: getContextType()}] = ctx
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	roots := []*node(nil)
+	for _, n := range p.nodes {
+		if n.lifetime == Singleton && !n.lazy {
+			roots = append(roots, n)
+		}
+	}
+	return p.construct(ctx, roots)
+}
+
+// constructLazy constructs the lazy singletons k needs, if any.
+func (p *Provider) constructLazy(k key) error {
+	p.lock.RLock()
+	roots := p.providersOf(k)
+	p.lock.RUnlock()
+	return p.constructFor(roots)
+}
+
+// constructFor constructs the lazy singletons roots need, roots included, if any.
+func (p *Provider) constructFor(roots []*node) error {
+	p.lock.RLock()
+	pending := len(p.levels(roots)) > 0
+	p.lock.RUnlock()
+	if !pending {
+		return nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.construct(p.context(), roots)
+}
+
+func (p *Provider) construct(ctx context.Context, roots []*node) error {
+	rollbackFrom := p.lifecycleLen()
+	constructed := []*node(nil)
+	done := func(n *node) {
+		n.constructed = true
+		p.constructions++
+		n.order = p.constructions
+		constructed = append(constructed, n)
+	}
+
+	shareable := make(map[*node]bool)
+	for level, nodes := range p.levels(roots) {
+		called := []*node(nil)
+		for _, n := range nodes {
+			values, ok := p.inheritedValues(n, shareable)
+			if !ok {
+				called = append(called, n)
+				continue
+			}
+			for k, v := range values {
+				p.container[k] = v
+			}
+			done(n)
+		}
+		results := p.callLevel(ctx, level, called)
+
+		errs := []error(nil)
+		for i, n := range called {
+			err := results[i].err
+			if err == nil {
+				err = p.store(n, results[i].returns)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			done(n)
+		}
+		if errs != nil {
+			return errors.Join(append(errs, p.rollback(ctx, constructed, rollbackFrom))...)
+		}
+	}
+	return nil
+}
+
+type callResult struct {
+	returns []reflect.Value
+	err     error
+}
+
+// callLevel calls the constructors of one level, which do not depend on each other, up to
+// the concurrency limit at once. Values are stored by the caller once all have returned, so
+// the calls only ever read the container.
+func (p *Provider) callLevel(ctx context.Context, level int, nodes []*node) []callResult {
+	results := make([]callResult, len(nodes))
+	call := func(i int) {
+		n := nodes[i]
+		args, err := p.arguments(ctx, n, nil, true)
+		if err != nil {
+			results[i].err = err
+			return
+		}
+		started := time.Now()
+		results[i].returns = call(ctx, p.tracerProvider, n, args)
+		p.recordTiming(n, level, started)
+	}
+
+	workers := p.concurrency
+	if workers <= 1 || len(nodes) <= 1 {
+		for i := range nodes {
+			call(i)
+		}
+		return results
+	}
+
+	sem := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+	panics := make(chan any, len(nodes))
+	for i := range nodes {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panics <- r
+				}
+				<-sem
+				wg.Done()
+			}()
+			call(i)
+		}(i)
+	}
+	wg.Wait()
+
+	select {
+	case r := <-panics:
+		panic(r)
+	default:
+	}
+	return results
+}
+
+// levels groups the singleton constructors roots need that are not constructed yet, roots
+// included, by depth: each level only depends on earlier ones. Levels keep registration order.
+func (p *Provider) levels(roots []*node) [][]*node {
+	needed := make(map[*node]struct{})
+	var need func(n *node)
+	need = func(n *node) {
+		if _, ok := needed[n]; ok || n.constructed {
+			return
+		}
+		if n.lifetime == Singleton {
+			needed[n] = struct{}{}
+		}
+		for _, dep := range p.singletonDeps(n) {
+			if dep.lifetime == Singleton {
+				need(dep)
+			}
+		}
+	}
+	for _, n := range roots {
+		need(n)
+	}
+
+	pending := make(map[*node]int, len(needed))
+	dependents := make(map[*node][]*node)
+	for n := range needed {
+		pending[n] += 0
+		for _, dep := range p.singletonDeps(n) {
+			if _, ok := needed[dep]; !ok {
+				continue
+			}
+			pending[n]++
+			dependents[dep] = append(dependents[dep], n)
+		}
+	}
+
+	ready := []*node(nil)
+	for n, count := range pending {
+		if count == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	levels := [][]*node(nil)
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i].index < ready[j].index })
+		levels = append(levels, ready)
+		next := []*node(nil)
+		for _, n := range ready {
+			for _, dependent := range dependents[n] {
+				pending[dependent]--
+				if pending[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		ready = next
+	}
+	return levels
+}
+
+// rollback undoes a failed Construct: it stops what the constructed nodes returned, in
+// reverse order, and forgets their values so Construct can be retried.
+func (p *Provider) rollback(ctx context.Context, constructed []*node, lifecycleFrom int) error {
+	err := p.stopAfter(ctx, lifecycleFrom)
+	for _, n := range constructed {
+		n.constructed = false
+		n.order = 0
+		for _, t := range n.provides {
+			delete(p.container, t)
+		}
+	}
+	return err
+}
+
+// Timing is how long a singleton constructor took.
+type Timing struct {
+	Constructor string
+	Level       int
+	Lazy        bool
+	Started     time.Time
+	Duration    time.Duration
+}
+
+func (p *Provider) recordTiming(n *node, level int, started time.Time) {
+	p.timingLock.Lock()
+	defer p.timingLock.Unlock()
+	p.timings = append(p.timings, Timing{
+		Constructor: n.String(),
+		Level:       level,
+		Lazy:        n.lazy,
+		Started:     started,
+		Duration:    time.Since(started),
+	})
+}
+
+// Timings returns how long each singleton constructor took, in the order they started.
+func (p *Provider) Timings() []Timing {
+	p.timingLock.Lock()
+	defer p.timingLock.Unlock()
+	timings := append([]Timing(nil), p.timings...)
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Started.Before(timings[j].Started) })
+	return timings
+}
+
+// WriteProfile prints the constructor timings to w, slowest first, after the wall time
+// construction took.
+func (p *Provider) WriteProfile(w io.Writer) error {
+	timings := p.Timings()
+	if len(timings) == 0 {
+		_, err := fmt.Fprintln(w, "no constructors called")
+		return err
+	}
+
+	first, last := timings[0].Started, timings[0].Started
+	sum := time.Duration(0)
+	for _, t := range timings {
+		if end := t.Started.Add(t.Duration); end.After(last) {
+			last = end
+		}
+		sum += t.Duration
+	}
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Duration > timings[j].Duration })
+
+	if _, err := fmt.Fprintf(w, "%d constructors in %s (%s in constructors)\n", len(timings), last.Sub(first), sum); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DURATION\tLEVEL\tCONSTRUCTOR")
+	for _, t := range timings {
+		constructor := t.Constructor
+		if t.Lazy {
+			constructor += " [lazy]"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", t.Duration, t.Level, constructor)
+	}
+	return tw.Flush()
+}
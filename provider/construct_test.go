@@ -0,0 +1,308 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type counter struct{ n int }
+
+var errRefused = errors.New("refused")
+
+func newFailingGraphB(a graphA) (graphB, error) { return graphB{}, errRefused }
+
+func TestConstructorReturns(t *testing.T) {
+	tests := []struct {
+		name        string
+		constructor any
+		registerErr error
+		failed      bool
+		value       int
+	}{
+		{"value", func() counter { return counter{1} }, nil, false, 1},
+		{"value and nil error", func() (counter, error) { return counter{2}, nil }, nil, false, 2},
+		{"pointer", func() (*counter, error) { return &counter{3}, nil }, nil, false, 0},
+		{"value, cleanup and nil error", func() (counter, func(), error) { return counter{4}, func() {}, nil }, nil, false, 4},
+		{"error", func() (counter, error) { return counter{}, errRefused }, nil, true, 0},
+		{"error not last", func() (error, counter) { return nil, counter{} }, ErrInvalidConstructorReturn{}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(tt.constructor); err != tt.registerErr {
+				t.Fatalf("Register = %v, want %v", err, tt.registerErr)
+			}
+			if tt.registerErr != nil {
+				return
+			}
+
+			err := p.Construct(context.Background())
+			failed := ErrConstructorFailed{}
+			if got := errors.As(err, &failed); got != tt.failed {
+				t.Fatalf("Construct = %v, want failed %v", err, tt.failed)
+			}
+			if tt.failed {
+				if !errors.Is(err, errRefused) {
+					t.Errorf("Construct = %v, does not wrap the constructor error", err)
+				}
+				if !strings.Contains(failed.Constructor, "construct_test.go:") {
+					t.Errorf("constructor = %q, want its location", failed.Constructor)
+				}
+				if _, ok := Get[counter](p); ok {
+					t.Error("the failed constructor provided a value")
+				}
+				return
+			}
+			if tt.value != 0 {
+				if got, _ := Get[counter](p); got.n != tt.value {
+					t.Errorf("value = %d, want %d", got.n, tt.value)
+				}
+			}
+			if _, ok := Get[error](p); ok {
+				t.Error("the error return was stored as a value")
+			}
+		})
+	}
+}
+
+func TestConstructRollback(t *testing.T) {
+	j := &journal{}
+	attempts := 0
+	p := New()
+	err := p.Register(
+		func() (graphA, func()) {
+			j.add("construct a")
+			return graphA{}, func() { j.add("cleanup a") }
+		},
+		func(a graphA) (graphB, error) {
+			attempts++
+			if attempts == 1 {
+				return graphB{}, errRefused
+			}
+			return graphB{}, nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Construct(context.Background()); !errors.Is(err, errRefused) {
+		t.Fatalf("Construct = %v, want %v", err, errRefused)
+	}
+	if got := j.String(); got != "construct a, cleanup a" {
+		t.Errorf("journal = %s, want the partial construction cleaned up", got)
+	}
+	if order := p.Graph().Order(); len(order) != 0 {
+		t.Errorf("%q still constructed after the rollback", order)
+	}
+
+	// the rolled back constructors are called again by the next Construct
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := j.String(); got != "construct a, cleanup a, construct a" {
+		t.Errorf("journal = %s", got)
+	}
+	if _, ok := Get[graphB](p); !ok {
+		t.Error("b is not provided")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		updater any
+		failed  bool
+		value   int
+	}{
+		{"replace", func(c counter) counter { return counter{c.n + 1} }, false, 2},
+		{"replace with nil error", func(c counter) (counter, error) { return counter{c.n + 2}, nil }, false, 3},
+		{"check only", func(c counter) error { return nil }, false, 1},
+		{"error", func(c counter) error { return errRefused }, true, 1},
+		{"error keeps the value", func(c counter) (counter, error) { return counter{10}, errRefused }, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(func() counter { return counter{1} }); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Update(p, tt.updater)
+			failed := ErrConstructorFailed{}
+			if got := errors.As(err, &failed); got != tt.failed {
+				t.Fatalf("Update = %v, want failed %v", err, tt.failed)
+			}
+			if tt.failed && !errors.Is(err, errRefused) {
+				t.Errorf("Update = %v, does not wrap the updater error", err)
+			}
+			if got, _ := Get[counter](p); got.n != tt.value {
+				t.Errorf("value = %d, want %d", got.n, tt.value)
+			}
+		})
+	}
+}
+
+func TestConstructFailureNamesConstructor(t *testing.T) {
+	p := New()
+	if err := p.Register(newGraphA, newFailingGraphB); err != nil {
+		t.Fatal(err)
+	}
+	err := p.Construct(context.Background())
+	if err == nil || !strings.HasPrefix(err.Error(), "construct provider.newFailingGraphB (") {
+		t.Errorf("Construct = %v, want it to name the constructor", err)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	tests := []struct {
+		name      string
+		needed    bool
+		fail      bool
+		construct int
+		get       int
+	}{
+		{"unused", false, false, 0, 1},
+		{"needed", true, false, 1, 1},
+		{"failing", false, true, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			p := New()
+			err := p.Register(Annotate(func() (graphA, error) {
+				calls++
+				if tt.fail {
+					return graphA{}, errRefused
+				}
+				return graphA{}, nil
+			}, Lazy()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.needed {
+				if err := p.Register(newGraphB); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if calls != tt.construct {
+				t.Errorf("Construct called it %d times, want %d", calls, tt.construct)
+			}
+
+			_, err = Lookup[graphA](p)
+			if tt.fail != errors.Is(err, errRefused) {
+				t.Errorf("Lookup = %v, want failed %v", err, tt.fail)
+			}
+			if !tt.fail {
+				Get[graphA](p)
+			}
+			if calls != tt.get {
+				t.Errorf("called %d times after Get, want %d", calls, tt.get)
+			}
+		})
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		concurrency int
+		maxRunning  int32
+	}{
+		{0, 1},
+		{1, 1},
+		{2, 2},
+		{8, 3},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.concurrency), func(t *testing.T) {
+			running, maxRunning := int32(0), int32(0)
+			level := func() {
+				now := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if now <= max || atomic.CompareAndSwapInt32(&maxRunning, max, now) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}
+
+			p := New()
+			p.SetConcurrency(tt.concurrency)
+			err := p.Register(
+				func() graphA { level(); return graphA{} },
+				func() graphB { level(); return graphB{} },
+				func() graphC { level(); return graphC{} },
+				func(a graphA, b graphB, c graphC) counter { return counter{} },
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if maxRunning != tt.maxRunning {
+				t.Errorf("%d constructors ran at once, want %d", maxRunning, tt.maxRunning)
+			}
+
+			levels := []int(nil)
+			for _, timing := range p.Timings() {
+				levels = append(levels, timing.Level)
+			}
+			sort.Ints(levels)
+			if fmt.Sprint(levels) != "[0 0 0 1]" {
+				t.Errorf("levels = %v, want three at 0 and one at 1", levels)
+			}
+		})
+	}
+}
+
+func TestWriteProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		construct bool
+		want      []string
+	}{
+		{"nothing called", false, []string{"no constructors called\n"}},
+		{"called", true, []string{"2 constructors in ", "DURATION", "provider.newGraphA", "[lazy]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(newGraphA, Annotate(newGraphB, Lazy())); err != nil {
+				t.Fatal(err)
+			}
+			if tt.construct {
+				if err := p.Construct(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				Get[graphB](p)
+			}
+
+			sb := strings.Builder{}
+			if err := p.WriteProfile(&sb); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(sb.String(), want) {
+					t.Errorf("profile does not contain %q:\n%s", want, sb.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// node is a registered constructor and the values it needs and provides.
+type node struct {
+	con         reflect.Value
+	name        string
+	fullName    string
+	location    string
+	params      []param
+	provides    []key
+	named       string
+	lifetime    Lifetime
+	lazy        bool
+	group       string
+	as          map[reflect.Type]reflect.Type
+	alias       bool
+	index       int
+	constructed bool
+	order       int
+	// inherited is the constructor of the parent provider n was copied from by Child.
+	inherited *node
+}
+
+func newNode(constructFunction any, index int) (*node, error) {
+	annotated, ok := constructFunction.(Annotated)
+	if !ok {
+		annotated = Annotated{Constructor: constructFunction}
+	}
+
+	args, rets, err := analyzeFunction(annotated.Constructor)
+	if err != nil {
+		return nil, err
+	}
+
+	con := reflect.ValueOf(annotated.Constructor)
+	n := &node{
+		con:      con,
+		name:     shortName(constructorName(con)),
+		fullName: constructorName(con),
+		named:    annotated.Name,
+		lifetime: annotated.Lifetime,
+		lazy:     annotated.Lazy,
+		group:    annotated.Group,
+		index:    index,
+	}
+	if fn := runtime.FuncForPC(con.Pointer()); fn != nil {
+		file, line := fn.FileLine(con.Pointer())
+		n.location = fmt.Sprintf("%s:%d", file, line)
+	}
+	for _, arg := range args {
+		param, err := newParam(arg)
+		if err != nil {
+			return nil, err
+		}
+		n.params = append(n.params, param)
+	}
+	for i, ret := range rets {
+		if ret == errorType && i != len(rets)-1 {
+			return nil, ErrInvalidConstructorReturn{}
+		}
+		if ret == errorType || isCleanup(ret) {
+			continue
+		}
+		n.provides = append(n.provides, n.key(ret))
+	}
+
+	for _, iface := range annotated.As {
+		implementations := []reflect.Type(nil)
+		for _, k := range n.provides {
+			if iface.Kind() == reflect.Interface && k.typ.Kind() != reflect.Interface && k.typ.Implements(iface) {
+				implementations = append(implementations, k.typ)
+			}
+		}
+		switch len(implementations) {
+		case 0:
+			return nil, ErrNotImplemented{Interface: iface, Constructor: n.String()}
+		case 1:
+		default:
+			return nil, ErrAmbiguousDependency{Type: iface, Name: n.named, Constructor: n.String(), Candidates: typeStrings(implementations)}
+		}
+		if n.as == nil {
+			n.as = make(map[reflect.Type]reflect.Type)
+		}
+		n.as[iface] = implementations[0]
+		n.provides = append(n.provides, n.key(iface))
+	}
+	return n, nil
+}
+
+// key is the key n provides a value of type t under.
+func (n *node) key(t reflect.Type) key {
+	if n.group != "" {
+		return key{typ: t, group: n.group, member: n.index + 1}
+	}
+	return key{typ: t, name: n.named}
+}
+
+// members lists the keys of the group values n provides that are assignable to t. A value
+// provided As an interface is listed once, under its own type.
+func (n *node) members(t reflect.Type) []key {
+	members := []key(nil)
+	for _, k := range n.provides {
+		if _, ok := n.as[k.typ]; ok {
+			continue
+		}
+		if k.group != "" && k.typ.AssignableTo(t) {
+			members = append(members, k)
+		}
+	}
+	return members
+}
+
+// deps lists every value n needs.
+func (n *node) deps() []key {
+	deps := []key(nil)
+	for _, param := range n.params {
+		deps = append(deps, param.keys...)
+	}
+	return deps
+}
+
+// required lists the values n cannot do without: all but optional ones and groups, which
+// may be empty.
+func (n *node) required() []key {
+	required := []key(nil)
+	for _, param := range n.params {
+		for i, k := range param.keys {
+			if !param.optional[i] && k.group == "" {
+				required = append(required, k)
+			}
+		}
+	}
+	return required
+}
+
+func typeStrings(types []reflect.Type) []string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = t.String()
+	}
+	return s
+}
+
+// shortName drops the import path from a function name, "github.com/a/b.NewC" becomes "b.NewC".
+func shortName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func (n *node) String() string {
+	if n.location == "" {
+		return n.name
+	}
+	return n.name + " (" + n.location + ")"
+}
+
+type ErrDuplicateProvider struct {
+	Type   reflect.Type
+	Name   string
+	First  string
+	Second string
+}
+
+func (e ErrDuplicateProvider) Error() string {
+	return fmt.Sprintf("duplicate provider of %s: %s and %s", key{typ: e.Type, name: e.Name}, e.First, e.Second)
+}
+
+type ErrMissingDependency struct {
+	Type        reflect.Type
+	Name        string
+	Constructor string
+}
+
+func (e ErrMissingDependency) Error() string {
+	return fmt.Sprintf("missing dependency: %s needs %s, which nothing provides", e.Constructor, key{typ: e.Type, name: e.Name})
+}
+
+// ErrCyclicDependency lists the constructors of a cycle, the first repeated at the end,
+// and the types each one needs from the next.
+type ErrCyclicDependency struct {
+	Path  []string
+	Types []reflect.Type
+	Names []string
+}
+
+func (e ErrCyclicDependency) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString("cyclic dependency: ")
+	sb.WriteString(strings.Join(e.Path, " -> "))
+	sb.WriteString(" (")
+	for i, t := range e.Types {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		name := ""
+		if i < len(e.Names) {
+			name = e.Names[i]
+		}
+		sb.WriteString(fmt.Sprintf("%s needs %s", e.Path[i], key{typ: t, name: name}))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// Validate checks the registered constructors without calling them: every argument must be
+// provided, by a constructor, a value already constructed or the context given to Construct,
+// and the constructors must not depend on each other in a cycle.
+func (p *Provider) Validate() error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.validate()
+}
+
+func (p *Provider) validate() error {
+	errs := []error(nil)
+	for _, n := range p.nodes {
+		for _, dep := range n.required() {
+			resolved, candidates := p.resolve(dep)
+			switch {
+			case candidates != nil:
+				errs = append(errs, ErrAmbiguousDependency{Type: dep.typ, Name: dep.name, Constructor: n.String(), Candidates: keyStrings(candidates)})
+			case resolved.typ == nil:
+				errs = append(errs, ErrMissingDependency{Type: dep.typ, Name: dep.name, Constructor: n.String()})
+			}
+		}
+	}
+	for _, cycle := range p.cycles() {
+		errs = append(errs, cycle)
+	}
+	errs = append(errs, p.scopeViolations()...)
+	return errors.Join(errs...)
+}
+
+func (p *Provider) available(k key) bool {
+	if _, ok := p.providers[k]; ok {
+		return true
+	}
+	if _, ok := p.container[k]; ok {
+		return true
+	}
+	return k == key{typ: getContextType()}
+}
+
+// cycles finds every cycle among constructors not constructed yet, each reported once.
+func (p *Provider) cycles() []ErrCyclicDependency {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[*node]int, len(p.nodes))
+	stack := []*node(nil)
+	via := []key(nil)
+	found := []ErrCyclicDependency(nil)
+
+	var visit func(n *node)
+	visit = func(n *node) {
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, arg := range n.deps() {
+			for _, dep := range p.providersOf(arg) {
+				if dep.constructed {
+					continue
+				}
+				via = append(via, arg)
+				switch state[dep] {
+				case unvisited:
+					visit(dep)
+				case visiting:
+					start := len(stack) - 1
+					for stack[start] != dep {
+						start--
+					}
+					cycle := ErrCyclicDependency{}
+					for _, member := range stack[start:] {
+						cycle.Path = append(cycle.Path, member.name)
+					}
+					cycle.Path = append(cycle.Path, dep.name)
+					for _, k := range via[start:] {
+						cycle.Types = append(cycle.Types, k.typ)
+						cycle.Names = append(cycle.Names, k.name)
+					}
+					found = append(found, cycle)
+				}
+				via = via[:len(via)-1]
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = visited
+	}
+
+	for _, n := range p.nodes {
+		if !n.constructed && state[n] == unvisited {
+			visit(n)
+		}
+	}
+	return found
+}
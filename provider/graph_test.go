@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type (
+	graphA struct{}
+	graphB struct{}
+	graphC struct{}
+)
+
+func newGraphA() graphA                               { return graphA{} }
+func newGraphAFrom(c graphC) graphA                   { return graphA{} }
+func newGraphAWithContext(ctx context.Context) graphA { return graphA{} }
+func newGraphB(a graphA) graphB                       { return graphB{} }
+func newGraphC(b graphB) graphC                       { return graphC{} }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		constructors []any
+		missing      []reflect.Type
+		cycle        string
+	}{
+		{
+			name:         "chain",
+			constructors: []any{newGraphB, newGraphA},
+		},
+		{
+			name:         "context is provided",
+			constructors: []any{newGraphAWithContext, newGraphB},
+		},
+		{
+			name:         "missing",
+			constructors: []any{newGraphB, newGraphC},
+			missing:      []reflect.Type{reflect.TypeOf(graphA{})},
+		},
+		{
+			name:         "cycle",
+			constructors: []any{newGraphAFrom, newGraphB, newGraphC},
+			cycle:        "provider.newGraphAFrom -> provider.newGraphC -> provider.newGraphB -> provider.newGraphAFrom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(tt.constructors...); err != nil {
+				t.Fatal(err)
+			}
+			err := p.Validate()
+			if tt.missing == nil && tt.cycle == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate = nil, want an error")
+			}
+
+			for _, want := range tt.missing {
+				missing := ErrMissingDependency{}
+				if !errors.As(err, &missing) || missing.Type != want {
+					t.Errorf("Validate = %v, want %s missing", err, want)
+				}
+			}
+			if tt.cycle != "" {
+				cycle := ErrCyclicDependency{}
+				if !errors.As(err, &cycle) {
+					t.Fatalf("Validate = %v, want a cycle", err)
+				}
+				if got := strings.Join(cycle.Path, " -> "); got != tt.cycle {
+					t.Errorf("cycle = %s, want %s", got, tt.cycle)
+				}
+				if len(cycle.Types) != len(cycle.Path)-1 {
+					t.Errorf("cycle has %d types for a path of %d", len(cycle.Types), len(cycle.Path))
+				}
+			}
+
+			// Construct fails the same way, before calling any constructor
+			if err := p.Construct(context.Background()); err == nil {
+				t.Error("Construct = nil, want an error")
+			}
+			if order := p.Graph().Order(); len(order) != 0 {
+				t.Errorf("constructed %q", order)
+			}
+		})
+	}
+}
+
+func TestValidateCallsNothing(t *testing.T) {
+	calls := 0
+	p := New()
+	if err := p.Register(func() graphA { calls++; return graphA{} }, newGraphB); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Errorf("Validate called %d constructors", calls)
+	}
+}
+
+func TestDuplicateProvider(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  any
+		second any
+		err    bool
+	}{
+		{"same type", newGraphA, newGraphAWithContext, true},
+		{"named apart", newGraphA, Annotate(newGraphAWithContext, Named("other")), false},
+		{"same name", Annotate(newGraphA, Named("x")), Annotate(newGraphAWithContext, Named("x")), true},
+		{"grouped", Annotate(newGraphA, Group("all")), Annotate(newGraphAWithContext, Group("all")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(tt.first); err != nil {
+				t.Fatal(err)
+			}
+			err := p.Register(tt.second)
+			duplicate := ErrDuplicateProvider{}
+			if got := errors.As(err, &duplicate); got != tt.err {
+				t.Fatalf("Register = %v, want duplicate %v", err, tt.err)
+			}
+			if tt.err && duplicate.Type != reflect.TypeOf(graphA{}) {
+				t.Errorf("duplicate type = %s", duplicate.Type)
+			}
+		})
+	}
+}
+
+func TestGraphOrder(t *testing.T) {
+	p := New()
+	if err := p.Register(newGraphC, newGraphB, newGraphA); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "provider.newGraphA, provider.newGraphB, provider.newGraphC"
+	if got := strings.Join(p.Graph().Order(), ", "); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestGraphMissing(t *testing.T) {
+	p := New()
+	if err := p.Register(newGraphC, newGraphB); err != nil {
+		t.Fatal(err)
+	}
+	want := "provider.newGraphB needs provider.graphA"
+	if got := strings.Join(p.Graph().Missing(), ", "); got != want {
+		t.Errorf("missing = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,290 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Graph describes registered constructors and how they depend on each other.
+type Graph struct {
+	Constructors []Constructor `json:"constructors"`
+}
+
+type Constructor struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location,omitempty"`
+	Lifetime string `json:"lifetime"`
+	Lazy     bool   `json:"lazy,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Updater  bool   `json:"updater,omitempty"`
+	// Invoked is set on functions run with Invoke, Run, JustRun or Populate, which only need values.
+	Invoked  bool         `json:"invoked,omitempty"`
+	Provides []string     `json:"provides"`
+	Needs    []Dependency `json:"needs"`
+	// Order is the position of the constructor in construction order, 0 when it was not called.
+	Order int `json:"order"`
+	// Unused is set when no other constructor, updater or invoked function needs what it provides.
+	Unused bool `json:"unused"`
+}
+
+type Dependency struct {
+	Type     string `json:"type"`
+	Optional bool   `json:"optional,omitempty"`
+	// Providers lists the IDs of the constructors the dependency is resolved from.
+	Providers []string `json:"providers"`
+	// External is set when the value does not come from a constructor, e.g. the context.
+	External bool `json:"external,omitempty"`
+	// Missing is set when nothing provides a required value.
+	Missing bool `json:"missing,omitempty"`
+	// Duplicate is set when several constructors provide a value only one may, all listed in
+	// Providers.
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// Graph describes what p has registered and constructed so far, along with the functions
+// run by Update and Invoke, once each.
+func (p *Provider) Graph() Graph {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	ids := make(map[*node]string, len(p.nodes))
+	for i, n := range p.nodes {
+		ids[n] = "n" + strconv.Itoa(i)
+	}
+	describe := func(id string, n *node) Constructor {
+		c := Constructor{
+			ID:       id,
+			Name:     n.name,
+			Location: n.location,
+			Lifetime: n.lifetime.String(),
+			Lazy:     n.lazy,
+			Group:    n.group,
+			Provides: keyStrings(n.provides),
+			Needs:    []Dependency{},
+			Order:    n.order,
+		}
+		for _, param := range n.params {
+			for i, k := range param.keys {
+				dep := Dependency{Type: k.String(), Optional: param.optional[i], Providers: []string{}}
+				for _, provider := range p.providersOf(k) {
+					dep.Providers = append(dep.Providers, ids[provider])
+				}
+				if len(dep.Providers) == 0 && k.group == "" {
+					resolved, candidates := p.resolve(k)
+					dep.External = resolved.typ != nil
+					dep.Missing = !dep.External && candidates == nil && !dep.Optional
+				}
+				c.Needs = append(c.Needs, dep)
+			}
+		}
+		return c
+	}
+
+	g := Graph{Constructors: make([]Constructor, 0, len(p.nodes))}
+	for _, n := range p.nodes {
+		g.Constructors = append(g.Constructors, describe(ids[n], n))
+	}
+
+	p.consumerLock.Lock()
+	consumers := append([]consumer(nil), p.consumers...)
+	p.consumerLock.Unlock()
+	for i, consumer := range consumers {
+		c := describe("u"+strconv.Itoa(i), consumer.n)
+		c.Lifetime = ""
+		c.Updater = consumer.updater
+		c.Invoked = !consumer.updater
+		if c.Invoked {
+			c.Provides = []string{}
+		}
+		g.Constructors = append(g.Constructors, c)
+	}
+	return g.marked()
+}
+
+// consumer is a function run by Update, or by Invoke when not an updater.
+type consumer struct {
+	n       *node
+	updater bool
+}
+
+// consumed records n for Graph, once per function.
+func (p *Provider) consumed(n *node, updater bool) {
+	p.consumerLock.Lock()
+	defer p.consumerLock.Unlock()
+	for _, c := range p.consumers {
+		if c.updater == updater && c.n.fullName == n.fullName && c.n.location == n.location {
+			return
+		}
+	}
+	p.consumers = append(p.consumers, consumer{n: n, updater: updater})
+}
+
+// marked copies g with Unused set on every constructor.
+func (g Graph) marked() Graph {
+	g.Constructors = append([]Constructor(nil), g.Constructors...)
+	used := make(map[string]struct{})
+	for _, c := range g.Constructors {
+		for _, dep := range c.Needs {
+			for _, id := range dep.Providers {
+				used[id] = struct{}{}
+			}
+		}
+	}
+	for i := range g.Constructors {
+		_, ok := used[g.Constructors[i].ID]
+		g.Constructors[i].Unused = !ok && !g.Constructors[i].Updater && !g.Constructors[i].Invoked
+	}
+	return g
+}
+
+func (g Graph) MarshalJSON() ([]byte, error) {
+	type graph Graph
+	return json.Marshal(graph(g.marked()))
+}
+
+// Order returns the names of the constructors called so far, in the order they were called.
+func (g Graph) Order() []string {
+	names := make([]string, len(g.Constructors))
+	count := 0
+	for _, c := range g.Constructors {
+		if c.Order > 0 && c.Order <= len(names) {
+			names[c.Order-1] = c.Name
+			count++
+		}
+	}
+	order := make([]string, 0, count)
+	for _, name := range names {
+		if name != "" {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// Missing lists the required dependencies nothing provides, as "constructor needs type".
+func (g Graph) Missing() []string {
+	missing := []string(nil)
+	for _, c := range g.Constructors {
+		for _, dep := range c.Needs {
+			if dep.Missing {
+				missing = append(missing, c.Name+" needs "+dep.Type)
+			}
+		}
+	}
+	return missing
+}
+
+func (g Graph) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+// WriteDOT writes g in the Graphviz DOT language, with an edge from every constructor to each
+// constructor it needs. Unused constructors are dashed, missing dependencies red and edges to
+// duplicate providers orange.
+func (g Graph) WriteDOT(w io.Writer, name string) error {
+	g = g.marked()
+	sb := strings.Builder{}
+	sb.WriteString("digraph " + strconv.Quote(name) + " {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=box];\n")
+	for _, c := range g.Constructors {
+		attrs := ""
+		switch {
+		case c.Unused:
+			attrs = " style=dashed color=gray50"
+		case c.Updater, c.Invoked:
+			attrs = " style=rounded"
+		}
+		sb.WriteString(fmt.Sprintf("\t%q [label=%q%s];\n", c.ID, c.label("\n"), attrs))
+	}
+
+	missing := 0
+	for _, c := range g.Constructors {
+		for _, dep := range c.Needs {
+			for _, id := range dep.Providers {
+				attrs := ""
+				if dep.Duplicate {
+					attrs = " color=orange fontcolor=orange"
+				}
+				sb.WriteString(fmt.Sprintf("\t%q -> %q [label=%q%s];\n", c.ID, id, dep.Type, attrs))
+			}
+			if dep.Missing {
+				id := "missing" + strconv.Itoa(missing)
+				missing++
+				sb.WriteString(fmt.Sprintf("\t%q [label=%q shape=ellipse color=red fontcolor=red];\n", id, dep.Type))
+				sb.WriteString(fmt.Sprintf("\t%q -> %q [color=red];\n", c.ID, id))
+			}
+		}
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteMermaid writes g as a Mermaid flowchart, highlighting like WriteDOT. Edges to duplicate
+// providers are dotted.
+func (g Graph) WriteMermaid(w io.Writer) error {
+	g = g.marked()
+	sb := strings.Builder{}
+	sb.WriteString("graph LR\n")
+	unused := []string(nil)
+	for _, c := range g.Constructors {
+		sb.WriteString(fmt.Sprintf("\t%s[\"%s\"]\n", c.ID, mermaidEscape(c.label("<br/>"))))
+		if c.Unused {
+			unused = append(unused, c.ID)
+		}
+	}
+
+	missing := []string(nil)
+	for _, c := range g.Constructors {
+		for _, dep := range c.Needs {
+			for _, id := range dep.Providers {
+				arrow := "-->"
+				if dep.Duplicate {
+					arrow = "-.->"
+				}
+				sb.WriteString(fmt.Sprintf("\t%s %s|\"%s\"| %s\n", c.ID, arrow, mermaidEscape(dep.Type), id))
+			}
+			if dep.Missing {
+				id := "missing" + strconv.Itoa(len(missing))
+				missing = append(missing, id)
+				sb.WriteString(fmt.Sprintf("\t%s([\"%s\"])\n", id, mermaidEscape(dep.Type)))
+				sb.WriteString(fmt.Sprintf("\t%s --> %s\n", c.ID, id))
+			}
+		}
+	}
+
+	sb.WriteString("\tclassDef unused stroke-dasharray:5 5,color:#888\n")
+	sb.WriteString("\tclassDef missing fill:#fdd,stroke:#d00,color:#d00\n")
+	if unused != nil {
+		sb.WriteString("\tclass " + strings.Join(unused, ",") + " unused\n")
+	}
+	if missing != nil {
+		sb.WriteString("\tclass " + strings.Join(missing, ",") + " missing\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (c Constructor) label(newline string) string {
+	lines := append([]string{c.Name}, c.Provides...)
+	if c.Lifetime != "" && c.Lifetime != Singleton.String() {
+		lines[0] += " (" + c.Lifetime + ")"
+	}
+	if c.Lazy {
+		lines[0] += " (lazy)"
+	}
+	return strings.Join(lines, newline)
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, "\"", "#quot;")
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testGraph has a duplicate provider, a missing dependency, an external one, an updater and
+// an unused constructor.
+func testGraph() Graph {
+	return Graph{Constructors: []Constructor{
+		{ID: "n0", Name: "comp.NewConfig", Lifetime: "singleton", Provides: []string{"*comp.Config"}, Needs: []Dependency{}},
+		{ID: "n1", Name: "comp.NewOtherConfig", Lifetime: "singleton", Provides: []string{"*comp.Config"}, Needs: []Dependency{}},
+		{ID: "n2", Name: "comp.NewDB", Lifetime: "transient", Lazy: true, Provides: []string{"*comp.DB"}, Needs: []Dependency{
+			{Type: "*comp.Config", Providers: []string{"n0", "n1"}, Duplicate: true},
+			{Type: "context.Context", Providers: []string{}, External: true},
+			{Type: "comp.Missing", Providers: []string{}, Missing: true},
+		}},
+		{ID: "n3", Name: "comp.NewReplica", Lifetime: "singleton", Provides: []string{`*comp.DB name:"replica"`}, Needs: []Dependency{}},
+		{ID: "u0", Name: "comp.Migrate", Updater: true, Provides: []string{"*comp.DB"}, Needs: []Dependency{
+			{Type: "*comp.DB", Providers: []string{"n2"}},
+		}},
+	}}
+}
+
+func TestWriteGraph(t *testing.T) {
+	tests := []struct {
+		format string
+		write  func(g Graph, sb *strings.Builder) error
+		want   string
+	}{
+		{
+			format: "dot",
+			write:  func(g Graph, sb *strings.Builder) error { return g.WriteDOT(sb, "app") },
+			want: `digraph "app" {
+	rankdir=LR;
+	node [shape=box];
+	"n0" [label="comp.NewConfig\n*comp.Config"];
+	"n1" [label="comp.NewOtherConfig\n*comp.Config"];
+	"n2" [label="comp.NewDB (transient) (lazy)\n*comp.DB"];
+	"n3" [label="comp.NewReplica\n*comp.DB name:\"replica\"" style=dashed color=gray50];
+	"u0" [label="comp.Migrate\n*comp.DB" style=rounded];
+	"n2" -> "n0" [label="*comp.Config" color=orange fontcolor=orange];
+	"n2" -> "n1" [label="*comp.Config" color=orange fontcolor=orange];
+	"missing0" [label="comp.Missing" shape=ellipse color=red fontcolor=red];
+	"n2" -> "missing0" [color=red];
+	"u0" -> "n2" [label="*comp.DB"];
+}
+`,
+		},
+		{
+			format: "mermaid",
+			write:  func(g Graph, sb *strings.Builder) error { return g.WriteMermaid(sb) },
+			want: `graph LR
+	n0["comp.NewConfig<br/>*comp.Config"]
+	n1["comp.NewOtherConfig<br/>*comp.Config"]
+	n2["comp.NewDB (transient) (lazy)<br/>*comp.DB"]
+	n3["comp.NewReplica<br/>*comp.DB name:#quot;replica#quot;"]
+	u0["comp.Migrate<br/>*comp.DB"]
+	n2 -.->|"*comp.Config"| n0
+	n2 -.->|"*comp.Config"| n1
+	missing0(["comp.Missing"])
+	n2 --> missing0
+	u0 -->|"*comp.DB"| n2
+	classDef unused stroke-dasharray:5 5,color:#888
+	classDef missing fill:#fdd,stroke:#d00,color:#d00
+	class n3 unused
+	class missing0 missing
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			sb := strings.Builder{}
+			if err := tt.write(testGraph(), &sb); err != nil {
+				t.Fatal(err)
+			}
+			if sb.String() != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", sb.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphJSON(t *testing.T) {
+	data, err := json.Marshal(testGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := Graph{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	unused := []string(nil)
+	for _, c := range decoded.Constructors {
+		if c.Unused {
+			unused = append(unused, c.ID)
+		}
+	}
+	// n1 is a duplicate, but still needed
+	if strings.Join(unused, ",") != "n3" {
+		t.Errorf("unused = %v, want n3 only", unused)
+	}
+	if missing := decoded.Missing(); strings.Join(missing, ",") != "comp.NewDB needs comp.Missing" {
+		t.Errorf("missing = %v", missing)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	p := New()
+	err := p.Register(
+		newGraphA,
+		Annotate(newGraphB, Lazy()),
+		Annotate(func(ctx context.Context) graphC { return graphC{} }, WithLifetime(Transient)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Invoke(p, func(a graphA) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id       string
+		name     string
+		lifetime string
+		needs    string
+		order    int
+		unused   bool
+		invoked  bool
+	}{
+		{"n0", "provider.newGraphA", "singleton", "", 1, false, false},
+		{"n1", "provider.newGraphB", "singleton", "provider.graphA:n0", 0, true, false},
+		{"n2", "provider.TestGraph.func1", "transient", "context.Context:external", 0, true, false},
+		{"u0", "provider.TestGraph.func2", "", "provider.graphA:n0", 0, false, true},
+	}
+	g := p.Graph()
+	if len(g.Constructors) != len(tests) {
+		t.Fatalf("%d constructors, want %d", len(g.Constructors), len(tests))
+	}
+	for i, tt := range tests {
+		c := g.Constructors[i]
+		needs := []string(nil)
+		for _, dep := range c.Needs {
+			from := strings.Join(dep.Providers, ",")
+			if dep.External {
+				from = "external"
+			}
+			needs = append(needs, dep.Type+":"+from)
+		}
+		got := []any{c.ID, c.Name, c.Lifetime, strings.Join(needs, " "), c.Order, c.Unused, c.Invoked}
+		want := []any{tt.id, tt.name, tt.lifetime, tt.needs, tt.order, tt.unused, tt.invoked}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Errorf("constructor %d = %v, want %v", i, got, want)
+				break
+			}
+		}
+	}
+}
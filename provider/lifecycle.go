@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	DefaultStartTimeout = 15 * time.Second
+	DefaultStopTimeout  = 15 * time.Second
+)
+
+// Starter is implemented by components that need to do work once everything is constructed,
+// e.g. opening connections or starting background loops.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by components that hold resources, e.g. database pools.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+var (
+	cleanupType      = reflect.TypeOf((func())(nil))
+	cleanupErrorType = reflect.TypeOf((func() error)(nil))
+)
+
+// lifecycleEntry is a constructed component, or a cleanup func a constructor returned.
+type lifecycleEntry struct {
+	name      string
+	component any
+	cleanup   func() error
+	started   bool
+	stopped   bool
+}
+
+func (p *Provider) SetStartTimeout(d time.Duration) {
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+	p.startTimeout = d
+}
+
+func (p *Provider) SetStopTimeout(d time.Duration) {
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+	p.stopTimeout = d
+}
+
+// isCleanup reports whether a constructor return is a cleanup func rather than a component.
+func isCleanup(t reflect.Type) bool {
+	return t == cleanupType || t == cleanupErrorType
+}
+
+// trackLifecycle records the cleanups of constructor before its components, so that stopping
+// in reverse order stops the components before their cleanup runs. A component tracked
+// already, e.g. returned unchanged by an updater, is not tracked again.
+func (p *Provider) trackLifecycle(constructor string, returns []reflect.Value) {
+	entries := lifecycleEntries(constructor, returns)
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+	for _, entry := range entries {
+		if entry.component == nil || !p.tracked(entry.component) {
+			p.lifecycle = append(p.lifecycle, entry)
+		}
+	}
+}
+
+func (p *Provider) tracked(component any) bool {
+	if !reflect.TypeOf(component).Comparable() {
+		return false
+	}
+	for _, entry := range p.lifecycle {
+		if entry.component == component && !entry.stopped {
+			return true
+		}
+	}
+	return false
+}
+
+func lifecycleEntries(constructor string, returns []reflect.Value) []lifecycleEntry {
+	entries := []lifecycleEntry(nil)
+	for _, ret := range returns {
+		if isCleanup(ret.Type()) {
+			entries = appendLifecycle(entries, constructor, ret)
+		}
+	}
+	for _, ret := range returns {
+		if !isCleanup(ret.Type()) {
+			entries = appendLifecycle(entries, constructor, ret)
+		}
+	}
+	return entries
+}
+
+func appendLifecycle(entries []lifecycleEntry, constructor string, ret reflect.Value) []lifecycleEntry {
+	entry := lifecycleEntry{name: constructor}
+	switch ret.Type() {
+	case cleanupType:
+		if ret.IsNil() {
+			return entries
+		}
+		cleanup := ret.Interface().(func())
+		entry.cleanup = func() error {
+			cleanup()
+			return nil
+		}
+	case cleanupErrorType:
+		if ret.IsNil() {
+			return entries
+		}
+		entry.cleanup = ret.Interface().(func() error)
+	default:
+		component := ret.Interface()
+		_, starter := component.(Starter)
+		_, stopper := component.(Stopper)
+		if !starter && !stopper {
+			return entries
+		}
+		entry.component = component
+	}
+	return append(entries, entry)
+}
+
+// Start starts every Starter in the order it was constructed, so dependencies start first.
+// When one fails, the components started before it are stopped again.
+func (p *Provider) Start(ctx context.Context) error {
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+
+	timeout := p.startTimeout
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout
+	}
+
+	for i := range p.lifecycle {
+		entry := &p.lifecycle[i]
+		starter, ok := entry.component.(Starter)
+		if !ok || entry.started || entry.stopped {
+			continue
+		}
+
+		if err := runWithTimeout(ctx, timeout, starter.Start); err != nil {
+			err = fmt.Errorf("start %s: %w", entry.name, err)
+			return errors.Join(err, p.stop(ctx, i-1, 0))
+		}
+		entry.started = true
+	}
+	return nil
+}
+
+// Stop runs Stoppers and cleanup funcs in reverse construction order, each once, and
+// returns every error they reported.
+func (p *Provider) Stop(ctx context.Context) error {
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+	return p.stop(ctx, len(p.lifecycle)-1, 0)
+}
+
+func (p *Provider) lifecycleLen() int {
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+	return len(p.lifecycle)
+}
+
+// stopAfter stops the lifecycle entries from index on and drops them.
+func (p *Provider) stopAfter(ctx context.Context, index int) error {
+	p.lifecycleLock.Lock()
+	defer p.lifecycleLock.Unlock()
+	err := p.stop(ctx, len(p.lifecycle)-1, index)
+	p.lifecycle = p.lifecycle[:index]
+	return err
+}
+
+func (p *Provider) stop(ctx context.Context, from int, to int) error {
+	if from < to {
+		return nil
+	}
+	return stopEntries(ctx, p.lifecycle[to:from+1], p.stopTimeout)
+}
+
+// stopEntries stops entries in reverse order, each once.
+func stopEntries(ctx context.Context, entries []lifecycleEntry, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+
+	errs := []error(nil)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := &entries[i]
+		if entry.stopped {
+			continue
+		}
+		entry.stopped = true
+
+		stop := func(context.Context) error {
+			return entry.cleanup()
+		}
+		if entry.cleanup == nil {
+			stopper, ok := entry.component.(Stopper)
+			if !ok {
+				continue
+			}
+			stop = stopper.Stop
+		}
+
+		if err := runWithTimeout(ctx, timeout, stop); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", entry.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runWithTimeout gives up waiting on fn after timeout even if fn ignores its context.
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,261 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// journal records what test components do, in order.
+type journal struct {
+	entries []string
+	lock    sync.Mutex
+}
+
+func (j *journal) add(entry string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.entries = append(j.entries, entry)
+}
+
+func (j *journal) String() string {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return strings.Join(j.entries, ", ")
+}
+
+type component struct {
+	name     string
+	journal  *journal
+	startErr error
+	stopErr  error
+	block    bool
+}
+
+func (c *component) Start(ctx context.Context) error {
+	if c.block {
+		<-make(chan struct{})
+	}
+	c.journal.add("start " + c.name)
+	return c.startErr
+}
+
+func (c *component) Stop(ctx context.Context) error {
+	c.journal.add("stop " + c.name)
+	return c.stopErr
+}
+
+type (
+	lifecycleConfig struct{ *component }
+	lifecycleDB     struct{ *component }
+	lifecycleAPI    struct{ *component }
+)
+
+func TestLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		db       func(*journal, lifecycleConfig) (lifecycleDB, func())
+		api      func(*journal) *component
+		start    string
+		startErr bool
+		stop     string
+		stopErr  bool
+	}{
+		{
+			name: "dependency order",
+			db: func(j *journal, c lifecycleConfig) (lifecycleDB, func()) {
+				return lifecycleDB{&component{name: "db", journal: j}}, func() { j.add("cleanup db") }
+			},
+			start: "start config, start db, start api",
+			stop:  "stop api, stop db, cleanup db, stop config",
+		},
+		{
+			name: "failed start rolls back",
+			db: func(j *journal, c lifecycleConfig) (lifecycleDB, func()) {
+				return lifecycleDB{&component{name: "db", journal: j, startErr: errors.New("refused")}}, nil
+			},
+			start:    "start config, start db, stop config",
+			startErr: true,
+			// constructed components are stopped even if they never started
+			stop: "stop api, stop db",
+		},
+		{
+			name: "stop errors are joined",
+			db: func(j *journal, c lifecycleConfig) (lifecycleDB, func()) {
+				return lifecycleDB{&component{name: "db", journal: j, stopErr: errors.New("busy")}}, nil
+			},
+			api: func(j *journal) *component {
+				return &component{name: "api", journal: j, stopErr: errors.New("draining")}
+			},
+			start:   "start config, start db, start api",
+			stop:    "stop api, stop db, stop config",
+			stopErr: true,
+		},
+		{
+			name: "start timeout",
+			db: func(j *journal, c lifecycleConfig) (lifecycleDB, func()) {
+				return lifecycleDB{&component{name: "db", journal: j, block: true}}, nil
+			},
+			start:    "start config, stop config",
+			startErr: true,
+			stop:     "stop api, stop db",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &journal{}
+			api := tt.api
+			if api == nil {
+				api = func(j *journal) *component { return &component{name: "api", journal: j} }
+			}
+
+			p := New()
+			p.SetStartTimeout(50 * time.Millisecond)
+			err := p.Register(
+				func() *journal { return j },
+				func(j *journal) lifecycleConfig { return lifecycleConfig{&component{name: "config", journal: j}} },
+				func(j *journal, c lifecycleConfig) (lifecycleDB, func()) { return tt.db(j, c) },
+				func(j *journal, db lifecycleDB) lifecycleAPI { return lifecycleAPI{api(j)} },
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.Start(context.Background()); (err != nil) != tt.startErr {
+				t.Fatalf("Start = %v, want error %v", err, tt.startErr)
+			}
+			if j.String() != tt.start {
+				t.Errorf("start: %s, want %s", j, tt.start)
+			}
+
+			j.entries = nil
+			err = p.Stop(context.Background())
+			if (err != nil) != tt.stopErr {
+				t.Errorf("Stop = %v, want error %v", err, tt.stopErr)
+			}
+			if j.String() != tt.stop {
+				t.Errorf("stop: %s, want %s", j, tt.stop)
+			}
+
+			j.entries = nil
+			if err := p.Stop(context.Background()); err != nil || j.String() != "" {
+				t.Errorf("second Stop = %v and ran %s", err, j)
+			}
+		})
+	}
+}
+
+type pool struct{}
+
+func TestCleanupReturns(t *testing.T) {
+	tests := []struct {
+		name        string
+		constructor any
+		stop        string
+		err         bool
+	}{
+		{
+			name:        "func()",
+			constructor: func(j *journal) (pool, func()) { return pool{}, func() { j.add("cleanup") } },
+			stop:        "cleanup",
+		},
+		{
+			name: "func() error",
+			constructor: func(j *journal) (pool, func() error) {
+				return pool{}, func() error { j.add("cleanup"); return errors.New("leak") }
+			},
+			stop: "cleanup",
+			err:  true,
+		},
+		{
+			name:        "nil cleanup",
+			constructor: func(j *journal) (pool, func(), error) { return pool{}, nil, nil },
+		},
+		{
+			name:        "panicking cleanup",
+			constructor: func(j *journal) (pool, func()) { return pool{}, func() { panic("oops") } },
+			err:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &journal{}
+			p := New()
+			if err := p.Register(func() *journal { return j }, tt.constructor); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := Get[pool](p); !ok {
+				t.Error("component is not provided")
+			}
+			if err := p.Stop(context.Background()); (err != nil) != tt.err {
+				t.Errorf("Stop = %v, want error %v", err, tt.err)
+			}
+			if j.String() != tt.stop {
+				t.Errorf("stop: %s, want %s", j, tt.stop)
+			}
+		})
+	}
+}
+
+func TestStartTwice(t *testing.T) {
+	j := &journal{}
+	p := New()
+	p.Register(func() *component { return &component{name: "a", journal: j} })
+	p.Construct(context.Background())
+	p.Start(context.Background())
+	p.Start(context.Background())
+	if j.String() != "start a" {
+		t.Errorf("started %s", j)
+	}
+}
+
+func TestUpdaterComponents(t *testing.T) {
+	tests := []struct {
+		name    string
+		updater func(j *journal) any
+		journal string
+	}{
+		{
+			name:    "same component",
+			updater: func(j *journal) any { return func(c *component) *component { return c } },
+			journal: "start a, stop a",
+		},
+		{
+			name: "new component",
+			updater: func(j *journal) any {
+				return func(c *component) *component { return &component{name: "b", journal: j} }
+			},
+			journal: "start a, start b, stop b, stop a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &journal{}
+			p := New()
+			if err := p.Register(func() *component { return &component{name: "a", journal: j} }); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := Update(p, tt.updater(j)); err != nil {
+				t.Fatal(err)
+			}
+			if err := errors.Join(p.Start(context.Background()), p.Stop(context.Background())); err != nil {
+				t.Fatal(err)
+			}
+			if got := j.String(); got != tt.journal {
+				t.Errorf("journal = %s, want %s", got, tt.journal)
+			}
+		})
+	}
+}
@@ -2,24 +2,46 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Provider struct {
-	zeroDepConstructors []reflect.Value
-	constructors        map[reflect.Type]map[reflect.Value]struct{}
-	container           map[reflect.Type]any
-	lock                sync.RWMutex
+	nodes          []*node
+	providers      map[key]*node
+	container      map[key]any
+	tracerProvider trace.TracerProvider
+	concurrency    int
+	constructions  int
+	parent         *Provider
+	lock           sync.RWMutex
+
+	lifecycle     []lifecycleEntry
+	startTimeout  time.Duration
+	stopTimeout   time.Duration
+	lifecycleLock sync.Mutex
+
+	timings    []Timing
+	timingLock sync.Mutex
+
+	consumers    []consumer
+	consumerLock sync.Mutex
 }
 
 func New() *Provider {
-	return &Provider{
-		constructors: make(map[reflect.Type]map[reflect.Value]struct{}),
-		container:    make(map[reflect.Type]any),
-		lock:         sync.RWMutex{},
+	p := &Provider{
+		providers: make(map[key]*node),
+		container: make(map[key]any),
+		lock:      sync.RWMutex{},
 	}
+	p.container[key{typ: reflect.TypeOf(p)}] = p
+	return p
 }
 
 func (p *Provider) Register(constructFunction ...any) error {
@@ -33,32 +55,110 @@ func (p *Provider) Register(constructFunction ...any) error {
 	return nil
 }
 
+// register adds constructFunction, a function or an Annotated one, to the dependency graph.
 func (p *Provider) register(constructFunction any) error {
-	args, _, err := analyzeFunction(constructFunction)
+	n, err := newNode(constructFunction, p.nextIndex())
 	if err != nil {
 		return err
 	}
+	return p.add(n)
+}
 
-	if len(args) == 0 {
-		p.zeroDepConstructors = append(p.zeroDepConstructors, reflect.ValueOf(constructFunction))
-		return nil
-	}
-
-	for _, arg := range args {
-		if _, ok := p.constructors[arg]; !ok {
-			p.constructors[arg] = make(map[reflect.Value]struct{})
+// add fails when another constructor already provides one of the values of n.
+func (p *Provider) add(n *node) error {
+	for _, t := range n.provides {
+		if other, ok := p.providers[t]; ok {
+			return ErrDuplicateProvider{Type: t.typ, Name: t.name, First: other.String(), Second: n.String()}
 		}
-		p.constructors[arg][reflect.ValueOf(constructFunction)] = struct{}{}
 	}
 
+	n.index = p.nextIndex()
+	p.nodes = append(p.nodes, n)
+	for _, t := range n.provides {
+		p.providers[t] = n
+	}
 	return nil
 }
 
+// nextIndex is the index of the next constructor registered. Indices only grow, also when
+// Replace removes constructors.
+func (p *Provider) nextIndex() int {
+	if len(p.nodes) == 0 {
+		return 0
+	}
+	return p.nodes[len(p.nodes)-1].index + 1
+}
+
+// Get returns the value of type T. An interface T is also satisfied by the one provided type
+// implementing it.
 func Get[T any](provider *Provider) (T, bool) {
+	return get[T](provider, key{typ: typeOf[T]()})
+}
+
+// Lookup is Get telling why there is no value, e.g. the error of the lazy constructor that
+// should have built it.
+func Lookup[T any](provider *Provider) (T, error) {
+	return lookup[T](provider, key{typ: typeOf[T]()})
+}
+
+// Collect returns every provided value assignable to T, usually an interface, ordered by type name.
+// A value provided as several types is returned once.
+func Collect[T any](provider *Provider) []T {
+	keyed := collect[T](provider)
+	values := make([]T, len(keyed))
+	for i, kv := range keyed {
+		values[i] = kv.value
+	}
+	return values
+}
+
+// CollectKeyed is Collect keyed by what each value is provided as, e.g. `*sql.DB name:"primary"`,
+// so values of the same type tell apart by name.
+func CollectKeyed[T any](provider *Provider) map[string]T {
+	keyed := collect[T](provider)
+	values := make(map[string]T, len(keyed))
+	for _, kv := range keyed {
+		values[kv.key.String()] = kv.value
+	}
+	return values
+}
+
+type keyedValue[T any] struct {
+	key   key
+	value T
+}
+
+func collect[T any](provider *Provider) []keyedValue[T] {
 	provider.lock.RLock()
 	defer provider.lock.RUnlock()
-	v, ok := provider.container[reflect.TypeOf(*new(T))].(T)
-	return v, ok
+
+	target := typeOf[T]()
+	keys := make([]key, 0)
+	for k := range provider.container {
+		if k.typ.AssignableTo(target) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	seen := make(map[any]struct{})
+	values := make([]keyedValue[T], 0, len(keys))
+	for _, k := range keys {
+		v, ok := provider.container[k].(T)
+		if !ok {
+			continue
+		}
+		if reflect.TypeOf(v).Comparable() {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+		}
+		values = append(values, keyedValue[T]{key: k, value: v})
+	}
+	return values
 }
 
 type ErrInvalidFunctionReturn struct{}
@@ -67,91 +167,134 @@ func (e ErrInvalidFunctionReturn) Error() string {
 	return "invalid function return"
 }
 
-func Run[T any](provider *Provider, function any) (r T, err error) {
-	provider.lock.RLock()
-	defer provider.lock.RUnlock()
+// MustGet is Get for values the program cannot do without. It panics when T is not provided.
+func MustGet[T any](provider *Provider) T {
+	v, err := Lookup[T](provider)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
 
-	args, rets, err := analyzeFunction(function)
+func Run[T any](provider *Provider, function any) (r T, err error) {
+	_, rets, err := analyzeFunction(function)
 	if err != nil {
 		return r, err
 	}
 
-	if len(rets) != 2 || rets[1].String() != "error" || rets[0] != reflect.TypeOf(*new(T)) {
+	if len(rets) != 2 || rets[1] != errorType || rets[0] != typeOf[T]() {
 		return r, ErrInvalidFunctionReturn{}
 	}
 
-	reflectArgs := make([]reflect.Value, len(args))
-	for i, arg := range args {
-		v, ok := provider.container[arg]
-		if !ok {
-			return r, ErrNotProvided{arg}
-		}
-		reflectArgs[i] = reflect.ValueOf(v)
+	results, err := Invoke(provider, function)
+	if err != nil {
+		return r, err
 	}
+	r, _ = results[0].(T)
+	return r, nil
+}
 
-	reflectReturns := reflect.ValueOf(function).Call(reflectArgs)
+func JustRun(provider *Provider, function any) error {
+	_, rets, err := analyzeFunction(function)
+	if err != nil {
+		return err
+	}
 
-	if !reflectReturns[1].IsNil() {
-		return r, reflectReturns[1].Interface().(error)
+	if len(rets) != 1 || rets[0] != errorType {
+		return ErrInvalidFunctionReturn{}
 	}
 
-	return reflectReturns[0].Interface().(T), nil
+	_, err = Invoke(provider, function)
+	return err
 }
 
-func JustRun(provider *Provider, function any) error {
-	provider.lock.RLock()
-	defer provider.lock.RUnlock()
-
-	args, rets, err := analyzeFunction(function)
+// Invoke calls function with provided values and returns what it returned. A trailing error
+// is returned as the error of Invoke instead.
+func Invoke(provider *Provider, function any) ([]any, error) {
+	n, err := newNode(function, -1)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	n.lifetime = Transient
+	provider.consumed(n, false)
+	if err := provider.constructFor([]*node{n}); err != nil {
+		return nil, err
 	}
 
-	if len(rets) != 1 || rets[0].String() != "error" {
-		return ErrInvalidFunctionReturn{}
+	// no lock is held while function or transient constructors run, so they may Get lazy values themselves
+	args, err := provider.arguments(provider.context(), n, nil, false)
+	if err != nil {
+		return nil, err
 	}
 
-	reflectArgs := make([]reflect.Value, len(args))
-	for i, arg := range args {
-		v, ok := provider.container[arg]
-		if !ok {
-			return ErrNotProvided{arg}
+	returns := n.con.Call(args)
+	results := make([]any, 0, len(returns))
+	for i, ret := range returns {
+		if i == len(returns)-1 && ret.Type() == errorType {
+			if !ret.IsNil() {
+				return results, ret.Interface().(error)
+			}
+			break
 		}
-		reflectArgs[i] = reflect.ValueOf(v)
+		results = append(results, ret.Interface())
 	}
+	return results, nil
+}
 
-	reflectReturns := reflect.ValueOf(function).Call(reflectArgs)
+// Populate fills the exported fields of target, a pointer to a struct embedding In, with
+// provided values.
+func Populate(provider *Provider, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() || !isIn(v.Elem().Type()) {
+		return ErrNotInjectable{Type: reflect.TypeOf(target)}
+	}
 
-	if !reflectReturns[0].IsNil() {
-		return reflectReturns[0].Interface().(error)
+	fields, err := newParam(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	n := &node{name: "provider.Populate", fullName: "provider.Populate " + v.Type().String(), params: []param{fields}, lifetime: Transient}
+	provider.consumed(n, false)
+	if err := provider.constructFor([]*node{n}); err != nil {
+		return err
 	}
 
+	args, err := provider.arguments(provider.context(), n, nil, false)
+	if err != nil {
+		return err
+	}
+	v.Elem().Set(args[0])
 	return nil
 }
 
+type ErrNotInjectable struct {
+	Type reflect.Type
+}
+
+func (e ErrNotInjectable) Error() string {
+	return fmt.Sprintf("not injectable: %v is not a pointer to a struct embedding provider.In", e.Type)
+}
+
+// Update calls functions with provided values and provides what they return in place of
+// the current values. Functions may return an error last, which stops Update.
 func Update(provider *Provider, functions ...any) error {
 	provider.lock.Lock()
 	defer provider.lock.Unlock()
 
 	for _, function := range functions {
-		args, _, err := analyzeFunction(function)
+		n, err := newNode(function, -1)
 		if err != nil {
 			return err
 		}
+		provider.consumed(n, true)
 
-		reflectArgs := make([]reflect.Value, len(args))
-		for i, arg := range args {
-			v, ok := provider.container[arg]
-			if !ok {
-				return ErrNotProvided{arg}
-			}
-			reflectArgs[i] = reflect.ValueOf(v)
+		reflectArgs, err := provider.arguments(provider.context(), n, nil, true)
+		if err != nil {
+			return err
 		}
 
-		results := reflect.ValueOf(function).Call(reflectArgs)
-
-		for _, result := range results {
-			provider.container[result.Type()] = result.Interface()
+		if err := provider.store(n, n.con.Call(reflectArgs)); err != nil {
+			return err
 		}
 	}
 
@@ -160,10 +303,25 @@ func Update(provider *Provider, functions ...any) error {
 
 type ErrNotProvided struct {
 	Type reflect.Type
+	Name string
 }
 
 func (e ErrNotProvided) Error() string {
-	return "not provided: " + e.Type.String()
+	return "not provided: " + key{typ: e.Type, name: e.Name}.String()
+}
+
+// ErrConstructorFailed wraps the error a constructor or updater returned.
+type ErrConstructorFailed struct {
+	Constructor string
+	Err         error
+}
+
+func (e ErrConstructorFailed) Error() string {
+	return "construct " + e.Constructor + ": " + e.Err.Error()
+}
+
+func (e ErrConstructorFailed) Unwrap() error {
+	return e.Err
 }
 
 type ErrInvalidConstructorReturn struct{}
@@ -172,6 +330,9 @@ func (e ErrInvalidConstructorReturn) Error() string {
 	return "invalid constructor return"
 }
 
+// ErrMaybeCyclicDependency was returned when Construct stopped making progress.
+//
+// Deprecated: Construct reports ErrCyclicDependency and ErrMissingDependency instead.
 type ErrMaybeCyclicDependency struct {
 	cons []reflect.Value
 }
@@ -193,65 +354,57 @@ func getContextType() reflect.Type {
 	return reflect.TypeOf((*context.Context)(nil)).Elem()
 }
 
-func (p *Provider) Construct(ctx context.Context) error {
-	p.lock.Lock()
-	defer p.lock.Unlock()
+// context returns the context given to Construct.
+func (p *Provider) context() context.Context {
+	if ctx, ok := p.container[key{typ: getContextType()}].(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
 
-	for _, con := range p.zeroDepConstructors {
-		returns := con.Call([]reflect.Value{})
-		for _, ret := range returns {
-			if ret.Type().Kind().String() == "error" {
-				if !ret.IsNil() {
-					return ret.Interface().(error)
-				}
-			}
+// valueOf wraps v as an argument of type t, keeping nil interfaces callable.
+func valueOf(t reflect.Type, v any) reflect.Value {
+	if v == nil {
+		return reflect.Zero(t)
+	}
+	return reflect.ValueOf(v)
+}
 
-			p.container[ret.Type()] = ret.Interface()
-		}
+// store puts what n returned into the container and tracks its lifecycle, unless n
+// returned an error.
+func (p *Provider) store(n *node, returns []reflect.Value) error {
+	values, err := n.results(returns)
+	if err != nil {
+		return err
 	}
 
-	p.container[getContextType()] = ctx
-	count := 0
-	for len(p.constructors) > 0 {
-		for arg, constructors := range p.constructors {
-		ConsLoop:
-			for con := range constructors {
-				args := make([]reflect.Value, con.Type().NumIn())
-				for i := 0; i < con.Type().NumIn(); i++ {
-					at := con.Type().In(i)
-					v, ok := p.container[at]
-					if !ok {
-						continue ConsLoop
-					}
-					args[i] = reflect.ValueOf(v)
-				}
-
-				returns := con.Call(args)
-
-				for _, ret := range returns {
-					if ret.Type().Kind().String() == "error" {
-						if !ret.IsNil() {
-							return ret.Interface().(error)
-						}
-					}
-
-					p.container[ret.Type()] = ret.Interface()
-				}
-
-				count++
-
-				delete(p.constructors[arg], con)
-			}
+	if !n.alias {
+		p.trackLifecycle(n.fullName, returns)
+	}
+	for k, v := range values {
+		p.container[k] = v
+	}
+	return nil
+}
 
-			if len(p.constructors[arg]) == 0 {
-				delete(p.constructors, arg)
-			}
+// results maps what n returned to the values it provides, or fails with the error n returned.
+func (n *node) results(returns []reflect.Value) (map[key]any, error) {
+	if len(returns) > 0 {
+		last := returns[len(returns)-1]
+		if last.Type() == errorType && !last.IsNil() {
+			return nil, ErrConstructorFailed{Constructor: n.String(), Err: last.Interface().(error)}
 		}
+	}
 
-		if count == 0 {
-			return ErrMaybeCyclicDependency{}
+	values := make(map[key]any, len(n.provides))
+	for _, ret := range returns {
+		if ret.Type() == errorType || isCleanup(ret.Type()) {
+			continue
 		}
+		values[n.key(ret.Type())] = ret.Interface()
 	}
-
-	return nil
+	for iface, impl := range n.as {
+		values[n.key(iface)] = values[n.key(impl)]
+	}
+	return values, nil
 }
@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type plugin interface {
+	Name() string
+}
+
+type namedPlugin string
+
+func (p namedPlugin) Name() string { return string(p) }
+
+type injected struct {
+	In
+	A       graphA
+	Store   *pgStore `name:"primary" optional:"true"`
+	Plugins []plugin `group:"plugins"`
+	hidden  graphB
+}
+
+func TestPopulate(t *testing.T) {
+	tests := []struct {
+		name     string
+		register []any
+		target   func() any
+		err      any
+		store    bool
+		plugins  string
+	}{
+		{
+			name:     "fields",
+			register: []any{newGraphA, Annotate(newPgStore, Named("primary"))},
+			target:   func() any { return &injected{} },
+			store:    true,
+		},
+		{
+			name:     "optional missing",
+			register: []any{newGraphA},
+			target:   func() any { return &injected{} },
+		},
+		{
+			name: "group",
+			register: []any{
+				newGraphA,
+				Annotate(func() namedPlugin { return "auth" }, Group("plugins"), As(new(plugin))),
+				Annotate(func() namedPlugin { return "gzip" }, Group("plugins"), As(new(plugin))),
+			},
+			target:  func() any { return &injected{} },
+			plugins: "auth, gzip",
+		},
+		{
+			name:   "required missing",
+			target: func() any { return &injected{} },
+			err:    &ErrNotProvided{},
+		},
+		{
+			name:     "not a pointer",
+			register: []any{newGraphA},
+			target:   func() any { return injected{} },
+			err:      &ErrNotInjectable{},
+		},
+		{
+			name:   "nil",
+			target: func() any { return (*injected)(nil) },
+			err:    &ErrNotInjectable{},
+		},
+		{
+			name:   "without In",
+			target: func() any { return &struct{ A graphA }{} },
+			err:    &ErrNotInjectable{},
+		},
+		{
+			name: "bad optional tag",
+			target: func() any {
+				return &struct {
+					In
+					A graphA `optional:"maybe"`
+				}{}
+			},
+			err: &ErrInvalidField{},
+		},
+		{
+			name: "group of one",
+			target: func() any {
+				return &struct {
+					In
+					Plugin plugin `group:"plugins"`
+				}{}
+			},
+			err: &ErrInvalidField{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(tt.register...); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			target := tt.target()
+			err := Populate(p, target)
+			if tt.err != nil {
+				if !errors.As(err, tt.err) {
+					t.Errorf("Populate = %v, want %T", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := target.(*injected)
+			if (in.Store != nil) != tt.store {
+				t.Errorf("store = %v, want set %v", in.Store, tt.store)
+			}
+			names := []string(nil)
+			for _, plugin := range in.Plugins {
+				names = append(names, plugin.Name())
+			}
+			if got := strings.Join(names, ", "); got != tt.plugins {
+				t.Errorf("plugins = %q, want %q", got, tt.plugins)
+			}
+		})
+	}
+}
+
+func TestMustGet(t *testing.T) {
+	p := New()
+	if err := p.Register(newGraphA); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	MustGet[graphA](p)
+
+	defer func() {
+		if err, _ := recover().(error); !errors.As(err, &ErrNotProvided{}) {
+			t.Errorf("recovered %v, want ErrNotProvided", err)
+		}
+	}()
+	MustGet[graphB](p)
+}
+
+func TestInvoke(t *testing.T) {
+	tests := []struct {
+		name     string
+		function any
+		results  int
+		err      error
+	}{
+		{"nothing", func() {}, 0, nil},
+		{"values", func(a graphA) (graphA, int) { return a, 1 }, 2, nil},
+		{"nil error", func(a graphA) (int, error) { return 1, nil }, 1, nil},
+		{"error", func(a graphA) (int, error) { return 0, errRefused }, 1, errRefused},
+		{"missing argument", func(b graphB) {}, 0, ErrNotProvided{Type: typeOf[graphB]()}},
+		{"not a function", 42, 0, ErrNotAFunction{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(newGraphA); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := Invoke(p, tt.function)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Invoke = %v, want %v", err, tt.err)
+			}
+			if len(results) != tt.results {
+				t.Errorf("%d results, want %d", len(results), tt.results)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	p := New()
+	if err := p.Register(newGraphA); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		err  error
+	}{
+		{"run", func() error {
+			n, err := Run[int](p, func(a graphA) (int, error) { return 7, nil })
+			if err == nil && n != 7 {
+				t.Errorf("Run = %d, want 7", n)
+			}
+			return err
+		}, nil},
+		{"run error", func() error {
+			_, err := Run[int](p, func(a graphA) (int, error) { return 0, errRefused })
+			return err
+		}, errRefused},
+		{"run other type", func() error {
+			_, err := Run[string](p, func(a graphA) (int, error) { return 7, nil })
+			return err
+		}, ErrInvalidFunctionReturn{}},
+		{"run without error", func() error {
+			_, err := Run[int](p, func(a graphA) int { return 7 })
+			return err
+		}, ErrInvalidFunctionReturn{}},
+		{"just run", func() error {
+			return JustRun(p, func(a graphA) error { return nil })
+		}, nil},
+		{"just run error", func() error {
+			return JustRun(p, func(a graphA) error { return errRefused })
+		}, errRefused},
+		{"just run a value", func() error {
+			return JustRun(p, func(a graphA) int { return 7 })
+		}, ErrInvalidFunctionReturn{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	p := New()
+	err := p.Register(
+		Annotate(func() *pgStore { return &pgStore{name: "primary"} }, Named("primary")),
+		Annotate(func() *pgStore { return &pgStore{name: "replica"} }, Named("replica")),
+		Annotate(newMemStore, As(new(store))),
+		newGraphA,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := []string(nil)
+	for _, s := range Collect[store](p) {
+		loaded = append(loaded, s.Load())
+	}
+	// *memStore is provided as itself and as store, but collected once
+	if got, want := strings.Join(loaded, ", "), "mem, pg primary, pg replica"; got != want {
+		t.Errorf("Collect = %s, want %s", got, want)
+	}
+
+	keyed := CollectKeyed[*pgStore](p)
+	if len(keyed) != 2 || keyed[`*provider.pgStore name:"replica"`].name != "replica" {
+		t.Errorf("CollectKeyed = %v", keyed)
+	}
+}
@@ -0,0 +1,102 @@
+// Package providertest builds providers from generated component sets for tests, with some
+// constructors swapped out, e.g.
+//
+//	p := providertest.New(t, components.NewDefault(), providertest.Replace(providertest.Value[Mailer](fake)))
+package providertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+// Set is a component set generated by lux, e.g. components.NewDefault().
+type Set interface {
+	Constructors() []any
+	Updaters() []any
+}
+
+// Option changes the provider New builds before anything is constructed.
+type Option func(p *provider.Provider) error
+
+// Register adds constructors the set does not have.
+func Register(constructors ...any) Option {
+	return func(p *provider.Provider) error {
+		return p.Register(constructors...)
+	}
+}
+
+// Replace registers constructors in place of the ones of the set providing the same values.
+func Replace(constructors ...any) Option {
+	return func(p *provider.Provider) error {
+		return p.Replace(constructors...)
+	}
+}
+
+// Decorate wraps values the set provides, see provider.Provider.Decorate.
+func Decorate(decorators ...any) Option {
+	return func(p *provider.Provider) error {
+		return p.Decorate(decorators...)
+	}
+}
+
+// Value returns a constructor providing v as T, e.g. a fake to Replace a real one with.
+func Value[T any](v T) func() T {
+	return func() T {
+		return v
+	}
+}
+
+// New registers the constructors of set, applies options, constructs the provider, runs the
+// updaters of set and starts the components. Any error fails t. The components are stopped
+// when t ends.
+func New(t testing.TB, set Set, options ...Option) *provider.Provider {
+	t.Helper()
+
+	p := provider.New()
+	if err := p.Register(set.Constructors()...); err != nil {
+		t.Fatalf("providertest: register: %v", err)
+	}
+	for _, option := range options {
+		if err := option(p); err != nil {
+			t.Fatalf("providertest: %v", err)
+		}
+	}
+	return start(t, p, set.Updaters())
+}
+
+// Child builds a child of parent with options applied, constructs and starts it. Values
+// parent has already constructed are shared unless they depend on a changed one.
+func Child(t testing.TB, parent *provider.Provider, options ...Option) *provider.Provider {
+	t.Helper()
+
+	p := parent.Child()
+	for _, option := range options {
+		if err := option(p); err != nil {
+			t.Fatalf("providertest: %v", err)
+		}
+	}
+	return start(t, p, nil)
+}
+
+func start(t testing.TB, p *provider.Provider, updaters []any) *provider.Provider {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := p.Construct(ctx); err != nil {
+		t.Fatalf("providertest: construct: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("providertest: stop: %v", err)
+		}
+	})
+	if err := provider.Update(p, updaters...); err != nil {
+		t.Fatalf("providertest: update: %v", err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("providertest: start: %v", err)
+	}
+	return p
+}
@@ -0,0 +1,119 @@
+package providertest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/lux/v3/provider"
+)
+
+type mailer interface {
+	Send(to string) string
+}
+
+type smtpMailer struct{}
+
+func (smtpMailer) Send(to string) string { return "smtp " + to }
+
+type fakeMailer struct{}
+
+func (fakeMailer) Send(to string) string { return "fake " + to }
+
+type prefixed struct {
+	mailer
+	prefix string
+}
+
+func (m prefixed) Send(to string) string { return m.prefix + m.mailer.Send(to) }
+
+// server records its lifecycle, and what it sent, in journal.
+type server struct {
+	mailer  mailer
+	journal *[]string
+}
+
+func (s *server) Start(ctx context.Context) error {
+	*s.journal = append(*s.journal, "start "+s.mailer.Send("a"))
+	return nil
+}
+
+func (s *server) Stop(ctx context.Context) error {
+	*s.journal = append(*s.journal, "stop")
+	return nil
+}
+
+type testSet struct {
+	journal *[]string
+}
+
+func (s testSet) Constructors() []any {
+	return []any{
+		func() mailer { return smtpMailer{} },
+		func(m mailer) *server { return &server{mailer: m, journal: s.journal} },
+	}
+}
+
+func (s testSet) Updaters() []any {
+	return []any{
+		func(srv *server) *server {
+			*s.journal = append(*s.journal, "update")
+			return srv
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		journal string
+	}{
+		{"set", nil, "update, start smtp a, stop"},
+		{"replaced", []Option{Replace(Value[mailer](fakeMailer{}))}, "update, start fake a, stop"},
+		{"decorated", []Option{Decorate(func(m mailer) mailer { return prefixed{m, "> "} })}, "update, start > smtp a, stop"},
+		{"registered", []Option{Register(Value("extra"))}, "update, start smtp a, stop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := []string(nil)
+			t.Run("provider", func(t *testing.T) {
+				p := New(t, testSet{journal: &journal}, tt.options...)
+				if _, err := provider.Lookup[*server](p); err != nil {
+					t.Error(err)
+				}
+			})
+			// the provider is stopped when the test that built it ends
+			if got := strings.Join(journal, ", "); got != tt.journal {
+				t.Errorf("journal = %s, want %s", got, tt.journal)
+			}
+		})
+	}
+}
+
+func TestChild(t *testing.T) {
+	journal := []string(nil)
+	parent := New(t, testSet{journal: &journal})
+
+	tests := []struct {
+		name    string
+		options []Option
+		shared  bool
+		sent    string
+	}{
+		{"unchanged", nil, true, "smtp a"},
+		{"replaced", []Option{Replace(Value[mailer](fakeMailer{}))}, false, "fake a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			child := Child(t, parent, tt.options...)
+			srv := provider.MustGet[*server](child)
+			if shared := srv == provider.MustGet[*server](parent); shared != tt.shared {
+				t.Errorf("shared = %v, want %v", shared, tt.shared)
+			}
+			if got := srv.mailer.Send("a"); got != tt.sent {
+				t.Errorf("sent %q, want %q", got, tt.sent)
+			}
+		})
+	}
+}
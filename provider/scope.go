@@ -0,0 +1,364 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Lifetime tells how often a constructor is called.
+type Lifetime int
+
+const (
+	// Singleton constructors are called once, by Construct.
+	Singleton Lifetime = iota
+	// Transient constructors are called every time their value is needed. What they return
+	// is stopped with the Scope they were called in, and otherwise left to the caller.
+	Transient
+	// RequestScoped constructors are called at most once per Scope, when their value is
+	// first needed, and what they return is stopped when the Scope closes.
+	RequestScoped
+)
+
+func (l Lifetime) String() string {
+	switch l {
+	case Singleton:
+		return "singleton"
+	case Transient:
+		return "transient"
+	case RequestScoped:
+		return "request scoped"
+	}
+	return fmt.Sprintf("Lifetime(%d)", int(l))
+}
+
+// Scope holds the request scoped values of one request. lux opens one per request when
+// given a provider with lux.SetRequestScope.
+type Scope struct {
+	provider  *Provider
+	ctx       context.Context
+	values    map[key]any
+	builds    map[*node]*sync.Mutex
+	lifecycle []lifecycleEntry
+	closed    bool
+	lock      sync.Mutex
+}
+
+// NewScope opens a scope whose constructors get ctx as their context.Context.
+func (p *Provider) NewScope(ctx context.Context) *Scope {
+	return &Scope{
+		provider: p,
+		ctx:      ctx,
+		values:   make(map[key]any),
+		builds:   make(map[*node]*sync.Mutex),
+	}
+}
+
+// Close stops what the scope constructed, in reverse order. Resolving from a closed scope fails.
+func (s *Scope) Close(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	s.provider.lifecycleLock.Lock()
+	timeout := s.provider.stopTimeout
+	s.provider.lifecycleLock.Unlock()
+	return stopEntries(ctx, s.lifecycle, timeout)
+}
+
+// Resolve returns the value of type T within s, building it when it is transient or request
+// scoped and not built in s yet.
+func Resolve[T any](s *Scope) (T, error) {
+	return ResolveNamed[T](s, "")
+}
+
+// ResolveNamed returns the value of type T provided under name within s. No lock is held
+// while constructors run, so they may resolve from s themselves.
+func ResolveNamed[T any](s *Scope, name string) (T, error) {
+	var r T
+	v, err := s.ResolveType(typeOf[T](), name)
+	if err != nil {
+		return r, err
+	}
+	r, _ = v.(T)
+	return r, nil
+}
+
+// ResolveType is ResolveNamed for a type known at run time. It lets context.Resolve reach
+// the scope without the context package depending on provider.
+func (s *Scope) ResolveType(t reflect.Type, name string) (any, error) {
+	if s == nil {
+		return nil, ErrNoScope{}
+	}
+
+	k := key{typ: t, name: name}
+	if err := s.provider.constructLazy(k); err != nil {
+		return nil, err
+	}
+	if s.isClosed() {
+		return nil, ErrScopeClosed{}
+	}
+
+	v, err := s.provider.instance(s.ctx, k, nil, s, false)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func (s *Scope) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+// building returns the lock serializing the calls of n within s, so a request scoped
+// constructor runs at most once per scope however many resolve it at once.
+func (s *Scope) building(n *node) *sync.Mutex {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	build, ok := s.builds[n]
+	if !ok {
+		build = new(sync.Mutex)
+		s.builds[n] = build
+	}
+	return build
+}
+
+func (s *Scope) value(k key) (any, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, ok := s.values[k]
+	return v, ok
+}
+
+// keep records what n returned within s, for Close to stop, and stores request scoped values.
+func (s *Scope) keep(n *node, returns []reflect.Value, values map[key]any) (map[key]any, error) {
+	entries := []lifecycleEntry(nil)
+	if !n.alias {
+		entries = lifecycleEntries(n.fullName, returns)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		s.provider.lifecycleLock.Lock()
+		timeout := s.provider.stopTimeout
+		s.provider.lifecycleLock.Unlock()
+		return nil, errors.Join(ErrScopeClosed{}, stopEntries(s.ctx, entries, timeout))
+	}
+	s.lifecycle = append(s.lifecycle, entries...)
+	if n.lifetime != RequestScoped {
+		return values, nil
+	}
+	for rk, v := range values {
+		s.values[rk] = v
+	}
+	return values, nil
+}
+
+// reading runs f under the read lock of p unless the caller holds the lock already, as
+// construction does. Resolving otherwise goes without it, so that constructors run unlocked
+// and may resolve themselves.
+func (p *Provider) reading(locked bool, f func()) {
+	if !locked {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+	}
+	f()
+}
+
+type scopeKey struct{}
+
+func WithScope(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+func ScopeFrom(ctx context.Context) (*Scope, bool) {
+	s, ok := ctx.Value(scopeKey{}).(*Scope)
+	return s, ok
+}
+
+// instance returns the value satisfying k for dependent, building transient values and,
+// within s, request scoped ones. locked tells whether the caller holds the lock of p.
+func (p *Provider) instance(ctx context.Context, k key, dependent *node, s *Scope, locked bool) (reflect.Value, error) {
+	if s != nil && k == (key{typ: getContextType()}) {
+		return reflect.ValueOf(&s.ctx).Elem(), nil
+	}
+
+	if k.group != "" && k.member == 0 {
+		return p.groupInstance(ctx, k, dependent, s, locked)
+	}
+
+	constructor := ""
+	if dependent != nil {
+		constructor = dependent.String()
+	}
+	var (
+		resolved   key
+		candidates []key
+		v          any
+		provided   bool
+		n          *node
+		ok         bool
+		tp         trace.TracerProvider
+	)
+	p.reading(locked, func() {
+		resolved, candidates = p.resolve(k)
+		v, provided = p.container[resolved]
+		n, ok = p.providers[resolved]
+		tp = p.tracerProvider
+	})
+	if candidates != nil {
+		return reflect.Value{}, ErrAmbiguousDependency{Type: k.typ, Name: k.name, Constructor: constructor, Candidates: keyStrings(candidates)}
+	}
+	if provided {
+		return valueOf(k.typ, v), nil
+	}
+
+	if !ok || n.lifetime == Singleton {
+		return reflect.Value{}, ErrNotProvided{Type: k.typ, Name: k.name}
+	}
+	if n.lifetime == RequestScoped {
+		if s == nil {
+			return reflect.Value{}, ErrOutOfScope{Type: k.typ, Name: k.name, Constructor: constructor}
+		}
+		build := s.building(n)
+		build.Lock()
+		defer build.Unlock()
+		if v, ok := s.value(resolved); ok {
+			return valueOf(k.typ, v), nil
+		}
+	}
+
+	args, err := p.arguments(ctx, n, s, locked)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	returns := call(ctx, tp, n, args)
+	values, err := n.results(returns)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	// outside a scope, transient values belong to whoever asked for them and are not stopped
+	if s != nil {
+		if values, err = s.keep(n, returns, values); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return valueOf(k.typ, values[resolved]), nil
+}
+
+// groupInstance collects the values of the group k into a slice, in registration order.
+func (p *Provider) groupInstance(ctx context.Context, k key, dependent *node, s *Scope, locked bool) (reflect.Value, error) {
+	elem := k.typ.Elem()
+	values := reflect.MakeSlice(k.typ, 0, 0)
+	members := []key(nil)
+	p.reading(locked, func() {
+		for _, n := range p.providersOf(k) {
+			members = append(members, n.members(elem)...)
+		}
+	})
+	for _, member := range members {
+		v, err := p.instance(ctx, member, dependent, s, locked)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v.Type() != elem {
+			converted := reflect.New(elem).Elem()
+			converted.Set(v)
+			v = converted
+		}
+		values = reflect.Append(values, v)
+	}
+	return values, nil
+}
+
+// singletonDeps lists the singleton constructors n needs, looking through the transient
+// constructors it needs, which Construct calls along with n.
+func (p *Provider) singletonDeps(n *node) []*node {
+	deps := []*node(nil)
+	seen := map[*node]struct{}{n: {}}
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, arg := range n.deps() {
+			for _, dep := range p.providersOf(arg) {
+				if _, ok := seen[dep]; ok {
+					continue
+				}
+				seen[dep] = struct{}{}
+				if dep.lifetime == Transient {
+					walk(dep)
+					continue
+				}
+				deps = append(deps, dep)
+			}
+		}
+	}
+	walk(n)
+	return deps
+}
+
+// scopeViolations finds singletons that need request scoped values, directly or through
+// transient ones, which would keep a value of the first request forever.
+func (p *Provider) scopeViolations() []error {
+	errs := []error(nil)
+	for _, n := range p.nodes {
+		if n.lifetime != Singleton {
+			continue
+		}
+		for _, dep := range p.singletonDeps(n) {
+			if dep.lifetime == RequestScoped {
+				errs = append(errs, ErrScopeViolation{Constructor: n.String(), Dependency: dep.String()})
+			}
+		}
+	}
+	return errs
+}
+
+type ErrScopeViolation struct {
+	Constructor string
+	Dependency  string
+}
+
+func (e ErrScopeViolation) Error() string {
+	return "scope violation: singleton " + e.Constructor + " needs request scoped " + e.Dependency
+}
+
+type ErrOutOfScope struct {
+	Type        reflect.Type
+	Name        string
+	Constructor string
+}
+
+func (e ErrOutOfScope) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString("out of scope: ")
+	sb.WriteString(key{typ: e.Type, name: e.Name}.String())
+	sb.WriteString(" is request scoped")
+	if e.Constructor != "" {
+		sb.WriteString(", needed by " + e.Constructor)
+	}
+	return sb.String()
+}
+
+type ErrNoScope struct{}
+
+func (e ErrNoScope) Error() string {
+	return "no scope"
+}
+
+type ErrScopeClosed struct{}
+
+func (e ErrScopeClosed) Error() string {
+	return "scope closed"
+}
@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type requestValue struct{ id int32 }
+
+func TestLifetimes(t *testing.T) {
+	tests := []struct {
+		lifetime Lifetime
+		// builds is how often the constructor runs for two resolutions in one scope and one
+		// in another.
+		builds int32
+	}{
+		{Singleton, 1},
+		{Transient, 3},
+		{RequestScoped, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lifetime.String(), func(t *testing.T) {
+			builds := int32(0)
+			p := New()
+			err := p.Register(Annotate(func() *requestValue {
+				return &requestValue{id: atomic.AddInt32(&builds, 1)}
+			}, WithLifetime(tt.lifetime)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Construct(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			first := p.NewScope(context.Background())
+			a, errA := Resolve[*requestValue](first)
+			b, errB := Resolve[*requestValue](first)
+			c, errC := Resolve[*requestValue](p.NewScope(context.Background()))
+			if err := errors.Join(errA, errB, errC); err != nil {
+				t.Fatal(err)
+			}
+			if builds != tt.builds {
+				t.Errorf("built %d times, want %d", builds, tt.builds)
+			}
+			if sameInScope := a == b; sameInScope != (tt.lifetime != Transient) {
+				t.Errorf("same value within a scope = %v", sameInScope)
+			}
+			if sameAcross := a == c; sameAcross != (tt.lifetime == Singleton) {
+				t.Errorf("same value across scopes = %v", sameAcross)
+			}
+		})
+	}
+}
+
+func TestScopeErrors(t *testing.T) {
+	request := func() requestValue { return requestValue{} }
+	tests := []struct {
+		name     string
+		register []any
+		check    func(p *Provider) error
+		// want is a pointer to the error type expected, nil for none
+		want any
+	}{
+		{
+			name:     "no scope",
+			register: []any{Annotate(request, WithLifetime(RequestScoped))},
+			check: func(p *Provider) error {
+				_, err := Resolve[requestValue](nil)
+				return err
+			},
+			want: &ErrNoScope{},
+		},
+		{
+			name:     "out of scope",
+			register: []any{Annotate(request, WithLifetime(RequestScoped))},
+			check: func(p *Provider) error {
+				_, err := Lookup[requestValue](p)
+				return err
+			},
+			want: &ErrOutOfScope{},
+		},
+		{
+			name:     "singleton needs request scoped",
+			register: []any{Annotate(request, WithLifetime(RequestScoped)), func(requestValue) graphA { return graphA{} }},
+			check:    func(p *Provider) error { return p.Validate() },
+			want:     &ErrScopeViolation{},
+		},
+		{
+			name: "singleton needs request scoped through transient",
+			register: []any{
+				Annotate(request, WithLifetime(RequestScoped)),
+				Annotate(func(requestValue) graphB { return graphB{} }, WithLifetime(Transient)),
+				func(graphB) graphA { return graphA{} },
+			},
+			check: func(p *Provider) error { return p.Validate() },
+			want:  &ErrScopeViolation{},
+		},
+		{
+			name: "request scoped needs request scoped",
+			register: []any{
+				Annotate(request, WithLifetime(RequestScoped)),
+				Annotate(func(requestValue) graphA { return graphA{} }, WithLifetime(RequestScoped)),
+			},
+			check: func(p *Provider) error { return p.Validate() },
+		},
+		{
+			name:     "closed scope",
+			register: []any{Annotate(request, WithLifetime(RequestScoped))},
+			check: func(p *Provider) error {
+				s := p.NewScope(context.Background())
+				if err := s.Close(context.Background()); err != nil {
+					return err
+				}
+				_, err := Resolve[requestValue](s)
+				return err
+			},
+			want: &ErrScopeClosed{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Register(tt.register...); err != nil {
+				t.Fatal(err)
+			}
+			err := tt.check(p)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, tt.want) {
+				t.Errorf("err = %v, want %T", err, tt.want)
+			}
+		})
+	}
+}
+
+type scopeParams struct {
+	In
+	Tx   *component `name:"tx"`
+	Conn *component `name:"conn"`
+}
+
+func TestScopeClose(t *testing.T) {
+	j := &journal{}
+	p := New()
+	err := p.Register(
+		Annotate(func() (*component, func()) {
+			return &component{name: "tx", journal: j}, func() { j.add("cleanup tx") }
+		}, Named("tx"), WithLifetime(RequestScoped)),
+		Annotate(func() *component {
+			return &component{name: "conn", journal: j}
+		}, Named("conn"), WithLifetime(Transient)),
+		Annotate(func(params scopeParams) graphA {
+			j.add("handler")
+			return graphA{}
+		}, WithLifetime(Transient)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := p.NewScope(context.Background())
+	if _, err := Resolve[graphA](s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+	// request scoped and transient values are stopped in reverse, only once
+	if got, want := j.String(), "handler, stop conn, stop tx, cleanup tx"; got != want {
+		t.Errorf("journal = %s, want %s", got, want)
+	}
+}
+
+func TestScopeBuildsOnce(t *testing.T) {
+	builds := int32(0)
+	p := New()
+	err := p.Register(Annotate(func(ctx context.Context) requestValue {
+		return requestValue{id: atomic.AddInt32(&builds, 1)}
+	}, WithLifetime(RequestScoped)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := p.NewScope(context.Background())
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Resolve[requestValue](s); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if builds != 1 {
+		t.Errorf("built %d times in one scope, want 1", builds)
+	}
+}
+
+func TestScopeContext(t *testing.T) {
+	type tenantKey struct{}
+	p := New()
+	err := p.Register(Annotate(func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	}, WithLifetime(RequestScoped)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Construct(context.WithValue(context.Background(), tenantKey{}, "startup")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := p.NewScope(context.WithValue(context.Background(), tenantKey{}, "acme"))
+	if tenant, err := Resolve[string](s); err != nil || tenant != "acme" {
+		t.Errorf("tenant = %q, %v, want the context of the scope", tenant, err)
+	}
+	if from, ok := ScopeFrom(WithScope(context.Background(), s)); !ok || from != s {
+		t.Error("ScopeFrom does not return the scope given to WithScope")
+	}
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/snowmerak/lux/v3/provider"
+
+// SetTracerProvider makes Construct trace every constructor call as a child of its context.
+func (p *Provider) SetTracerProvider(tp trace.TracerProvider) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.tracerProvider = tp
+}
+
+// call calls the constructor of n, traced when tp is set. Callers read tp from the provider
+// under its lock.
+func call(ctx context.Context, tp trace.TracerProvider, n *node, args []reflect.Value) []reflect.Value {
+	if tp == nil {
+		return n.con.Call(args)
+	}
+
+	name := n.name
+	_, span := tp.Tracer(tracerName).Start(ctx, "construct "+name, trace.WithAttributes(
+		attribute.String("provider.constructor", name),
+	))
+	defer span.End()
+
+	returns := n.con.Call(args)
+	provides := make([]string, 0, len(returns))
+	for _, ret := range returns {
+		if ret.Type() == errorType {
+			if !ret.IsNil() {
+				err := ret.Interface().(error)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			continue
+		}
+		provides = append(provides, ret.Type().String())
+	}
+	span.SetAttributes(attribute.StringSlice("provider.provides", provides))
+	return returns
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func constructorName(con reflect.Value) string {
+	if fn := runtime.FuncForPC(con.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return con.Type().String()
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tracedConfig struct{}
+type tracedStore struct{}
+
+func TestConstructorSpans(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(tracedConfig) (*tracedStore, error)
+		spans []string
+		error bool
+	}{
+		{
+			name:  "constructed",
+			store: func(tracedConfig) (*tracedStore, error) { return &tracedStore{}, nil },
+			spans: []string{"construct ok", "construct ok"},
+		},
+		{
+			name:  "failed",
+			store: func(tracedConfig) (*tracedStore, error) { return nil, errors.New("no database") },
+			spans: []string{"construct ok", "construct error"},
+			error: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			p := New()
+			p.SetTracerProvider(tp)
+			if err := p.Register(func() tracedConfig { return tracedConfig{} }, tt.store); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, root := tp.Tracer("test").Start(context.Background(), "startup")
+			err := p.Construct(ctx)
+			root.End()
+			if (err != nil) != tt.error {
+				t.Fatalf("Construct = %v, want error %v", err, tt.error)
+			}
+
+			spans := []string(nil)
+			for _, span := range recorder.Ended() {
+				if span.Name() == "startup" {
+					continue
+				}
+				if !strings.HasPrefix(span.Name(), "construct ") {
+					t.Errorf("span %q", span.Name())
+				}
+				if span.Parent().SpanID() != root.SpanContext().SpanID() {
+					t.Errorf("%q is not a child of the context's span", span.Name())
+				}
+				status := "ok"
+				if span.Status().Code == codes.Error {
+					status = "error"
+				}
+				spans = append(spans, "construct "+status)
+			}
+			if strings.Join(spans, "|") != strings.Join(tt.spans, "|") {
+				t.Errorf("spans = %q, want %q", spans, tt.spans)
+			}
+		})
+	}
+}
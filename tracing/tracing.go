@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+type Config struct {
+	ServiceName string
+	// Exporter receives finished spans, e.g. an OTLP exporter, NewStdoutExporter or NewMemoryExporter.
+	Exporter sdktrace.SpanExporter
+	// SampleRatio samples that share of new traces, following the caller's decision otherwise.
+	// Zero samples everything.
+	SampleRatio float64
+	// Synchronous exports each span as it ends instead of in batches, for tests and debugging.
+	Synchronous bool
+}
+
+// NewProvider builds a tracer provider to pass to lux.SetTracerProvider and
+// Provider.SetTracerProvider. Shut it down to flush the remaining spans.
+func NewProvider(cfg Config) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	}
+	if cfg.Exporter != nil {
+		if cfg.Synchronous {
+			opts = append(opts, sdktrace.WithSyncer(cfg.Exporter))
+		} else {
+			opts = append(opts, sdktrace.WithBatcher(cfg.Exporter))
+		}
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// NewStdoutExporter writes spans to w as JSON.
+func NewStdoutExporter(w io.Writer, pretty bool) (sdktrace.SpanExporter, error) {
+	opts := []stdouttrace.Option{stdouttrace.WithWriter(w)}
+	if pretty {
+		opts = append(opts, stdouttrace.WithPrettyPrint())
+	}
+	return stdouttrace.New(opts...)
+}
+
+// NewMemoryExporter keeps spans in memory so tests can inspect them with GetSpans.
+func NewMemoryExporter() *tracetest.InMemoryExporter {
+	return tracetest.NewInMemoryExporter()
+}
+
+// Shutdown flushes and stops tp, waiting at most timeout.
+func Shutdown(tp *sdktrace.TracerProvider, timeout time.Duration) error {
+	c, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return tp.Shutdown(c)
+}
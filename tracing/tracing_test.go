@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewProvider(t *testing.T) {
+	remote := func(flags trace.TraceFlags) context.Context {
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+			Remote:     true,
+		}))
+	}
+
+	tests := []struct {
+		name     string
+		ratio    float64
+		parent   context.Context
+		sampled  bool
+		exported int
+	}{
+		{"everything", 0, context.Background(), true, 1},
+		{"ratio one", 1, context.Background(), true, 1},
+		{"sampled parent", 0.000001, remote(trace.FlagsSampled), true, 1},
+		{"unsampled parent", 0, remote(0), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := NewMemoryExporter()
+			tp, err := NewProvider(Config{ServiceName: "test", Exporter: exporter, SampleRatio: tt.ratio, Synchronous: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Shutdown(tp, time.Second)
+
+			_, span := tp.Tracer("test").Start(tt.parent, "work")
+			span.End()
+			if span.SpanContext().IsSampled() != tt.sampled {
+				t.Errorf("sampled = %v, want %v", span.SpanContext().IsSampled(), tt.sampled)
+			}
+			spans := exporter.GetSpans()
+			if len(spans) != tt.exported {
+				t.Fatalf("exported %d spans, want %d", len(spans), tt.exported)
+			}
+			for _, span := range spans {
+				if name, ok := span.Resource.Set().Value("service.name"); !ok || name.AsString() != "test" {
+					t.Errorf("service.name = %v", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package tus
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("tus: upload not found")
+	ErrOffsetMismatch = errors.New("tus: upload offset mismatch")
+	ErrChunkTooLarge  = errors.New("tus: chunk exceeds the upload length")
+)
+
+type Info struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+}
+
+func (i Info) IsComplete() bool {
+	return i.Offset >= i.Length
+}
+
+func (i Info) IsExpired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !i.IsComplete() && now.After(i.ExpiresAt)
+}
+
+// Storage keeps upload state. Calls for one ID are serialized by the Handler.
+type Storage interface {
+	Create(info Info) error
+	Info(id string) (Info, error)
+	// Append writes r at offset and returns the new offset, which also counts bytes
+	// written before an error. When r holds more than the upload has left, it returns
+	// ErrChunkTooLarge and leaves the upload at offset.
+	Append(id string, offset int64, r io.Reader) (int64, error)
+	Terminate(id string) error
+	Expired(now time.Time) ([]string, error)
+}
+
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+// Path returns the file holding the data of upload id.
+func (s *LocalStorage) Path(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *LocalStorage) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+func (s *LocalStorage) Create(info Info) error {
+	if !validID(info.ID) {
+		return ErrNotFound
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(s.infoPath(info.ID), data, 0o644); err != nil {
+		os.Remove(s.Path(info.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Info(id string) (Info, error) {
+	info := Info{}
+	if !validID(id) {
+		return info, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, ErrNotFound
+	}
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, err
+	}
+
+	stat, err := os.Stat(s.Path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, ErrNotFound
+	}
+	if err != nil {
+		return info, err
+	}
+	info.Offset = stat.Size()
+
+	return info, nil
+}
+
+func (s *LocalStorage) Append(id string, offset int64, r io.Reader) (int64, error) {
+	info, err := s.Info(id)
+	if err != nil {
+		return 0, err
+	}
+	if info.Offset != offset {
+		return info.Offset, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return offset, err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, info.Length-offset))
+	if err == nil {
+		if extra, _ := io.ReadAtLeast(r, make([]byte, 1), 1); extra > 0 {
+			err = ErrChunkTooLarge
+			if truncateErr := f.Truncate(offset); truncateErr != nil {
+				err = errors.Join(err, truncateErr)
+			} else {
+				n = 0
+			}
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return offset + n, err
+}
+
+func (s *LocalStorage) Terminate(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+
+	err := os.Remove(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.Path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Expired(now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []string(nil)
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok || !validID(id) {
+			continue
+		}
+		info, err := s.Info(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.IsExpired(now) {
+			expired = append(expired, id)
+		}
+	}
+	return expired, nil
+}
+
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,432 @@
+package tus
+
+import (
+	ctx "context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/controller"
+	"github.com/snowmerak/lux/v3/lux"
+	"github.com/snowmerak/lux/v3/middleware"
+)
+
+const (
+	Version    = "1.0.0"
+	Extensions = "creation,creation-with-upload,termination,expiration"
+
+	offsetContentType = "application/offset+octet-stream"
+	exposedHeaders    = "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires"
+)
+
+type Config struct {
+	// MaxSize rejects uploads declaring a larger Upload-Length. Zero means unlimited.
+	MaxSize int64
+	// MaxChunkSize limits the body of each upload request in place of the server wide
+	// lux.SetMaxBodySize, which is meant for ordinary requests. Zero falls back to MaxSize,
+	// or no limit when that is zero too.
+	MaxChunkSize int64
+	// Expiration drops incomplete uploads this long after creation. Zero disables it.
+	Expiration time.Duration
+	// RequestMiddlewares run before every tus request except OPTIONS, e.g. middleware.Auth.
+	RequestMiddlewares  []middleware.Request
+	ResponseMiddlewares []middleware.Response
+	OnComplete          func(lc *context.LuxContext, info Info)
+}
+
+type Handler struct {
+	storage Storage
+	cfg     Config
+	route   string
+	// locked holds the IDs of uploads being changed, only while they are.
+	locked     map[string]struct{}
+	lockedLock sync.Mutex
+}
+
+func New(storage Storage, cfg Config) *Handler {
+	return &Handler{
+		storage: storage,
+		cfg:     cfg,
+		locked:  make(map[string]struct{}),
+	}
+}
+
+// Register serves the creation URL at route and each upload at route/:id.
+func (h *Handler) Register(l *lux.Lux, route string) {
+	route = strings.TrimRight(route, "/")
+	h.route = route
+	uploadRoute := route + "/:id"
+
+	options := controller.RestController{Handler: h.options}
+	l.AddRestController(route, controller.OPTIONS, options)
+	l.AddRestController(uploadRoute, controller.OPTIONS, options)
+
+	l.AddRestController(route, controller.POST, h.controller(h.create))
+	l.AddRestController(uploadRoute, controller.HEAD, h.controller(h.head))
+	l.AddRestController(uploadRoute, controller.PATCH, h.controller(h.patch))
+	l.AddRestController(uploadRoute, controller.DELETE, h.controller(h.terminate))
+}
+
+func (h *Handler) controller(handler controller.RestHandler) controller.RestController {
+	return controller.RestController{
+		RequestMiddlewares:  h.cfg.RequestMiddlewares,
+		Handler:             handler,
+		ResponseMiddlewares: h.cfg.ResponseMiddlewares,
+		MaxBodySize:         h.maxChunkSize(),
+	}
+}
+
+func (h *Handler) maxChunkSize() int64 {
+	switch {
+	case h.cfg.MaxChunkSize > 0:
+		return h.cfg.MaxChunkSize
+	case h.cfg.MaxSize > 0:
+		return h.cfg.MaxSize
+	}
+	return -1
+}
+
+// CleanupExpired terminates every expired upload and returns how many were removed.
+func (h *Handler) CleanupExpired() (int, error) {
+	ids, err := h.storage.Expired(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, id := range ids {
+		unlock, ok := h.tryLock(id)
+		if !ok {
+			continue
+		}
+		err := h.storage.Terminate(id)
+		unlock()
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RunCleanup calls CleanupExpired every interval until ctx is done.
+func (h *Handler) RunCleanup(c ctx.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			h.CleanupExpired()
+		}
+	}
+}
+
+func (h *Handler) tryLock(id string) (func(), bool) {
+	h.lockedLock.Lock()
+	defer h.lockedLock.Unlock()
+	if _, ok := h.locked[id]; ok {
+		return nil, false
+	}
+	h.locked[id] = struct{}{}
+	return func() {
+		h.lockedLock.Lock()
+		defer h.lockedLock.Unlock()
+		delete(h.locked, id)
+	}, true
+}
+
+func (h *Handler) writeCommonHeaders(lc *context.LuxContext) {
+	header := lc.Response.Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Access-Control-Expose-Headers", exposedHeaders)
+}
+
+func (h *Handler) checkVersion(lc *context.LuxContext) bool {
+	h.writeCommonHeaders(lc)
+	if lc.Request.Header.Get("Tus-Resumable") != Version {
+		lc.Response.Header().Set("Tus-Version", Version)
+		lc.Response.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) options(lc *context.LuxContext) error {
+	h.writeCommonHeaders(lc)
+	header := lc.Response.Header()
+	header.Set("Tus-Version", Version)
+	header.Set("Tus-Extension", Extensions)
+	if h.cfg.MaxSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxSize, 10))
+	}
+	lc.SetNoContent()
+	return nil
+}
+
+func (h *Handler) create(lc *context.LuxContext) error {
+	if !h.checkVersion(lc) {
+		return nil
+	}
+
+	length, err := strconv.ParseInt(lc.Request.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		lc.SetBadRequest()
+		return nil
+	}
+	if h.cfg.MaxSize > 0 && length > h.cfg.MaxSize {
+		lc.SetStatus(http.StatusRequestEntityTooLarge)
+		return nil
+	}
+
+	metadata, err := parseMetadata(lc.Request.Header.Get("Upload-Metadata"))
+	if err != nil {
+		lc.SetBadRequest()
+		return nil
+	}
+
+	id, err := newID()
+	if err != nil {
+		lc.SetInternalServerError()
+		return err
+	}
+
+	info := Info{
+		ID:        id,
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if h.cfg.Expiration > 0 {
+		info.ExpiresAt = info.CreatedAt.Add(h.cfg.Expiration)
+	}
+
+	unlock, _ := h.tryLock(id)
+	defer unlock()
+
+	if err := h.storage.Create(info); err != nil {
+		lc.SetInternalServerError()
+		return err
+	}
+
+	header := lc.Response.Header()
+	header.Set("Location", h.route+"/"+id)
+	if !info.ExpiresAt.IsZero() {
+		header.Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
+	if lc.Request.Header.Get("Content-Type") == offsetContentType {
+		offset, err := h.storage.Append(id, 0, lc.Request.Body)
+		header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		if errors.Is(err, ErrChunkTooLarge) {
+			lc.SetStatus(http.StatusRequestEntityTooLarge)
+			return nil
+		}
+		if err != nil {
+			lc.SetInternalServerError()
+			return err
+		}
+		info.Offset = offset
+		h.complete(lc, info)
+	}
+
+	lc.SetStatus(http.StatusCreated)
+	return nil
+}
+
+func (h *Handler) head(lc *context.LuxContext) error {
+	if !h.checkVersion(lc) {
+		return nil
+	}
+
+	info, ok, err := h.lookup(lc)
+	if !ok {
+		return err
+	}
+
+	header := lc.Response.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	if len(info.Metadata) > 0 {
+		header.Set("Upload-Metadata", formatMetadata(info.Metadata))
+	}
+	if !info.ExpiresAt.IsZero() {
+		header.Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	lc.SetOK()
+	return nil
+}
+
+func (h *Handler) patch(lc *context.LuxContext) error {
+	if !h.checkVersion(lc) {
+		return nil
+	}
+
+	if lc.Request.Header.Get("Content-Type") != offsetContentType {
+		lc.SetUnsupportedMediaType()
+		return nil
+	}
+
+	offset, err := strconv.ParseInt(lc.Request.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		lc.SetBadRequest()
+		return nil
+	}
+
+	id := lc.GetPathVariable("id")
+	unlock, ok := h.tryLock(id)
+	if !ok {
+		lc.SetStatus(http.StatusLocked)
+		return nil
+	}
+	defer unlock()
+
+	info, ok, err := h.lookup(lc)
+	if !ok {
+		return err
+	}
+	if info.Offset != offset {
+		lc.SetConflict()
+		return nil
+	}
+	if lc.Request.ContentLength > 0 && offset+lc.Request.ContentLength > info.Length {
+		lc.SetStatus(http.StatusRequestEntityTooLarge)
+		return nil
+	}
+
+	newOffset, err := h.storage.Append(id, offset, lc.Request.Body)
+	lc.Response.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	if errors.Is(err, ErrOffsetMismatch) {
+		lc.SetConflict()
+		return nil
+	}
+	if errors.Is(err, ErrChunkTooLarge) {
+		lc.SetStatus(http.StatusRequestEntityTooLarge)
+		return nil
+	}
+	if err != nil {
+		lc.SetInternalServerError()
+		return fmt.Errorf("tus append %s: %w", id, err)
+	}
+
+	if !info.ExpiresAt.IsZero() {
+		lc.Response.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
+	info.Offset = newOffset
+	h.complete(lc, info)
+	lc.SetNoContent()
+	return nil
+}
+
+func (h *Handler) terminate(lc *context.LuxContext) error {
+	if !h.checkVersion(lc) {
+		return nil
+	}
+
+	id := lc.GetPathVariable("id")
+	unlock, ok := h.tryLock(id)
+	if !ok {
+		lc.SetStatus(http.StatusLocked)
+		return nil
+	}
+	defer unlock()
+
+	err := h.storage.Terminate(id)
+	if errors.Is(err, ErrNotFound) {
+		lc.SetNotFound()
+		return nil
+	}
+	if err != nil {
+		lc.SetInternalServerError()
+		return err
+	}
+
+	lc.SetNoContent()
+	return nil
+}
+
+// lookup loads the upload named by the route, answering 404 or 410 when it is gone.
+func (h *Handler) lookup(lc *context.LuxContext) (Info, bool, error) {
+	id := lc.GetPathVariable("id")
+	info, err := h.storage.Info(id)
+	if errors.Is(err, ErrNotFound) {
+		lc.SetNotFound()
+		return info, false, nil
+	}
+	if err != nil {
+		lc.SetInternalServerError()
+		return info, false, err
+	}
+
+	if info.IsExpired(time.Now()) {
+		h.storage.Terminate(id)
+		lc.SetStatus(http.StatusGone)
+		return info, false, nil
+	}
+
+	return info, true, nil
+}
+
+func (h *Handler) complete(lc *context.LuxContext, info Info) {
+	if info.IsComplete() && h.cfg.OnComplete != nil {
+		h.cfg.OnComplete(lc, info)
+	}
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("tus: empty metadata key")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("tus: metadata %s: %w", key, err)
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
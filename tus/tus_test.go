@@ -0,0 +1,376 @@
+package tus
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/lux/v3/context"
+	"github.com/snowmerak/lux/v3/lux"
+)
+
+func newTestServer(t *testing.T, cfg Config) (*lux.Lux, *LocalStorage) {
+	t.Helper()
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lux.New()
+	New(storage, cfg).Register(l, "/files/")
+	return l, storage
+}
+
+func send(l *lux.Lux, method string, target string, header map[string]string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	return w
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		body   string
+		status int
+		offset string
+	}{
+		{
+			name:   "deferred body",
+			header: map[string]string{"Tus-Resumable": Version, "Upload-Length": "10"},
+			status: http.StatusCreated,
+		},
+		{
+			name:   "no version",
+			header: map[string]string{"Upload-Length": "10"},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "other version",
+			header: map[string]string{"Tus-Resumable": "0.2.2", "Upload-Length": "10"},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "missing length",
+			header: map[string]string{"Tus-Resumable": Version},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "negative length",
+			header: map[string]string{"Tus-Resumable": Version, "Upload-Length": "-1"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too large",
+			header: map[string]string{"Tus-Resumable": Version, "Upload-Length": "101"},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "bad metadata",
+			header: map[string]string{"Tus-Resumable": Version, "Upload-Length": "10", "Upload-Metadata": "name !!!"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "with upload",
+			header: map[string]string{"Tus-Resumable": Version, "Upload-Length": "10", "Content-Type": offsetContentType},
+			body:   "hello",
+			status: http.StatusCreated,
+			offset: "5",
+		},
+		{
+			name:   "with upload beyond the length",
+			header: map[string]string{"Tus-Resumable": Version, "Upload-Length": "3", "Content-Type": offsetContentType},
+			body:   "hello",
+			status: http.StatusRequestEntityTooLarge,
+			offset: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestServer(t, Config{MaxSize: 100})
+			w := send(l, http.MethodPost, "/files", tt.header, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Tus-Resumable"); got != Version {
+				t.Errorf("Tus-Resumable = %q", got)
+			}
+			if got := w.Header().Get("Upload-Offset"); got != tt.offset {
+				t.Errorf("Upload-Offset = %q, want %q", got, tt.offset)
+			}
+			if location := w.Header().Get("Location"); tt.status == http.StatusCreated && !strings.HasPrefix(location, "/files/") {
+				t.Errorf("Location = %q", location)
+			}
+		})
+	}
+}
+
+func TestUpload(t *testing.T) {
+	type step struct {
+		method string
+		header map[string]string
+		body   string
+		status int
+		offset string
+	}
+	patch := func(offset string, body string, status int, newOffset string) step {
+		return step{
+			method: http.MethodPatch,
+			header: map[string]string{"Upload-Offset": offset, "Content-Type": offsetContentType},
+			body:   body,
+			status: status,
+			offset: newOffset,
+		}
+	}
+	head := func(status int, offset string) step {
+		return step{method: http.MethodHead, status: status, offset: offset}
+	}
+
+	tests := []struct {
+		name     string
+		steps    []step
+		data     string
+		complete bool
+	}{
+		{
+			name:  "fresh",
+			steps: []step{head(http.StatusOK, "0")},
+		},
+		{
+			name: "in chunks",
+			steps: []step{
+				patch("0", "hello", http.StatusNoContent, "5"),
+				head(http.StatusOK, "5"),
+				patch("5", " tus!", http.StatusNoContent, "10"),
+				head(http.StatusOK, "10"),
+			},
+			data:     "hello tus!",
+			complete: true,
+		},
+		{
+			name: "offset mismatch",
+			steps: []step{
+				patch("0", "hello", http.StatusNoContent, "5"),
+				patch("0", "hello", http.StatusConflict, ""),
+				patch("7", "xx", http.StatusConflict, ""),
+			},
+			data: "hello",
+		},
+		{
+			name: "beyond the length",
+			steps: []step{
+				patch("0", "hello", http.StatusNoContent, "5"),
+				patch("5", "too long!!", http.StatusRequestEntityTooLarge, ""),
+				head(http.StatusOK, "5"),
+			},
+			data: "hello",
+		},
+		{
+			name:  "chunk above the limit",
+			steps: []step{patch("0", "hello tus!", http.StatusRequestEntityTooLarge, "")},
+		},
+		{
+			name: "wrong content type",
+			steps: []step{{
+				method: http.MethodPatch,
+				header: map[string]string{"Upload-Offset": "0", "Content-Type": "text/plain"},
+				body:   "hello",
+				status: http.StatusUnsupportedMediaType,
+			}},
+		},
+		{
+			name:  "bad offset",
+			steps: []step{patch("-1", "hello", http.StatusBadRequest, "")},
+		},
+		{
+			name: "terminated",
+			steps: []step{
+				{method: http.MethodDelete, status: http.StatusNoContent},
+				head(http.StatusNotFound, ""),
+				patch("0", "hello", http.StatusNotFound, ""),
+				{method: http.MethodDelete, status: http.StatusNotFound},
+			},
+		},
+		{
+			name:  "no version",
+			steps: []step{{method: http.MethodHead, header: map[string]string{"Tus-Resumable": ""}, status: http.StatusPreconditionFailed}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var completed []Info
+			l, storage := newTestServer(t, Config{
+				MaxSize:      100,
+				MaxChunkSize: 8,
+				OnComplete: func(lc *context.LuxContext, info Info) {
+					completed = append(completed, info)
+				},
+			})
+			created := send(l, http.MethodPost, "/files", map[string]string{"Tus-Resumable": Version, "Upload-Length": "10"}, "")
+			location := created.Header().Get("Location")
+			id := strings.TrimPrefix(location, "/files/")
+
+			for i, s := range tt.steps {
+				header := map[string]string{"Tus-Resumable": Version}
+				for key, value := range s.header {
+					header[key] = value
+				}
+				w := send(l, s.method, location, header, s.body)
+				if w.Code != s.status {
+					t.Fatalf("step %d: %s status = %d, want %d", i, s.method, w.Code, s.status)
+				}
+				if s.offset != "" && w.Header().Get("Upload-Offset") != s.offset {
+					t.Errorf("step %d: Upload-Offset = %q, want %q", i, w.Header().Get("Upload-Offset"), s.offset)
+				}
+			}
+
+			if data, err := os.ReadFile(storage.Path(id)); err == nil && string(data) != tt.data {
+				t.Errorf("data = %q, want %q", data, tt.data)
+			}
+			if tt.complete != (len(completed) == 1) {
+				t.Errorf("OnComplete ran %d times, want complete %v", len(completed), tt.complete)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	l, _ := newTestServer(t, Config{MaxSize: 100})
+	for _, target := range []string{"/files", "/files/abc"} {
+		w := send(l, http.MethodOptions, target, nil, "")
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: status = %d", target, w.Code)
+		}
+		want := map[string]string{"Tus-Version": Version, "Tus-Extension": Extensions, "Tus-Max-Size": "100"}
+		for key, value := range want {
+			if got := w.Header().Get(key); got != value {
+				t.Errorf("%s: %s = %q, want %q", target, key, got, value)
+			}
+		}
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	l, storage := newTestServer(t, Config{MaxSize: 100, Expiration: time.Hour})
+	handler := New(storage, Config{})
+	w := send(l, http.MethodPost, "/files", map[string]string{"Tus-Resumable": Version, "Upload-Length": "10"}, "")
+	if _, err := time.Parse(http.TimeFormat, w.Header().Get("Upload-Expires")); err != nil {
+		t.Fatalf("Upload-Expires: %v", err)
+	}
+	id := strings.TrimPrefix(w.Header().Get("Location"), "/files/")
+
+	tests := []struct {
+		now  time.Time
+		want []string
+	}{
+		{time.Now(), nil},
+		{time.Now().Add(2 * time.Hour), []string{id}},
+	}
+	for _, tt := range tests {
+		expired, err := storage.Expired(tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(expired, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Expired(%v) = %q, want %q", tt.now, expired, tt.want)
+		}
+	}
+
+	info, _ := storage.Info(id)
+	info.ExpiresAt = time.Now().Add(-time.Minute)
+	storage.Terminate(id)
+	storage.Create(info)
+	if removed, err := handler.CleanupExpired(); removed != 1 || err != nil {
+		t.Errorf("CleanupExpired = %d, %v, want 1", removed, err)
+	}
+	if _, err := storage.Info(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired upload is still there: %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]string
+		err    bool
+	}{
+		{"", map[string]string{}, false},
+		{"filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==", map[string]string{"filename": "world_domination_plan.pdf"}, false},
+		{"filename d29ybGQ=, is_confidential", map[string]string{"filename": "world", "is_confidential": ""}, false},
+		{"filename !!!", nil, true},
+		{" , filename d29ybGQ=", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMetadata(tt.header)
+		if (err != nil) != tt.err {
+			t.Errorf("parseMetadata(%q) error = %v, want error %v", tt.header, err, tt.err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseMetadata(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("parseMetadata(%q)[%s] = %q, want %q", tt.header, key, got[key], value)
+			}
+		}
+		if parsed, _ := parseMetadata(formatMetadata(got)); len(parsed) != len(got) {
+			t.Errorf("formatMetadata(%v) does not round trip", got)
+		}
+	}
+}
+
+func TestLocalStorageAppend(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		data   string
+		want   int64
+		err    error
+	}{
+		{"fits", 0, "hello", 5, nil},
+		{"fills", 0, "0123456789", 10, nil},
+		{"too large", 0, "0123456789!", 0, ErrChunkTooLarge},
+		{"wrong offset", 3, "hello", 0, ErrOffsetMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := NewLocalStorage(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := storage.Create(Info{ID: "abc", Length: 10}); err != nil {
+				t.Fatal(err)
+			}
+			offset, err := storage.Append("abc", tt.offset, strings.NewReader(tt.data))
+			if offset != tt.want || !errors.Is(err, tt.err) {
+				t.Fatalf("Append = %d, %v, want %d, %v", offset, err, tt.want, tt.err)
+			}
+			info, _ := storage.Info("abc")
+			if info.Offset != tt.want {
+				t.Errorf("stored offset = %d, want %d", info.Offset, tt.want)
+			}
+		})
+	}
+
+	storage, _ := NewLocalStorage(t.TempDir())
+	for _, id := range []string{"", "../abc", "ABC", "abc.info"} {
+		if _, err := storage.Info(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Info(%q) = %v, want ErrNotFound", id, err)
+		}
+		if _, err := storage.Append(id, 0, io.MultiReader()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Append(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxFileNameLength = 255
+
+// SanitizeFileName reduces a client supplied file name to a single safe path element.
+func SanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	builder := strings.Builder{}
+	for _, r := range name {
+		if r == utf8.RuneError || unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			builder.WriteRune('_')
+			continue
+		}
+		builder.WriteRune(r)
+	}
+
+	name = strings.Trim(builder.String(), " .")
+	if name == "" {
+		return "file"
+	}
+
+	if len(name) > maxFileNameLength {
+		ext := path.Ext(name)
+		if len(ext) > maxFileNameLength/2 {
+			ext = ""
+		}
+		base := name[:maxFileNameLength-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+
+	return name
+}
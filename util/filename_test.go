@@ -0,0 +1,47 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.png", "photo.png"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\report.pdf`, "report.pdf"},
+		{"a<b>c:d\"e|f?g*.txt", "a_b_c_d_e_f_g_.txt"},
+		{"tab\there.txt", "tab_here.txt"},
+		{"  .hidden. ", "hidden"},
+		{"..", "file"},
+		{"", "file"},
+		{"/", "_"},
+		{"ファイル.txt", "ファイル.txt"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFileName(tt.name); got != tt.want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFileNameLength(t *testing.T) {
+	tests := []string{
+		strings.Repeat("a", 300) + ".txt",
+		strings.Repeat("é", 200) + ".txt",
+		strings.Repeat("a", 300) + "." + strings.Repeat("b", 200),
+	}
+	for _, name := range tests {
+		got := SanitizeFileName(name)
+		if len(got) > maxFileNameLength || !utf8.ValidString(got) {
+			t.Errorf("SanitizeFileName(%d bytes) = %d bytes, valid %v", len(name), len(got), utf8.ValidString(got))
+		}
+	}
+	if got := SanitizeFileName(tests[0]); !strings.HasSuffix(got, ".txt") {
+		t.Errorf("extension was dropped: %q", got)
+	}
+}
@@ -1,8 +1,17 @@
 package util
 
+import "strings"
+
 func GetContentTypeFromExt(ext string) string {
-	contentType := "text/plain"
-	switch ext {
+	if contentType, ok := LookupContentTypeFromExt(ext); ok {
+		return contentType
+	}
+	return "text/plain"
+}
+
+func LookupContentTypeFromExt(ext string) (string, bool) {
+	contentType := ""
+	switch strings.ToLower(ext) {
 	case ".html":
 		contentType = "text/html"
 	case ".css":
@@ -102,5 +111,5 @@ func GetContentTypeFromExt(ext string) string {
 	case ".tsv":
 		contentType = "text/tab-separated-values"
 	}
-	return contentType
+	return contentType, contentType != ""
 }